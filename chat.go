@@ -0,0 +1,196 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+)
+
+const (
+	chatLabel = "chat"
+	// maxChatLength は1つのメッセージの最大文字数です
+	maxChatLength = 200
+	// chatBurst 件まで続けて送れ、その後は chatRefill ごとに1件ずつ送れるようになります
+	chatBurst  = 3
+	chatRefill = 2 * time.Second
+)
+
+// emotes はレーティング対象の対局で使える定型文です
+// レーティング対象の対局では自由な文章は送受信しません
+var emotes = map[string]string{
+	"hi":    "Hi!",
+	"gl":    "Good luck!",
+	"nice":  "Nice guess!",
+	"think": "Let me think...",
+	"oops":  "Oops!",
+	"gg":    "Good game!",
+}
+
+var chat = &chatChannel{
+	sendLimit: newRateLimiter(chatBurst, chatRefill),
+	recvLimit: newRateLimiter(chatBurst, chatRefill),
+}
+
+type chatMsg struct {
+	Emote string
+	Text  string
+}
+
+// chatChannel は chat のメッセージをやり取りするチャットです
+// P2P では対局とは別の DataChannel を使い、チャットが対局のメッセージを遅らせないようにします
+// 中継サーバは1本の経路しか中継しないので、中継サーバでは対局と同じ経路に混ぜて送ります
+type chatChannel struct {
+	t         transport.Transport
+	muted     bool
+	sendLimit *rateLimiter
+	recvLimit *rateLimiter
+	mu        sync.Mutex
+}
+
+// rateLimiter はトークンバケットで送受信の頻度を制限します
+type rateLimiter struct {
+	tokens float64
+	burst  float64
+	refill time.Duration
+	last   time.Time
+}
+
+func newRateLimiter(burst int, refill time.Duration) *rateLimiter {
+	return &rateLimiter{tokens: float64(burst), burst: float64(burst), refill: refill}
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.refill))
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// attachDataChannel は P2P で相手と開いたチャット用の DataChannel を使い始めます
+func (c *chatChannel) attachDataChannel(dc *webrtc.DataChannel) {
+	t := transport.NewDataChannel(dc)
+	t.OnOpen(func() {
+		c.attach(t)
+	})
+	t.OnMessage(func(data []byte) {
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			logger.Error("failed to unmarshal chat", "err", err)
+			return
+		}
+		if message.Type == "chat" {
+			c.onMessage(chatMsg{Emote: message.Emote, Text: message.Text})
+		}
+	})
+	t.OnClose(func() {
+		c.detach(t)
+	})
+}
+
+// attach は経路 t でチャットを始めます
+// 中継サーバでは対局の経路を渡し、届いた chat のメッセージは対局の onMessage から onMessage に渡します
+func (c *chatChannel) attach(t transport.Transport) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+	// レーティング対象の対局では定型文だけを使う
+	getElementByID("chat-input").Set("disabled", !unrated)
+	appendChatLog("[Sys]", "Chat connected", false)
+}
+
+// detach は経路 t が閉じた時に呼びます
+func (c *chatChannel) detach(t transport.Transport) {
+	c.mu.Lock()
+	attached := c.t == t
+	if attached {
+		c.t = nil
+	}
+	c.mu.Unlock()
+	if attached {
+		getElementByID("chat-input").Set("disabled", true)
+		appendChatLog("[Sys]", "Chat disconnected", false)
+	}
+}
+
+func (c *chatChannel) onMessage(m chatMsg) {
+	c.mu.Lock()
+	allowed, muted := c.recvLimit.allow(time.Now()), c.muted
+	c.mu.Unlock()
+	// 連投は表示せずに捨てる
+	if !allowed || muted {
+		return
+	}
+	text, ok := chatText(m)
+	if !ok {
+		return
+	}
+	appendChatLog("Opponent", text, false)
+}
+
+// chatText は表示する文章を返します
+// レーティング対象の対局の自由な文章や、長すぎる文章は受け付けません
+func chatText(m chatMsg) (string, bool) {
+	if m.Emote != "" {
+		text, ok := emotes[m.Emote]
+		return text, ok
+	}
+	text := strings.TrimSpace(m.Text)
+	if !unrated || text == "" || utf8.RuneCountInString(text) > maxChatLength {
+		return "", false
+	}
+	return text, true
+}
+
+func (c *chatChannel) send(m chatMsg) {
+	text, ok := chatText(m)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.t == nil || c.t.State() != transport.StateOpen {
+		return
+	}
+	if !c.sendLimit.allow(time.Now()) {
+		appendChatLog("[Sys]", "You are sending messages too fast", false)
+		return
+	}
+	if err := sendMessage(c.t, Message{Type: "chat", Emote: m.Emote, Text: m.Text}); err != nil {
+		logger.Error("failed to send chat", "err", err)
+		return
+	}
+	appendChatLog("You", text, true)
+}
+
+// toggleMute は相手のチャットの表示を切り替え、ミュート中かを返します
+func (c *chatChannel) toggleMute() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.muted = !c.muted
+	return c.muted
+}
+
+func appendChatLog(from, text string, isMe bool) {
+	item := js.Global().Get("document").Call("createElement", "li")
+	item.Set("textContent", from+": "+text)
+	if isMe {
+		item.Get("style").Set("fontWeight", "bold")
+	}
+	list := getElementByID("chat-log")
+	list.Call("appendChild", item)
+	list.Set("scrollTop", list.Get("scrollHeight"))
+}
@@ -0,0 +1,36 @@
+// daily は日替わりパズルの手札を決める secret を持ち、call に回答する HTTP サーバです
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/daily"
+)
+
+// cors はブラウザの WASM クライアントから呼べるようにします
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	addr := flag.String("addr", ":8088", "listen address")
+	flag.Parse()
+
+	secret := os.Getenv("DAILY_SECRET")
+	if secret == "" {
+		log.Fatal("DAILY_SECRET is required")
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, cors(daily.Handler(secret))))
+}
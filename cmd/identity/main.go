@@ -0,0 +1,55 @@
+// identity はアカウントの発行と、各サーバや Ayame の認証ウェブフックからのトークン検証を行う HTTP サーバです
+// TURN_SECRET を設定すると、TURN サーバの一時的な認証情報も発行します
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/turnrest"
+)
+
+// cors はブラウザの WASM クライアントから呼べるようにします
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	addr := flag.String("addr", ":8083", "listen address")
+	turnURIs := flag.String("turn-uris", os.Getenv("TURN_URIS"), "comma separated TURN URIs, e.g. turn:turn.example.com:3478?transport=udp")
+	turnTTL := flag.Duration("turn-ttl", turnrest.DefaultTTL, "lifetime of TURN credentials")
+	data := flag.String("data", "identity.jsonl", "file to store accounts")
+	flag.Parse()
+
+	f, err := os.OpenFile(*data, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("failed to open %s: %v", *data, err)
+	}
+	defer f.Close()
+	registry := identity.NewRegistry(f)
+	if err := registry.Load(f); err != nil {
+		log.Fatalf("failed to load %s: %v", *data, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", registry.Handler())
+	if secret := os.Getenv("TURN_SECRET"); secret != "" && *turnURIs != "" {
+		issuer := turnrest.NewIssuer(secret, strings.Split(*turnURIs, ","), *turnTTL, registry)
+		mux.Handle("/turn/", issuer.Handler())
+	} else {
+		log.Printf("TURN_SECRET or TURN URIs are empty, TURN credentials are not issued")
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, cors(mux)))
+}
@@ -0,0 +1,47 @@
+// leaderboard はシーズンごとの順位表を返す HTTP サーバです
+// レーティングサーバは対局ごとに /leaderboard/update へ対局後のレーティングを送ります
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/leaderboard"
+)
+
+// cors はブラウザの WASM クライアントから呼べるようにします
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	addr := flag.String("addr", ":8085", "listen address")
+	data := flag.String("data", "leaderboard.jsonl", "file to store rating updates")
+	flag.Parse()
+
+	secret := os.Getenv("LEADERBOARD_SECRET")
+	if secret == "" {
+		log.Printf("LEADERBOARD_SECRET is empty, updates are rejected")
+	}
+	f, err := os.OpenFile(*data, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("failed to open %s: %v", *data, err)
+	}
+	defer f.Close()
+	l := leaderboard.New(leaderboard.DefaultRules(), f)
+	if err := l.Load(f); err != nil {
+		log.Fatalf("failed to load %s: %v", *data, err)
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, cors(l.Handler(secret))))
+}
@@ -0,0 +1,151 @@
+// profile は対局記録を保存し、プレイヤーの成績と対局履歴を返す HTTP サーバです
+// 記録は各クライアントが1局ごとに自分から見た内容を報告し、両者の報告が食い違わない対局だけを成績に数えます
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/profile"
+)
+
+const defaultHistoryLimit = 20
+
+type recordReqMsg struct {
+	profile.Record
+	Hash string `json:"hash"`
+}
+
+type server struct {
+	solt string
+	// legacyHash は identity のトークンがない報告を従来の hash で受け付けるかです
+	// hash は userID と solt から誰でも計算できるので、移行期間だけ有効にします
+	legacyHash bool
+	identity   identity.Authenticator
+	store      *profile.Store
+}
+
+// verify は identity のトークンで報告者を認証します
+// legacyHash が有効な場合だけ、トークンのない報告を従来の hash で認証します
+func (s *server) verify(r *http.Request, playerID, hash string) error {
+	if token := identity.BearerToken(r); token != "" && s.identity != nil {
+		account, err := s.identity.Authenticate(token, identity.AudienceProfile, time.Now())
+		if err != nil {
+			return err
+		}
+		if account.ID != playerID {
+			return errors.New("token does not match player")
+		}
+		return nil
+	}
+	if !s.legacyHash {
+		return errors.New("identity token is required")
+	}
+	if fmt.Sprintf("%x", sha256.Sum256([]byte(s.solt+playerID+s.solt))) != hash {
+		return errors.New("invalid hash")
+	}
+	return nil
+}
+
+func (s *server) record(w http.ResponseWriter, r *http.Request) {
+	var req recordReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.verify(r, req.PlayerID, req.Hash); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	// 報告の時刻はクライアントの時計ではなくサーバで決める
+	req.PlayedAt = time.Now()
+	if err := s.store.Add(&req.Record); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *server) profile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	writeJSON(w, profile.NewProfile(id, s.store.Records(id)))
+}
+
+func (s *server) history(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if text := r.URL.Query().Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, profile.History(s.store.Records(r.PathValue("id")), limit))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
+
+// cors はブラウザの WASM クライアントから呼べるようにします
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func main() {
+	addr := flag.String("addr", ":8084", "listen address")
+	data := flag.String("data", "profile.jsonl", "file to store game records")
+	identityURL := flag.String("identity", os.Getenv("IDENTITY_URL"), "identity server URL to verify bearer tokens")
+	legacyHash := flag.Bool("legacy-hash", false, "accept reports without an identity token by the legacy hash")
+	flag.Parse()
+
+	f, err := os.OpenFile(*data, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("failed to open %s: %v", *data, err)
+	}
+	defer f.Close()
+	store := profile.NewStore(f)
+	if err := store.Load(f); err != nil {
+		log.Fatalf("failed to load %s: %v", *data, err)
+	}
+
+	s := &server{solt: os.Getenv("SOLT"), legacyHash: *legacyHash, store: store}
+	if *identityURL != "" {
+		u, err := url.Parse(*identityURL)
+		if err != nil {
+			log.Fatalf("invalid identity URL: %v", err)
+		}
+		s.identity = identity.NewClient(*u)
+	} else if !*legacyHash {
+		log.Printf("identity URL is empty and -legacy-hash is off, reports are rejected")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /profile/record", s.record)
+	mux.HandleFunc("GET /profile/{id}", s.profile)
+	mux.HandleFunc("GET /profile/{id}/history", s.history)
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, cors(mux)))
+}
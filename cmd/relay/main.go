@@ -0,0 +1,33 @@
+// relay は P2P でつながらないプレイヤー同士の対局のメッセージを中継する WebSocket サーバです
+// クライアントは P2P の接続に失敗した場合や、中継を選んだ場合に /relay/{room} へつなぎます
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/relay"
+)
+
+func main() {
+	addr := flag.String("addr", ":8086", "listen address")
+	identityURL := flag.String("identity", os.Getenv("IDENTITY_URL"), "identity server URL to verify tokens")
+	pairTimeout := flag.Duration("pair-timeout", relay.DefaultPairTimeout, "time to wait for the opponent")
+	flag.Parse()
+
+	var auth identity.Authenticator
+	if *identityURL != "" {
+		u, err := url.Parse(*identityURL)
+		if err != nil {
+			log.Fatalf("invalid identity URL: %v", err)
+		}
+		auth = identity.NewClient(*u)
+	}
+	s := relay.NewServer(auth, *pairTimeout)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.Handler()))
+}
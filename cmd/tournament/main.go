@@ -0,0 +1,474 @@
+// tournament は大会の参加登録、組み合わせ、結果の集計を行う HTTP サーバです
+// 対局自体は通常の対局と同じく Ayame のルームで行い、結果は /rating/finish と同じ形式で報告を受けます
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/tournament"
+)
+
+type createReqMsg struct {
+	TournamentID string `json:"tournament_id"`
+	Format       string `json:"format"`
+	Rounds       int    `json:"rounds"`
+}
+
+type registerReqMsg struct {
+	TournamentID string `json:"tournament_id"`
+	PlayerID     string `json:"player_id"`
+	Hash         string `json:"hash"`
+}
+
+// finishReqMsg は /rating/finish への報告と同じ形式です
+type finishReqMsg struct {
+	TournamentID string `json:"tournament_id"`
+	MatchID      string `json:"match_id"`
+	PlayerID     string `json:"player_id"`
+	Number       int    `json:"number"`
+	Hash         string `json:"hash"`
+	Result       string `json:"result"`
+	// identity を使う大会では両者の署名付きの結果が必要です
+	Signed *identity.SignedResult `json:"signed,omitempty"`
+}
+
+type arbitrateReqMsg struct {
+	TournamentID string                   `json:"tournament_id"`
+	Decision     identity.ArbiterDecision `json:"decision"`
+}
+
+// pairingResMsg はマッチングサーバの MATCH と同じく、対局するルームと相手を返します
+type pairingResMsg struct {
+	Type   string `json:"type"`
+	Round  int    `json:"round"`
+	RoomID string `json:"room_id"`
+	UserID string `json:"user_id"`
+	First  string `json:"first"`
+}
+
+type pairingView struct {
+	Round   int    `json:"round"`
+	Table   int    `json:"table"`
+	Player1 string `json:"player1"`
+	Player2 string `json:"player2"`
+	RoomID  string `json:"room_id"`
+	Result  string `json:"result"`
+}
+
+type standingsResMsg struct {
+	TournamentID string                 `json:"tournament_id"`
+	Format       string                 `json:"format"`
+	Round        int                    `json:"round"`
+	Rounds       int                    `json:"rounds"`
+	Finished     bool                   `json:"finished"`
+	Standings    []*tournament.Standing `json:"standings"`
+	Pairings     []*pairingView         `json:"pairings"`
+}
+
+type server struct {
+	solt        string
+	identity    *identity.Client
+	arbiter     ed25519.PublicKey
+	tournaments map[string]*tournament.Tournament
+	// 対局ごとの両者の報告(player1 から見た結果)
+	reports map[string]map[string]string
+	mu      sync.Mutex
+}
+
+func newServer(solt string, auth *identity.Client, arbiter ed25519.PublicKey) *server {
+	return &server{
+		solt:        solt,
+		identity:    auth,
+		arbiter:     arbiter,
+		tournaments: make(map[string]*tournament.Tournament),
+		reports:     make(map[string]map[string]string),
+	}
+}
+
+// verify は identity のトークンがあればそれで、なければ従来の hash で参加者を認証します
+func (s *server) verify(r *http.Request, playerID, hash string) error {
+	if token := identity.BearerToken(r); token != "" && s.identity != nil {
+		account, err := s.identity.Authenticate(token, identity.AudienceTournament, time.Now())
+		if err != nil {
+			return err
+		}
+		if account.ID != playerID {
+			return errors.New("token does not match player")
+		}
+		return nil
+	}
+	if fmt.Sprintf("%x", sha256.Sum256([]byte(s.solt+playerID+s.solt))) != hash {
+		return errors.New("invalid hash")
+	}
+	return nil
+}
+
+func (s *server) tournament(id string) (*tournament.Tournament, error) {
+	t, ok := s.tournaments[id]
+	if !ok {
+		return nil, fmt.Errorf("tournament not found: %s", id)
+	}
+	return t, nil
+}
+
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	var req createReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := tournament.NewFormatFromText(req.Format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tournaments[req.TournamentID]; ok || req.TournamentID == "" {
+		http.Error(w, "tournament id is empty or already used", http.StatusConflict)
+		return
+	}
+	s.tournaments[req.TournamentID] = tournament.New(req.TournamentID, format, req.Rounds)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *server) register(w http.ResponseWriter, r *http.Request) {
+	var req registerReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.verify(r, req.PlayerID, req.Hash); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(req.TournamentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := t.Register(req.PlayerID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *server) start(w http.ResponseWriter, r *http.Request) {
+	var req createReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(req.TournamentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := t.Start(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("tournament %s started with %d players", t.ID(), len(t.Players()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// pairing は参加者の現在の対局を返します
+// type は WAIT(開始前や他の卓の終了待ち), MATCH, BYE, FINISH のいずれかです
+func (s *server) pairing(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(r.URL.Query().Get("tournament_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	playerID := r.URL.Query().Get("player_id")
+	if !t.IsRegistered(playerID) {
+		http.Error(w, "player is not registered", http.StatusNotFound)
+		return
+	}
+	res := pairingResMsg{Type: "WAIT", Round: t.CurrentRound()}
+	p := t.PairingOf(playerID)
+	switch {
+	case t.State() == tournament.Finished:
+		res.Type = "FINISH"
+	case p == nil:
+	case p.IsBye():
+		res.Type = "BYE"
+	case !p.IsFinished():
+		res.Type = "MATCH"
+		res.RoomID = p.RoomID()
+		res.UserID = p.Opponent(playerID)
+		res.First = p.Player1()
+	}
+	writeJSON(w, res)
+}
+
+// finish は対局結果の報告を受けます
+// 対局の player1 は Ayame の開室者なので、組み合わせの先手から見た結果に直してから、両者の報告が揃って一致したときに記録します
+func (s *server) finish(w http.ResponseWriter, r *http.Request) {
+	var req finishReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.verify(r, req.PlayerID, req.Hash); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(req.TournamentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	p := t.FindPairing(req.MatchID)
+	if p == nil || !p.Has(req.PlayerID) {
+		http.Error(w, "no pairing for this match", http.StatusNotFound)
+		return
+	}
+	if s.identity != nil {
+		s.finishSigned(w, t, p, req)
+		return
+	}
+	result, err := pairingResult(p, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reports, ok := s.reports[p.RoomID()]
+	if !ok {
+		reports = make(map[string]string)
+		s.reports[p.RoomID()] = reports
+	}
+	reports[req.PlayerID] = result
+	if len(reports) < 2 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if reports[p.Player1()] != reports[p.Player2()] {
+		log.Printf("conflicting reports for %s: %v", p.RoomID(), reports)
+		http.Error(w, "conflicting reports", http.StatusConflict)
+		return
+	}
+	if err := t.Report(p.RoomID(), result); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("tournament %s: %s %s vs %s = %s", t.ID(), p.RoomID(), p.Player1(), p.Player2(), result)
+	w.WriteHeader(http.StatusOK)
+}
+
+// finishSigned は両者の署名が揃った結果だけを記録します
+// 署名が揃わない対局は arbitrate で裁定者が結果を決めます
+func (s *server) finishSigned(w http.ResponseWriter, t *tournament.Tournament, p *tournament.Pairing, req finishReqMsg) {
+	signed := req.Signed
+	if signed == nil || signed.Statement.MatchID != req.MatchID {
+		http.Error(w, identity.ErrUnsignedResult.Error(), http.StatusUnauthorized)
+		return
+	}
+	var accounts [2]*identity.Account
+	for i, id := range signed.Statement.Players {
+		if !p.Has(id) {
+			http.Error(w, "signed result is not for this pairing", http.StatusBadRequest)
+			return
+		}
+		account, err := s.identity.Lookup(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		accounts[i] = account
+	}
+	if err := signed.Verify(accounts); err != nil {
+		log.Printf("rejected result for %s: %v", p.RoomID(), err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.record(w, t, p, signed.Statement.Players[0], signed.Statement.Result)
+}
+
+// arbitrate は裁定者の署名がある結果を記録します
+func (s *server) arbitrate(w http.ResponseWriter, r *http.Request) {
+	var req arbitrateReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.arbiter == nil {
+		http.Error(w, "arbiter is not configured", http.StatusNotImplemented)
+		return
+	}
+	if err := req.Decision.Verify(s.arbiter); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(req.TournamentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	p := t.FindPairing(req.Decision.MatchID)
+	if p == nil || !p.Has(req.Decision.Players[0]) || !p.Has(req.Decision.Players[1]) {
+		http.Error(w, "no pairing for this match", http.StatusNotFound)
+		return
+	}
+	log.Printf("arbiter decided %s: %s", p.RoomID(), req.Decision.Reason)
+	s.record(w, t, p, req.Decision.Players[0], req.Decision.Result)
+}
+
+// record は Ayame の player1 である opener から見た result を組み合わせの結果として記録します
+func (s *server) record(w http.ResponseWriter, t *tournament.Tournament, p *tournament.Pairing, opener, result string) {
+	result, err := pairingResult(p, finishReqMsg{PlayerID: opener, Number: 1, Result: result})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := t.Report(p.RoomID(), result); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("tournament %s: %s %s vs %s = %s", t.ID(), p.RoomID(), p.Player1(), p.Player2(), result)
+	w.WriteHeader(http.StatusOK)
+}
+
+// pairingResult は報告者の番号と結果から、組み合わせの player1 から見た結果を求めます
+func pairingResult(p *tournament.Pairing, req finishReqMsg) (string, error) {
+	var myScore string
+	switch {
+	case req.Result == tournament.Drawn:
+		return tournament.Drawn, nil
+	case req.Number == 1:
+		myScore = req.Result
+	case req.Number == 2 && req.Result == tournament.Player1Win:
+		myScore = tournament.Player2Win
+	case req.Number == 2 && req.Result == tournament.Player2Win:
+		myScore = tournament.Player1Win
+	default:
+		return "", fmt.Errorf("invalid report: number=%d, result=%q", req.Number, req.Result)
+	}
+	if req.PlayerID == p.Player1() {
+		return myScore, nil
+	}
+	if myScore == tournament.Player1Win {
+		return tournament.Player2Win, nil
+	}
+	return tournament.Player1Win, nil
+}
+
+func (s *server) standings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.tournament(r.URL.Query().Get("tournament_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	res := standingsResMsg{
+		TournamentID: t.ID(),
+		Format:       t.Format().Msg(),
+		Round:        t.CurrentRound(),
+		Rounds:       t.Rounds(),
+		Finished:     t.State() == tournament.Finished,
+		Standings:    t.Standings(),
+		Pairings:     make([]*pairingView, 0),
+	}
+	for _, p := range t.Pairings(t.CurrentRound()) {
+		res.Pairings = append(res.Pairings, &pairingView{p.Round(), p.Table(), p.Player1(), p.Player2(), p.RoomID(), p.Result()})
+	}
+	writeJSON(w, res)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
+
+// cors はブラウザの WASM クライアントから呼べるようにします
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// organizer は secret を Bearer トークンとして送った主催者のリクエストだけを通します
+func organizer(secret string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", ":8082", "listen address")
+	identityURL := flag.String("identity", os.Getenv("IDENTITY_URL"), "identity server URL to verify bearer tokens and signed results")
+	arbiterKey := flag.String("arbiter", os.Getenv("ARBITER_PUBLIC_KEY"), "public key of the arbiter who decides results without both signatures")
+	flag.Parse()
+
+	var auth *identity.Client
+	if *identityURL != "" {
+		u, err := url.Parse(*identityURL)
+		if err != nil {
+			log.Fatalf("invalid identity URL: %v", err)
+		}
+		auth = identity.NewClient(*u)
+	}
+	var arbiter ed25519.PublicKey
+	if *arbiterKey != "" {
+		key, err := identity.DecodePublicKey(*arbiterKey)
+		if err != nil {
+			log.Fatalf("invalid arbiter key: %v", err)
+		}
+		arbiter = key
+	}
+	organizerSecret := os.Getenv("TOURNAMENT_ORGANIZER_SECRET")
+	if organizerSecret == "" {
+		log.Printf("TOURNAMENT_ORGANIZER_SECRET is empty, creating and starting tournaments are rejected")
+	}
+	s := newServer(os.Getenv("SOLT"), auth, arbiter)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tournament/create", organizer(organizerSecret, s.create))
+	mux.HandleFunc("POST /tournament/register", s.register)
+	mux.HandleFunc("POST /tournament/start", organizer(organizerSecret, s.start))
+	mux.HandleFunc("GET /tournament/pairing", s.pairing)
+	mux.HandleFunc("POST /tournament/finish", s.finish)
+	mux.HandleFunc("POST /tournament/arbitrate", s.arbitrate)
+	mux.HandleFunc("GET /tournament/standings", s.standings)
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, cors(mux)))
+}
@@ -0,0 +1,129 @@
+// turn は NAT の内側からでも対局できるように、通信を中継する TURN サーバです
+// 認証は identity サーバが発行する TURN REST API 形式の一時的な認証情報で行い、TURN_SECRET を identity サーバと共有します
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/turn/v2"
+	"github.com/ponyo877/go-wasm-hit-and-blow/turnrest"
+)
+
+// quotaIdle はやり取りのなくなったクライアントのアドレスを忘れるまでの時間です
+const quotaIdle = 10 * time.Minute
+
+type healthResMsg struct {
+	Status      string `json:"status"`
+	Allocations int    `json:"allocations"`
+}
+
+func main() {
+	publicIP := flag.String("public-ip", os.Getenv("TURN_PUBLIC_IP"), "IP address clients use to reach relayed ports")
+	udpAddr := flag.String("udp", ":3478", "UDP listen address")
+	tcpAddr := flag.String("tcp", "", "TCP listen address, e.g. :3478 for networks that block UDP")
+	realm := flag.String("realm", "hit-and-blow", "realm of long-term credentials")
+	minPort := flag.Uint("min-port", 49152, "lowest relayed port")
+	maxPort := flag.Uint("max-port", 65535, "highest relayed port")
+	maxSessions := flag.Int("max-sessions", 4, "concurrent client addresses per user")
+	maxBytes := flag.Int64("max-bytes", 512<<20, "relayed bytes per user in each quota window")
+	quotaWindow := flag.Duration("quota-window", 24*time.Hour, "period to reset relayed bytes")
+	healthAddr := flag.String("health", ":8087", "HTTP listen address of the health endpoint")
+	flag.Parse()
+
+	secret := os.Getenv("TURN_SECRET")
+	if secret == "" {
+		log.Fatal("TURN_SECRET is empty")
+	}
+	relayIP := net.ParseIP(*publicIP)
+	if relayIP == nil {
+		log.Fatalf("invalid public IP: %q", *publicIP)
+	}
+	if *minPort == 0 || *minPort > *maxPort || *maxPort > 65535 {
+		log.Fatalf("invalid port range: %d-%d", *minPort, *maxPort)
+	}
+
+	// 割り当ては一定時間ごとに更新されるので、更新もやり取りもなくなったアドレスは使い終わったとみなす
+	q := newQuota(*maxSessions, *maxBytes, *quotaWindow, quotaIdle)
+	go func() {
+		for now := range time.Tick(quotaIdle) {
+			q.sweep(now)
+		}
+	}()
+	relayAddressGenerator := func() turn.RelayAddressGenerator {
+		return &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: relayIP,
+			Address:      "0.0.0.0",
+			MinPort:      uint16(*minPort),
+			MaxPort:      uint16(*maxPort),
+		}
+	}
+	config := turn.ServerConfig{
+		Realm:         *realm,
+		LoggerFactory: logging.NewDefaultLoggerFactory(),
+		AuthHandler: func(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+			now := time.Now()
+			userID, err := turnrest.Verify(username, now)
+			if err != nil {
+				log.Printf("rejected %s from %s: %v", username, srcAddr, err)
+				return nil, false
+			}
+			if !q.authorize(userID, srcAddr, now) {
+				log.Printf("rejected %s from %s: %v", userID, srcAddr, errQuotaExceeded)
+				return nil, false
+			}
+			return turn.GenerateAuthKey(username, realm, turnrest.Password(secret, username)), true
+		},
+	}
+
+	udp, err := net.ListenPacket("udp4", *udpAddr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *udpAddr, err)
+	}
+	config.PacketConnConfigs = []turn.PacketConnConfig{{
+		PacketConn:            &quotaPacketConn{PacketConn: udp, quota: q},
+		RelayAddressGenerator: relayAddressGenerator(),
+	}}
+	if *tcpAddr != "" {
+		tcp, err := net.Listen("tcp4", *tcpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", *tcpAddr, err)
+		}
+		config.ListenerConfigs = []turn.ListenerConfig{{
+			Listener:              &quotaListener{Listener: tcp, quota: q},
+			RelayAddressGenerator: relayAddressGenerator(),
+		}}
+	}
+	server, err := turn.NewServer(config)
+	if err != nil {
+		log.Fatalf("failed to start TURN server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(healthResMsg{Status: "ok", Allocations: server.AllocationCount()}); err != nil {
+			log.Printf("failed to encode response: %v", err)
+		}
+	})
+	go func() {
+		log.Printf("health endpoint listening on %s", *healthAddr)
+		log.Fatal(http.ListenAndServe(*healthAddr, mux))
+	}()
+
+	log.Printf("TURN server listening on %s (udp) %s (tcp), relaying %s:%d-%d", *udpAddr, *tcpAddr, relayIP, *minPort, *maxPort)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	if err := server.Close(); err != nil {
+		log.Printf("failed to close TURN server: %v", err)
+	}
+}
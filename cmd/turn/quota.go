@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// quota はユーザーごとの同時接続数と転送量を制限します
+// TURN の認証でわかったクライアントのアドレスとユーザーを結び付け、そのアドレスとのパケットを数えます
+type quota struct {
+	maxSessions int
+	maxBytes    int64
+	window      time.Duration
+	idle        time.Duration
+
+	users map[string]*usage
+	// addrs はクライアントのアドレスからユーザーを引きます
+	addrs map[string]string
+	mu    sync.Mutex
+}
+
+type usage struct {
+	bytes int64
+	since time.Time
+	// sessions はクライアントのアドレスごとの最後に認証またはやり取りした時刻です
+	sessions map[string]time.Time
+}
+
+// reset は window が過ぎていれば転送量を数え直します
+func (u *usage) reset(now time.Time, window time.Duration) {
+	if now.Sub(u.since) >= window {
+		u.bytes, u.since = 0, now
+	}
+}
+
+func newQuota(maxSessions int, maxBytes int64, window, idle time.Duration) *quota {
+	return &quota{
+		maxSessions: maxSessions,
+		maxBytes:    maxBytes,
+		window:      window,
+		idle:        idle,
+		users:       make(map[string]*usage),
+		addrs:       make(map[string]string),
+	}
+}
+
+// authorize は userID が addr から使えるかを返します
+// 同時に使えるアドレスの数と、window ごとの転送量を超えていれば使えません
+func (q *quota) authorize(userID string, addr net.Addr, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.users[userID]
+	if !ok {
+		u = &usage{since: now, sessions: make(map[string]time.Time)}
+		q.users[userID] = u
+	}
+	u.reset(now, q.window)
+	q.expire(userID, u, now)
+	if u.bytes >= q.maxBytes {
+		return false
+	}
+	key := addr.String()
+	if _, ok := u.sessions[key]; !ok && len(u.sessions) >= q.maxSessions {
+		return false
+	}
+	u.sessions[key] = now
+	q.addrs[key] = userID
+	return true
+}
+
+// expire は idle の間使われなかったアドレスを userID の接続から外します
+func (q *quota) expire(userID string, u *usage, now time.Time) {
+	for a, last := range u.sessions {
+		if now.Sub(last) < q.idle {
+			continue
+		}
+		delete(u.sessions, a)
+		// 同じアドレスを別のユーザーが使い始めていれば残す
+		if q.addrs[a] == userID {
+			delete(q.addrs, a)
+		}
+	}
+}
+
+// sweep は使われなくなったアドレスとユーザーを忘れます
+// 接続のなくなったユーザーも window が過ぎるまでは残し、つなぎ直して転送量の上限を逃れられないようにします
+func (q *quota) sweep(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for userID, u := range q.users {
+		q.expire(userID, u, now)
+		if len(u.sessions) == 0 && now.Sub(u.since) >= q.window {
+			delete(q.users, userID)
+		}
+	}
+}
+
+// consume は addr とやり取りした n バイトをユーザーの転送量に加え、上限以内かを返します
+// 認証前のパケットは数えません
+// 上限を超えたユーザーも、window が過ぎれば認証し直さずに使えるようになります
+func (q *quota) consume(addr net.Addr, n int, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := addr.String()
+	userID, ok := q.addrs[key]
+	if !ok {
+		return true
+	}
+	u := q.users[userID]
+	u.reset(now, q.window)
+	u.sessions[key] = now
+	u.bytes += int64(n)
+	return u.bytes <= q.maxBytes
+}
+
+// quotaPacketConn は上限を超えたユーザーの UDP のパケットを捨てます
+type quotaPacketConn struct {
+	net.PacketConn
+	quota *quota
+}
+
+func (c *quotaPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || c.quota.consume(addr, n, time.Now()) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *quotaPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !c.quota.consume(addr, len(p), time.Now()) {
+		// UDP なので届かなかったことにする
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// quotaListener は上限を超えたユーザーの TCP の接続を切ります
+type quotaListener struct {
+	net.Listener
+	quota *quota
+}
+
+func (l *quotaListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &quotaConn{Conn: conn, quota: l.quota}, nil
+}
+
+type quotaConn struct {
+	net.Conn
+	quota *quota
+}
+
+func (c *quotaConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && !c.quota.consume(c.RemoteAddr(), n, time.Now()) {
+		c.Conn.Close()
+		return 0, errQuotaExceeded
+	}
+	return n, err
+}
+
+func (c *quotaConn) Write(p []byte) (int, error) {
+	if !c.quota.consume(c.RemoteAddr(), len(p), time.Now()) {
+		c.Conn.Close()
+		return 0, errQuotaExceeded
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+const (
+	testWindow = time.Hour
+	testIdle   = 10 * time.Minute
+)
+
+func udpAddr(port int) net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: port}
+}
+
+func TestQuotaAuthorize(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		// setup は userID "alice" の準備で、最後に authorize する状況を作ります
+		setup func(q *quota)
+		addr  net.Addr
+		at    time.Time
+		want  bool
+	}{
+		{
+			name:  "first address",
+			setup: func(q *quota) {},
+			addr:  udpAddr(1),
+			at:    start,
+			want:  true,
+		},
+		{
+			name: "same address again",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.authorize("alice", udpAddr(2), start)
+			},
+			addr: udpAddr(1),
+			at:   start,
+			want: true,
+		},
+		{
+			name: "too many addresses",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.authorize("alice", udpAddr(2), start)
+			},
+			addr: udpAddr(3),
+			at:   start,
+			want: false,
+		},
+		{
+			name: "idle address frees a session",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.authorize("alice", udpAddr(2), start)
+			},
+			addr: udpAddr(3),
+			at:   start.Add(testIdle),
+			want: true,
+		},
+		{
+			name: "traffic keeps a session",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.authorize("alice", udpAddr(2), start)
+				q.consume(udpAddr(1), 1, start.Add(testIdle-time.Second))
+				q.consume(udpAddr(2), 1, start.Add(testIdle-time.Second))
+			},
+			addr: udpAddr(3),
+			at:   start.Add(testIdle),
+			want: false,
+		},
+		{
+			name: "bytes exceeded",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.consume(udpAddr(1), 100, start)
+			},
+			addr: udpAddr(2),
+			at:   start,
+			want: false,
+		},
+		{
+			name: "bytes reset after the window",
+			setup: func(q *quota) {
+				q.authorize("alice", udpAddr(1), start)
+				q.consume(udpAddr(1), 100, start)
+			},
+			addr: udpAddr(1),
+			at:   start.Add(testWindow),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuota(2, 100, testWindow, testIdle)
+			tt.setup(q)
+			if got := q.authorize("alice", tt.addr, tt.at); got != tt.want {
+				t.Errorf("authorize() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaConsume(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	q := newQuota(2, 100, testWindow, testIdle)
+	if !q.consume(udpAddr(1), 1000, start) {
+		t.Error("consume() before authorize = false, want true")
+	}
+	if !q.authorize("alice", udpAddr(1), start) {
+		t.Fatal("authorize() = false")
+	}
+	steps := []struct {
+		n    int
+		at   time.Time
+		want bool
+	}{
+		{n: 60, at: start, want: true},
+		{n: 40, at: start.Add(time.Minute), want: true},
+		{n: 1, at: start.Add(2 * time.Minute), want: false},
+		// 上限を超えた後も、window が過ぎれば認証し直さずに使える
+		{n: 100, at: start.Add(testWindow), want: true},
+		{n: 1, at: start.Add(testWindow + time.Minute), want: false},
+	}
+	for i, s := range steps {
+		if got := q.consume(udpAddr(1), s.n, s.at); got != s.want {
+			t.Errorf("step %d: consume(%d) = %t, want %t", i, s.n, got, s.want)
+		}
+	}
+}
+
+func TestQuotaSweep(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	q := newQuota(2, 100, testWindow, testIdle)
+	q.authorize("alice", udpAddr(1), start)
+	q.authorize("bob", udpAddr(2), start)
+	q.consume(udpAddr(1), 100, start)
+	// bob のアドレスを carol が使い始める
+	q.authorize("carol", udpAddr(2), start.Add(testIdle-time.Minute))
+
+	q.sweep(start.Add(testIdle))
+	if _, ok := q.addrs[udpAddr(1).String()]; ok {
+		t.Error("idle address of alice is kept")
+	}
+	if got := q.addrs[udpAddr(2).String()]; got != "carol" {
+		t.Errorf("address reused by carol belongs to %q", got)
+	}
+	// 転送量を使い切ったユーザーは window が過ぎるまで忘れない
+	if q.authorize("alice", udpAddr(3), start.Add(testIdle)) {
+		t.Error("authorize() after sweep = true, want false until the window passes")
+	}
+
+	q.sweep(start.Add(2*testWindow))
+	if len(q.users) != 0 || len(q.addrs) != 0 {
+		t.Errorf("sweep() kept %d users and %d addresses, want none", len(q.users), len(q.addrs))
+	}
+}
@@ -0,0 +1,138 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"syscall/js"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+)
+
+// startCoop は協力プレイを始めます
+// ホストは secret を持つディーラーとなり、ids に自分を含めたときはボットとして手札を伏せたまま自分も call します
+func (rc *roomClient) startCoop(ids []string, secret *game.Hand) {
+	if err := rc.coop.Start(ids, secret); err != nil {
+		js.Global().Call("alert", err.Error())
+		return
+	}
+	getElementByID("room-target").Set("disabled", true)
+	logElem(fmt.Sprintf("[Co-op]: Start with %v\n", ids))
+	rc.renderCoop()
+}
+
+// dealCoop はホストとして協力プレイの手札を決めて開始を通知します
+// hand が nil のときはボットが手札を選び、ホストもチームに加わります
+func (rc *roomClient) dealCoop(hand *game.Hand) {
+	ids := rc.lobby()
+	dealerOnly := hand != nil
+	if dealerOnly {
+		// ホストが手札を決めた場合はディーラーに専念する
+		ids = ids[1:]
+	} else {
+		rand.NewSource(time.Now().UnixNano())
+		hand = game.NewHandBySeed(rand.Int())
+	}
+	if len(ids) < game.MinCoopPlayers || len(ids) > game.MaxCoopPlayers {
+		js.Global().Call("alert", fmt.Sprintf("Co-op needs %d-%d players", game.MinCoopPlayers, game.MaxCoopPlayers))
+		return
+	}
+	rc.send(Message{Type: "coop_start", Players: ids})
+	rc.startCoop(ids, hand)
+	if dealerOnly {
+		setHand(true, hand)
+	}
+}
+
+func (rc *roomClient) sendCoopGuess(guess *game.Guess) {
+	message := Message{Type: "coop_guess", From: rc.userID, Guess: guess.Msg()}
+	rc.send(message)
+	rc.applyCoopGuess(message)
+}
+
+func (rc *roomClient) applyCoopGuess(message Message) {
+	if err := rc.coop.Guess(message.From, game.NewGuessFromText(message.Guess)); err != nil {
+		logger.Warn("invalid co-op guess", "err", err)
+		return
+	}
+	rc.renderCoop()
+	if !rc.coop.IsDealer() {
+		return
+	}
+	ans, err := rc.coop.CalcAnswer()
+	if err != nil {
+		logger.Error("failed to answer co-op guess", "err", err)
+		return
+	}
+	hit, blow := ans.Hit(), ans.Blow()
+	answer := Message{Type: "coop_answer", From: rc.userID, Hit: &hit, Blow: &blow}
+	rc.send(answer)
+	rc.applyCoopAnswer(answer)
+}
+
+func (rc *roomClient) applyCoopAnswer(message Message) {
+	if message.Hit == nil || message.Blow == nil {
+		logger.Warn("invalid co-op answer", "from", message.From)
+		return
+	}
+	if err := rc.coop.Answer(game.NewAnswer(*message.Hit, *message.Blow)); err != nil {
+		logger.Warn("invalid co-op answer", "err", err)
+		return
+	}
+	calls := rc.coop.Calls()
+	call := calls[len(calls)-1]
+	qa := call.QA()
+	setScore(game.MyTurn, len(calls), qa.Guess().View(), qa.Answer().Hit(), qa.Answer().Blow())
+	appendRoomLog(call.View())
+	logElem(fmt.Sprintf("[Co-op]: %s\n", call.View()))
+	if rc.coop.IsFinished() {
+		setHand(false, game.NewHandFromText(qa.Guess().Msg()))
+	}
+	rc.renderCoop()
+}
+
+func (rc *roomClient) onCoopLeave(peer *roomPeer) {
+	if !rc.isHost {
+		// ディーラーがいなくなると続けられない
+		setTurn("Dealer left the room")
+		return
+	}
+	logElem(fmt.Sprintf("[Co-op]: %s disconnected\n", peer.userID))
+	rc.coop.Leave(peer.userID)
+	rc.send(Message{Type: "room_leave", From: peer.userID})
+	rc.renderCoop()
+}
+
+func (rc *roomClient) sendChat(text string) {
+	message := Message{Type: "room_chat", From: rc.userID, Info: text}
+	rc.send(message)
+	appendRoomLog(fmt.Sprintf("%s: %s", rc.userID, text))
+}
+
+func (rc *roomClient) renderCoop() {
+	players := getElementByID("room-players")
+	players.Set("innerHTML", "")
+	for _, id := range rc.coop.Players() {
+		text := id
+		if id == rc.userID {
+			text += " (You)"
+		}
+		if rc.coop.IsPlaying() && rc.coop.CurrentPlayer() == id {
+			text += " <- turn"
+		}
+		item := js.Global().Get("document").Call("createElement", "li")
+		item.Set("textContent", text)
+		players.Call("appendChild", item)
+	}
+	switch {
+	case rc.coop.IsFinished():
+		setTurn(fmt.Sprintf("Solved !!! Team score: %d guesses", rc.coop.Score()))
+	case rc.coop.IsMyTurn():
+		setTurn(fmt.Sprintf("It's Your Turn ! (%d candidates left)", rc.coop.Candidates()))
+	case rc.coop.IsPlaying():
+		setTurn(fmt.Sprintf("It's %s's Turn, Waiting ... (%d candidates left)", rc.coop.CurrentPlayer(), rc.coop.Candidates()))
+	}
+}
@@ -0,0 +1,153 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"syscall/js"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/daily"
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+)
+
+var (
+	// dailyOrigin は日替わりの手札を決める secret を持つサーバです
+	dailyOrigin string
+	// currentDaily は挑戦中のデイリーパズルです
+	currentDaily *game.Daily
+)
+
+// startDaily はサーバから今日の日付とコミットメントを受け取り、デイリーパズルを始めます
+// 今日すでにクリアしている場合は保存した結果を表示します
+func startDaily(dailyURL url.URL) {
+	dailyURL.Path = path.Join(dailyURL.Path, "today")
+	res, err := http.Get(dailyURL.String())
+	if err != nil {
+		logger.Error("failed to get daily puzzle", "err", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logger.Error("failed to get daily puzzle", "status", res.Status)
+		return
+	}
+	var today daily.TodayResMsg
+	if err := json.NewDecoder(res.Body).Decode(&today); err != nil {
+		logger.Error("failed to decode daily puzzle", "err", err)
+		return
+	}
+	localStorage := js.Global().Get("localStorage")
+	if localStorage.Get("dailyLast").String() == today.Date {
+		setTurn(fmt.Sprintf("Already solved today ! Streak: %s", localStorage.Get("dailyStreak").String()))
+		setDailyShare(localStorage.Get("dailyShare").String())
+		return
+	}
+	currentDaily = game.NewDaily(today.Date, today.Commitment)
+	getElementByID("start").Set("disabled", true)
+	logElem(fmt.Sprintf("[Daily]: %s\n", today.Date))
+	setTurn("Daily Puzzle ! Guess the hand")
+}
+
+// sendDailyGuess は guess をサーバに送って回答を受け取ります
+func sendDailyGuess(dailyURL url.URL, guess *game.Guess) {
+	if err := currentDaily.CanGuess(guess); err != nil {
+		js.Global().Call("alert", err.Error())
+		return
+	}
+	verdict, err := requestDailyGuess(dailyURL, guess)
+	if err != nil {
+		logger.Error("failed to send daily guess", "err", err)
+		return
+	}
+	answer := game.NewAnswer(verdict.Hit, verdict.Blow)
+	if err := currentDaily.Record(guess, answer, verdict.Nonce); err != nil {
+		logger.Error("failed to record daily guess", "err", err)
+		logElem("[Daily]: The daily hand does not match the commitment!\n")
+		return
+	}
+	row := len(currentDaily.QAs())
+	setScore(game.MyTurn, row, guess.View(), answer.Hit(), answer.Blow())
+	logElem(fmt.Sprintf("[Daily]: %s (%s)\n", guess.View(), answer.Msg()))
+	if !currentDaily.IsSolved() {
+		setTurn(fmt.Sprintf("Daily Puzzle ! %d guesses", row))
+		return
+	}
+	setHand(false, game.NewHandFromText(guess.Msg()))
+	streak := saveDailyResult(currentDaily)
+	setTurn(fmt.Sprintf("Solved in %d guesses ! Streak: %d", row, streak))
+	setDailyShare(currentDaily.Share())
+}
+
+func requestDailyGuess(dailyURL url.URL, guess *game.Guess) (*daily.GuessResMsg, error) {
+	dailyURL.Path = path.Join(dailyURL.Path, "guess")
+	body, err := json.Marshal(daily.GuessReqMsg{Date: currentDaily.Date(), Guess: guess.Msg()})
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.Post(dailyURL.String(), "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	var verdict daily.GuessResMsg
+	if err := json.NewDecoder(res.Body).Decode(&verdict); err != nil {
+		return nil, err
+	}
+	return &verdict, nil
+}
+
+// saveDailyResult は userID, hash と同じく localStorage に連続クリア日数を保存します
+func saveDailyResult(daily *game.Daily) int {
+	localStorage := js.Global().Get("localStorage")
+	var streak, best int
+	if v := localStorage.Get("dailyStreak"); !v.Equal(js.Null()) && !v.Equal(js.Undefined()) {
+		streak, _ = strconv.Atoi(v.String())
+	}
+	if v := localStorage.Get("dailyBest"); !v.Equal(js.Null()) && !v.Equal(js.Undefined()) {
+		best, _ = strconv.Atoi(v.String())
+	}
+	streak = game.NextStreak(localStorage.Get("dailyLast").String(), streak, daily.Date())
+	best = max(best, streak)
+	localStorage.Set("dailyLast", daily.Date())
+	localStorage.Set("dailyStreak", strconv.Itoa(streak))
+	localStorage.Set("dailyBest", strconv.Itoa(best))
+	localStorage.Set("dailyShare", daily.Share())
+	return streak
+}
+
+func setDailyShare(share string) {
+	getElementByID("daily-share").Set("value", share)
+	getElementByID("share-daily").Set("disabled", share == "")
+}
+
+func copyDailyShare() {
+	share := getElementByID("daily-share").Get("value").String()
+	if share == "" {
+		return
+	}
+	promise := js.Global().Get("navigator").Get("clipboard").Call("writeText", share)
+	var then, catch js.Func
+	then = js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		logElem("[Daily]: Copied result\n")
+		then.Release()
+		catch.Release()
+		return js.Undefined()
+	})
+	catch = js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		logger.Error("failed to copy daily result", "err", args[0].Call("toString").String())
+		then.Release()
+		catch.Release()
+		return js.Undefined()
+	})
+	promise.Call("then", then).Call("catch", catch)
+}
@@ -0,0 +1,107 @@
+// daily は日替わりパズルの call に回答する HTTP ハンドラです
+// 手札を決める secret をクライアントに渡さないため、回答はすべてサーバで行います
+package daily
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+)
+
+// TodayResMsg は今日の日付と手札のコミットメントです
+type TodayResMsg struct {
+	Date       string `json:"date"`
+	Commitment string `json:"commitment"`
+}
+
+// GuessReqMsg は date のパズルへの call です
+type GuessReqMsg struct {
+	Date  string `json:"date"`
+	Guess string `json:"guess"`
+}
+
+// GuessResMsg は call への回答で、当たった場合だけコミットメントのノンスを添えます
+type GuessResMsg struct {
+	Hit   int    `json:"hit"`
+	Blow  int    `json:"blow"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// RevealResMsg は終わった日の手札とノンスで、誰でもコミットメントを確かめられます
+type RevealResMsg struct {
+	Date  string `json:"date"`
+	Hand  string `json:"hand"`
+	Nonce string `json:"nonce"`
+}
+
+type server struct {
+	secret string
+	now    func() time.Time
+}
+
+// Handler は secret から決めた日替わりの手札で回答します
+func Handler(secret string) http.Handler {
+	s := &server{secret: secret, now: time.Now}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /daily/today", s.today)
+	mux.HandleFunc("POST /daily/guess", s.guess)
+	mux.HandleFunc("GET /daily/reveal/{date}", s.reveal)
+	return mux
+}
+
+func (s *server) today(w http.ResponseWriter, r *http.Request) {
+	date := game.DailyDate(s.now())
+	commitment, _ := game.NewDailyCommitment(s.secret, date)
+	writeJSON(w, TodayResMsg{Date: date, Commitment: commitment})
+}
+
+// guess は日付が変わる直前に始めたプレイヤーのため、前日のパズルへの call も受け付けます
+func (s *server) guess(w http.ResponseWriter, r *http.Request) {
+	var req GuessReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := s.now()
+	if req.Date != game.DailyDate(now) && req.Date != game.DailyDate(now.AddDate(0, 0, -1)) {
+		http.Error(w, "daily puzzle is not open", http.StatusNotFound)
+		return
+	}
+	guess := game.NewGuessFromText(req.Guess)
+	if len(req.Guess) != 3 || !game.NewHand(*guess).IsValid() {
+		http.Error(w, "invalid guess", http.StatusBadRequest)
+		return
+	}
+	answer := game.NewDailyHand(s.secret, req.Date).Answer(guess)
+	res := GuessResMsg{Hit: answer.Hit(), Blow: answer.Blow()}
+	if answer.IsAllHit() {
+		_, res.Nonce = game.NewDailyCommitment(s.secret, req.Date)
+	}
+	writeJSON(w, res)
+}
+
+// reveal は call を受け付けなくなった日の手札だけを公開します
+func (s *server) reveal(w http.ResponseWriter, r *http.Request) {
+	date := r.PathValue("date")
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.now().Before(t.AddDate(0, 0, 2)) {
+		http.Error(w, "daily puzzle is still open", http.StatusNotFound)
+		return
+	}
+	_, nonce := game.NewDailyCommitment(s.secret, date)
+	writeJSON(w, RevealResMsg{Date: date, Hand: game.NewDailyHand(s.secret, date).Msg(), Nonce: nonce})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
+)
+
+const (
+	// selfTestTimeout は自己診断で候補を集める時間です
+	selfTestTimeout = 5 * time.Second
+	// statsInterval は対局中に接続の品質を更新する間隔です
+	statsInterval = 2 * time.Second
+)
+
+// runSelfTest は STUN サーバへの到達性と NAT の種類を調べて、診断パネルに表示します
+// 結果は不具合の報告に使えるようにログにも残します
+func runSelfTest() {
+	button := getElementByID("diagnose")
+	button.Set("disabled", true)
+	defer button.Set("disabled", false)
+	setDiagnostics("diagnostics-selftest", "Testing connectivity ...")
+
+	result, err := ayame.SelfTest(nil, selfTestTimeout)
+	if err != nil {
+		logger.Error("failed to run self-test", "err", err)
+		setDiagnostics("diagnostics-selftest", "Self-test failed: "+err.Error())
+		return
+	}
+	logger.Info("self-test", "stun_reachable", result.STUNReachable, "nat", result.NAT, "duration", result.Duration)
+	for _, c := range result.Candidates {
+		logger.Debug("self-test candidate", "candidate", c)
+	}
+	lines := []string{
+		fmt.Sprintf("STUN: %s", reachableView(result.STUNReachable)),
+		fmt.Sprintf("NAT: %s", result.NAT),
+	}
+	if hint := natHint(result.NAT); hint != "" {
+		lines = append(lines, hint)
+	}
+	setDiagnostics("diagnostics-selftest", strings.Join(lines, "\n"))
+}
+
+// watchStats は active が true を返す間、c の接続の品質を診断パネルに表示します
+// 接続直後と終了時の品質はログにも残します
+func watchStats(c *ayame.Connection, active func() bool) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	var last *ayame.Stats
+	for logged := false; active(); <-ticker.C {
+		stats, err := c.Stats()
+		if err != nil {
+			logger.Error("failed to get stats", "err", err)
+			return
+		}
+		if !logged && stats.SelectedPair != nil {
+			logger.Info("connection stats", "stats", stats.String())
+			logged = true
+		}
+		setDiagnostics("diagnostics-stats", statsView(stats))
+		last = stats
+		if stats.ICEConnectionState == webrtc.ICEConnectionStateClosed || stats.ICEConnectionState == webrtc.ICEConnectionStateFailed {
+			break
+		}
+	}
+	if last != nil {
+		logger.Info("connection stats", "stats", last.String())
+	}
+}
+
+// showRelayStats は中継サーバを経由している場合の表示です
+// WebSocket には候補のペアがないので経路だけを表示します
+func showRelayStats() {
+	setDiagnostics("diagnostics-stats", "Path: relay server")
+}
+
+func statsView(stats *ayame.Stats) string {
+	lines := []string{
+		fmt.Sprintf("ICE: %s", stats.ICEConnectionState),
+		fmt.Sprintf("Path: %s", pathView(stats.Path())),
+	}
+	if p := stats.SelectedPair; p != nil {
+		lines = append(lines,
+			fmt.Sprintf("Candidates: %s -> %s (%s)", p.LocalType, p.RemoteType, p.Protocol),
+			fmt.Sprintf("RTT: %s", p.RTT.Round(time.Millisecond)))
+	}
+	lines = append(lines, fmt.Sprintf("Sent: %d B / Received: %d B", stats.BytesSent, stats.BytesReceived))
+	for _, dc := range stats.DataChannels {
+		lines = append(lines, fmt.Sprintf("DataChannel %s: %s, buffered %d B", dc.Label, dc.State, dc.BufferedAmount))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func reachableView(ok bool) string {
+	if ok {
+		return "reachable"
+	}
+	return "unreachable"
+}
+
+// natHint は NAT の種類から対局できるかの見込みを返します
+// ブラウザでは STUN の結果が一部隠されるため、推定の限界も合わせて表示します
+func natHint(nat ayame.NATType) string {
+	switch nat {
+	case ayame.NATSymmetric:
+		return "Direct P2P is unlikely to work, TURN or the relay server will be used\n" +
+			"(a device with two network interfaces behind one cone NAT is also shown as symmetric)"
+	case ayame.NATUnknown:
+		return "The browser merges identical STUN results, so a cone NAT is shown as unknown"
+	case ayame.NATBlocked:
+		return "UDP seems to be blocked, only the relay server may work"
+	}
+	return ""
+}
+
+func setDiagnostics(id, text string) {
+	getElementByID(id).Set("textContent", text)
+}
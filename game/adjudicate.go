@@ -0,0 +1,248 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type WinCondition int
+
+const (
+	// 後手にも同じターン数を与えてから判定する
+	EqualTurns WinCondition = iota
+	// 先に当てた方がその場で勝つ
+	FirstToCrack
+)
+
+var winConditionNames = map[WinCondition]string{
+	EqualTurns:   "equal",
+	FirstToCrack: "crack",
+}
+
+// Rules は勝敗判定のルールです
+// クライアント同士やサーバで同じ Rules と History から同じ結果を得られるよう、start で共有します
+type Rules struct {
+	winCondition WinCondition
+	// 0 のときは無制限
+	maxTurns int
+	// 規定ターン数に達したときに残り候補数の少ない方を勝ちとするか
+	tiebreakByCandidates bool
+}
+
+func NewRules(winCondition WinCondition, maxTurns int, tiebreakByCandidates bool) *Rules {
+	return &Rules{winCondition, maxTurns, tiebreakByCandidates}
+}
+
+func DefaultRules() *Rules {
+	return NewRules(EqualTurns, 8, false)
+}
+
+// NewRulesFromText は "equal/8", "crack/10/candidates" 形式の文字列を解釈します
+func NewRulesFromText(text string) (*Rules, error) {
+	parts := strings.Split(text, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid rules: %q", text)
+	}
+	winCondition := WinCondition(-1)
+	for w, n := range winConditionNames {
+		if n == parts[0] {
+			winCondition = w
+		}
+	}
+	if winCondition < 0 {
+		return nil, fmt.Errorf("unknown win condition: %q", parts[0])
+	}
+	maxTurns, err := strconv.Atoi(parts[1])
+	if err != nil || maxTurns < 0 {
+		return nil, fmt.Errorf("invalid max turns: %q", parts[1])
+	}
+	var tiebreak bool
+	if len(parts) == 3 {
+		if parts[2] != "candidates" {
+			return nil, fmt.Errorf("unknown tiebreak: %q", parts[2])
+		}
+		tiebreak = true
+	}
+	return NewRules(winCondition, maxTurns, tiebreak), nil
+}
+
+func (r *Rules) WinCondition() WinCondition {
+	return r.winCondition
+}
+
+func (r *Rules) MaxTurns() int {
+	return r.maxTurns
+}
+
+func (r *Rules) Msg() string {
+	msg := fmt.Sprintf("%s/%d", winConditionNames[r.winCondition], r.maxTurns)
+	if r.tiebreakByCandidates {
+		msg += "/candidates"
+	}
+	return msg
+}
+
+type event struct {
+	by          Turn
+	qa          *QA
+	endsTurn    bool
+	handChanged bool
+}
+
+// History は勝敗判定に必要な対局の経過です
+// Turn は観測者から見た手番で、サーバなど第三者は player1 を MyTurn として扱います
+type History struct {
+	events     []*event
+	forfeit    *Forfeit
+	drawAgreed bool
+}
+
+func NewHistory() *History {
+	return &History{
+		events: make([]*event, 0),
+	}
+}
+
+// AddCall は by 側の call を記録します
+// DOUBLE の1回目のようにターンを終えない call は endsTurn を false にします
+func (h *History) AddCall(by Turn, qa *QA, endsTurn bool) {
+	h.events = append(h.events, &event{by: by, qa: qa, endsTurn: endsTurn})
+}
+
+// AddHandChange は by 側が SHUFFLE や CHANGE で手札を変えたことを記録します
+func (h *History) AddHandChange(by Turn) {
+	h.events = append(h.events, &event{by: by, handChanged: true})
+}
+
+func (h *History) Forfeit(loser Turn, reason ForfeitReason) {
+	h.forfeit = &Forfeit{loser, reason}
+}
+
+func (h *History) AgreeDraw() {
+	h.drawAgreed = true
+}
+
+// Calls は by 側の call のうち、相手が最後に手札を変えた後のものを返します
+func (h *History) Calls(by Turn) []*QA {
+	qas := make([]*QA, 0)
+	for _, e := range h.events {
+		if e.handChanged && e.by != by {
+			qas = qas[:0]
+		}
+		if e.qa != nil && e.by == by {
+			qas = append(qas, e.qa)
+		}
+	}
+	return qas
+}
+
+type Reason int
+
+const (
+	Undecided Reason = iota
+	Cracked
+	BothCracked
+	TurnCap
+	FewerCandidates
+	ByTimeout
+	ByAbandon
+	ByResign
+	ByAgreement
+)
+
+// Judgement は MyTurn 側から見た判定結果です
+type Judgement struct {
+	status JudgeStatus
+	reason Reason
+}
+
+func (j *Judgement) Status() JudgeStatus {
+	return j.status
+}
+
+func (j *Judgement) Reason() Reason {
+	return j.reason
+}
+
+func winnerJudgement(winner Turn, reason Reason) *Judgement {
+	if winner == MyTurn {
+		return &Judgement{Win, reason}
+	}
+	return &Judgement{Lose, reason}
+}
+
+// Adjudicate は rules に従って history の勝敗を判定します
+func Adjudicate(rules *Rules, history *History) *Judgement {
+	if f := history.forfeit; f != nil {
+		reason := map[ForfeitReason]Reason{Timeout: ByTimeout, Abandon: ByAbandon, Resign: ByResign}[f.reason]
+		return winnerJudgement(f.loser.Reverse(), reason)
+	}
+	if history.drawAgreed {
+		return &Judgement{Draw, ByAgreement}
+	}
+	var turns [2]int
+	var cracked [2]bool
+	for _, e := range history.events {
+		if e.qa == nil {
+			continue
+		}
+		if e.qa.answer.IsAllHit() {
+			cracked[e.by] = true
+		}
+		if !e.endsTurn {
+			continue
+		}
+		turns[e.by]++
+		if rules.winCondition == FirstToCrack && cracked[e.by] {
+			return winnerJudgement(e.by, Cracked)
+		}
+		// 後手のターンが終わり、両者のターン数が揃った時点で判定する
+		if turns[MyTurn] != turns[OpTurn] {
+			continue
+		}
+		switch {
+		case cracked[MyTurn] && cracked[OpTurn]:
+			return &Judgement{Draw, BothCracked}
+		case cracked[MyTurn]:
+			return winnerJudgement(MyTurn, Cracked)
+		case cracked[OpTurn]:
+			return winnerJudgement(OpTurn, Cracked)
+		}
+		if rules.maxTurns > 0 && turns[MyTurn] >= rules.maxTurns {
+			return turnCapJudgement(rules, history)
+		}
+	}
+	return &Judgement{NotYet, Undecided}
+}
+
+func turnCapJudgement(rules *Rules, history *History) *Judgement {
+	if !rules.tiebreakByCandidates {
+		return &Judgement{Draw, TurnCap}
+	}
+	// 相手の手札の候補をより絞り込めている方の勝ち
+	my := CountCandidates(history.Calls(MyTurn))
+	op := CountCandidates(history.Calls(OpTurn))
+	switch {
+	case my < op:
+		return winnerJudgement(MyTurn, FewerCandidates)
+	case op < my:
+		return winnerJudgement(OpTurn, FewerCandidates)
+	}
+	return &Judgement{Draw, TurnCap}
+}
+
+// CountCandidates は qas の結果と矛盾しない手札の数を返します
+func CountCandidates(qas []*QA) int {
+	var count int
+	for _, hand := range allHands {
+		if slices.ContainsFunc(qas, func(qa *QA) bool {
+			return *hand.Answer(qa.guess) != *qa.answer
+		}) {
+			continue
+		}
+		count++
+	}
+	return count
+}
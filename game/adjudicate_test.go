@@ -0,0 +1,248 @@
+package game
+
+import "testing"
+
+// step は History に記録する1回の call です
+type step struct {
+	by       Turn
+	guess    string
+	hit      int
+	blow     int
+	keepTurn bool
+}
+
+func miss(by Turn) step {
+	return step{by: by, guess: "012"}
+}
+
+func crack(by Turn) step {
+	return step{by: by, guess: "345", hit: 3}
+}
+
+func newTestHistory(steps []step) *History {
+	h := NewHistory()
+	for _, s := range steps {
+		h.AddCall(s.by, NewQA(NewGuessFromText(s.guess), NewAnswer(s.hit, s.blow)), !s.keepTurn)
+	}
+	return h
+}
+
+func TestAdjudicate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   *Rules
+		steps   []step
+		forfeit *Forfeit
+		draw    bool
+		status  JudgeStatus
+		reason  Reason
+	}{
+		{
+			name:   "no calls yet",
+			rules:  DefaultRules(),
+			status: NotYet,
+			reason: Undecided,
+		},
+		{
+			name:   "first to crack wins on the spot",
+			rules:  NewRules(FirstToCrack, 8, false),
+			steps:  []step{crack(MyTurn)},
+			status: Win,
+			reason: Cracked,
+		},
+		{
+			name:   "first to crack by the second player",
+			rules:  NewRules(FirstToCrack, 8, false),
+			steps:  []step{miss(MyTurn), crack(OpTurn)},
+			status: Lose,
+			reason: Cracked,
+		},
+		{
+			name:   "first to crack ignores calls after the crack",
+			rules:  NewRules(FirstToCrack, 8, false),
+			steps:  []step{miss(OpTurn), crack(MyTurn), crack(OpTurn)},
+			status: Win,
+			reason: Cracked,
+		},
+		{
+			name:   "equal turns waits for the reply turn",
+			rules:  NewRules(EqualTurns, 8, false),
+			steps:  []step{crack(MyTurn)},
+			status: NotYet,
+			reason: Undecided,
+		},
+		{
+			name:   "equal turns wins when the reply misses",
+			rules:  NewRules(EqualTurns, 8, false),
+			steps:  []step{crack(MyTurn), miss(OpTurn)},
+			status: Win,
+			reason: Cracked,
+		},
+		{
+			name:   "equal turns when only the second player cracks",
+			rules:  NewRules(EqualTurns, 8, false),
+			steps:  []step{miss(MyTurn), crack(OpTurn)},
+			status: Lose,
+			reason: Cracked,
+		},
+		{
+			name:   "draw when both crack on equal turns",
+			rules:  NewRules(EqualTurns, 8, false),
+			steps:  []step{miss(MyTurn), miss(OpTurn), crack(MyTurn), crack(OpTurn)},
+			status: Draw,
+			reason: BothCracked,
+		},
+		{
+			name:   "a call that keeps the turn does not count as a turn",
+			rules:  NewRules(EqualTurns, 8, false),
+			steps:  []step{{by: MyTurn, guess: "012", keepTurn: true}, crack(MyTurn), crack(OpTurn)},
+			status: Draw,
+			reason: BothCracked,
+		},
+		{
+			name:   "turn cap without tiebreak is a draw",
+			rules:  NewRules(EqualTurns, 2, false),
+			steps:  []step{miss(MyTurn), miss(OpTurn), miss(MyTurn), miss(OpTurn)},
+			status: Draw,
+			reason: TurnCap,
+		},
+		{
+			name:   "turn cap is not reached before the reply turn",
+			rules:  NewRules(EqualTurns, 2, false),
+			steps:  []step{miss(MyTurn), miss(OpTurn), miss(MyTurn)},
+			status: NotYet,
+			reason: Undecided,
+		},
+		{
+			name:   "crack on the last turn beats the turn cap",
+			rules:  NewRules(EqualTurns, 1, true),
+			steps:  []step{crack(MyTurn), miss(OpTurn)},
+			status: Win,
+			reason: Cracked,
+		},
+		{
+			// 0 hit 0 blow の方が 0 hit 1 blow より候補を絞り込める
+			name:   "turn cap tiebreak by fewer candidates",
+			rules:  NewRules(EqualTurns, 1, true),
+			steps:  []step{{by: MyTurn, guess: "012"}, {by: OpTurn, guess: "012", blow: 1}},
+			status: Win,
+			reason: FewerCandidates,
+		},
+		{
+			name:   "turn cap tiebreak for the opponent",
+			rules:  NewRules(EqualTurns, 1, true),
+			steps:  []step{{by: MyTurn, guess: "012", blow: 1}, {by: OpTurn, guess: "012"}},
+			status: Lose,
+			reason: FewerCandidates,
+		},
+		{
+			name:   "turn cap tiebreak with equal candidates is a draw",
+			rules:  NewRules(EqualTurns, 1, true),
+			steps:  []step{miss(MyTurn), miss(OpTurn)},
+			status: Draw,
+			reason: TurnCap,
+		},
+		{
+			name:   "unlimited turns never reach the cap",
+			rules:  NewRules(EqualTurns, 0, true),
+			steps:  []step{miss(MyTurn), miss(OpTurn), miss(MyTurn), miss(OpTurn)},
+			status: NotYet,
+			reason: Undecided,
+		},
+		{
+			name:    "timeout overrides a crack",
+			rules:   NewRules(FirstToCrack, 8, false),
+			steps:   []step{crack(MyTurn)},
+			forfeit: &Forfeit{MyTurn, Timeout},
+			status:  Lose,
+			reason:  ByTimeout,
+		},
+		{
+			name:    "abandon overrides a turn cap",
+			rules:   NewRules(EqualTurns, 1, false),
+			steps:   []step{miss(MyTurn), miss(OpTurn)},
+			forfeit: &Forfeit{OpTurn, Abandon},
+			status:  Win,
+			reason:  ByAbandon,
+		},
+		{
+			name:    "resign overrides both cracking",
+			rules:   NewRules(EqualTurns, 8, false),
+			steps:   []step{crack(MyTurn), crack(OpTurn)},
+			forfeit: &Forfeit{OpTurn, Resign},
+			status:  Win,
+			reason:  ByResign,
+		},
+		{
+			name:    "forfeit overrides an agreed draw",
+			rules:   DefaultRules(),
+			forfeit: &Forfeit{MyTurn, Resign},
+			draw:    true,
+			status:  Lose,
+			reason:  ByResign,
+		},
+		{
+			name:   "agreed draw",
+			rules:  DefaultRules(),
+			steps:  []step{crack(MyTurn)},
+			draw:   true,
+			status: Draw,
+			reason: ByAgreement,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistory(tt.steps)
+			if tt.forfeit != nil {
+				h.Forfeit(tt.forfeit.loser, tt.forfeit.reason)
+			}
+			if tt.draw {
+				h.AgreeDraw()
+			}
+			j := Adjudicate(tt.rules, h)
+			if j.Status() != tt.status || j.Reason() != tt.reason {
+				t.Errorf("Adjudicate() = (%v, %v), want (%v, %v)", j.Status(), j.Reason(), tt.status, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewRulesFromText(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr bool
+		want    *Rules
+	}{
+		{text: "equal/8", want: NewRules(EqualTurns, 8, false)},
+		{text: "crack/10/candidates", want: NewRules(FirstToCrack, 10, true)},
+		{text: "equal/0", want: NewRules(EqualTurns, 0, false)},
+		{text: "", wantErr: true},
+		{text: "equal", wantErr: true},
+		{text: "equal/8/candidates/extra", wantErr: true},
+		{text: "sudden/8", wantErr: true},
+		{text: "equal/-1", wantErr: true},
+		{text: "equal/eight", wantErr: true},
+		{text: "equal/8/fewest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			rules, err := NewRulesFromText(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewRulesFromText(%q) = %+v, want error", tt.text, rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRulesFromText(%q) error: %v", tt.text, err)
+			}
+			if *rules != *tt.want {
+				t.Errorf("NewRulesFromText(%q) = %+v, want %+v", tt.text, rules, tt.want)
+			}
+			// start で送った Rules は相手側で同じ Rules に戻る
+			if back, err := NewRulesFromText(rules.Msg()); err != nil || *back != *rules {
+				t.Errorf("NewRulesFromText(%q) = %+v, %v, want %+v", rules.Msg(), back, err, rules)
+			}
+		})
+	}
+}
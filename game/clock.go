@@ -0,0 +1,188 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TimeControlKind int
+
+const (
+	// 1手ごとの制限時間
+	PerMove TimeControlKind = iota
+	// 1局の持ち時間(切れ負け)
+	SuddenDeath
+	// 1手ごとに加算
+	Fischer
+	// 使った時間を上限つきで返却
+	Bronstein
+)
+
+var timeControlKindNames = map[TimeControlKind]string{
+	PerMove:     "move",
+	SuddenDeath: "bank",
+	Fischer:     "fischer",
+	Bronstein:   "bronstein",
+}
+
+type TimeControl struct {
+	kind      TimeControlKind
+	base      time.Duration
+	increment time.Duration
+}
+
+func NewTimeControl(kind TimeControlKind, base, increment time.Duration) *TimeControl {
+	return &TimeControl{kind, base, increment}
+}
+
+func DefaultTimeControl() *TimeControl {
+	return NewTimeControl(PerMove, 60*time.Second, 0)
+}
+
+// NewTimeControlFromText は "move:60", "bank:300", "fischer:180+5", "bronstein:180+5" 形式の文字列を解釈します
+func NewTimeControlFromText(text string) (*TimeControl, error) {
+	name, spec, ok := strings.Cut(text, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid time control: %q", text)
+	}
+	kind := TimeControlKind(-1)
+	for k, n := range timeControlKindNames {
+		if n == name {
+			kind = k
+		}
+	}
+	if kind < 0 {
+		return nil, fmt.Errorf("unknown time control: %q", name)
+	}
+	baseStr, incStr, hasInc := strings.Cut(spec, "+")
+	base, err := strconv.Atoi(baseStr)
+	if err != nil || base <= 0 {
+		return nil, fmt.Errorf("invalid base time: %q", baseStr)
+	}
+	var inc int
+	if hasInc {
+		inc, err = strconv.Atoi(incStr)
+		if err != nil || inc < 0 {
+			return nil, fmt.Errorf("invalid increment: %q", incStr)
+		}
+	}
+	if (kind == PerMove || kind == SuddenDeath) && inc != 0 {
+		return nil, fmt.Errorf("%s does not take an increment", name)
+	}
+	return NewTimeControl(kind, time.Duration(base)*time.Second, time.Duration(inc)*time.Second), nil
+}
+
+func (tc *TimeControl) Kind() TimeControlKind {
+	return tc.kind
+}
+
+func (tc *TimeControl) Base() time.Duration {
+	return tc.base
+}
+
+func (tc *TimeControl) Increment() time.Duration {
+	return tc.increment
+}
+
+func (tc *TimeControl) Msg() string {
+	msg := fmt.Sprintf("%s:%d", timeControlKindNames[tc.kind], int(tc.base/time.Second))
+	if tc.kind == Fischer || tc.kind == Bronstein {
+		msg += fmt.Sprintf("+%d", int(tc.increment/time.Second))
+	}
+	return msg
+}
+
+// Clock は両プレイヤーの持ち時間を管理する対局時計です
+// 双方のクライアントが同じ TimeControl で同じ Clock を動かすことで、どちらの側でも時間切れを判定できます
+type Clock struct {
+	tc        *TimeControl
+	remaining [2]time.Duration
+	used      [2]time.Duration
+	turn      Turn
+	startedAt time.Time
+	running   bool
+}
+
+func NewClock(tc *TimeControl) *Clock {
+	return &Clock{
+		tc:        tc,
+		remaining: [2]time.Duration{tc.base, tc.base},
+	}
+}
+
+func (c *Clock) TimeControl() *TimeControl {
+	return c.tc
+}
+
+func (c *Clock) Start(turn Turn, now time.Time) {
+	c.turn = turn
+	c.startedAt = now
+	c.running = true
+	if c.tc.kind == PerMove {
+		c.remaining[turn] = c.tc.base
+	}
+}
+
+// Press は手番プレイヤーの着手を確定し、相手の時計を動かします
+// 持ち時間を超過していた場合はその超過時間を返します
+func (c *Clock) Press(now time.Time) time.Duration {
+	if !c.running {
+		return 0
+	}
+	elapsed := now.Sub(c.startedAt)
+	overrun := elapsed - c.remaining[c.turn]
+	c.used[c.turn] += elapsed
+	c.remaining[c.turn] = max(c.remaining[c.turn]-elapsed, 0)
+	// 時間切れになった後の着手では加算せず、持ち時間が戻らないようにする
+	if overrun < 0 {
+		switch c.tc.kind {
+		case Fischer:
+			c.remaining[c.turn] += c.tc.increment
+		case Bronstein:
+			c.remaining[c.turn] += min(elapsed, c.tc.increment)
+		}
+	}
+	c.Start(c.turn.Reverse(), now)
+	return max(overrun, 0)
+}
+
+func (c *Clock) Stop(now time.Time) {
+	if !c.running {
+		return
+	}
+	c.remaining[c.turn] = max(c.remaining[c.turn]-now.Sub(c.startedAt), 0)
+	c.used[c.turn] += now.Sub(c.startedAt)
+	c.running = false
+}
+
+// Used は turn 側がこれまでに考慮に使った時間です
+func (c *Clock) Used(turn Turn, now time.Time) time.Duration {
+	used := c.used[turn]
+	if c.running && c.turn == turn {
+		used += now.Sub(c.startedAt)
+	}
+	return used
+}
+
+func (c *Clock) Remaining(turn Turn, now time.Time) time.Duration {
+	remaining := c.remaining[turn]
+	if c.running && c.turn == turn {
+		remaining -= now.Sub(c.startedAt)
+	}
+	return max(remaining, 0)
+}
+
+// Overrun は turn 側が持ち時間を超過している時間を返します
+func (c *Clock) Overrun(turn Turn, now time.Time) time.Duration {
+	overrun := -c.remaining[turn]
+	if c.running && c.turn == turn {
+		overrun += now.Sub(c.startedAt)
+	}
+	return max(overrun, 0)
+}
+
+func (c *Clock) IsFlagged(turn Turn, now time.Time) bool {
+	return c.Remaining(turn, now) <= 0
+}
@@ -0,0 +1,166 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockPress(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		tc   *TimeControl
+		// moves は手番ごとに使った時間で、MyTurn から交互に着手します
+		moves         []time.Duration
+		wantRemaining [2]time.Duration
+		wantOverrun   time.Duration
+	}{
+		{
+			name:          "per move resets when the turn starts",
+			tc:            NewTimeControl(PerMove, 60*time.Second, 0),
+			moves:         []time.Duration{50 * time.Second, 10 * time.Second, 30 * time.Second},
+			wantRemaining: [2]time.Duration{30 * time.Second, 60 * time.Second},
+		},
+		{
+			name:          "per move overrun",
+			tc:            NewTimeControl(PerMove, 60*time.Second, 0),
+			moves:         []time.Duration{65 * time.Second},
+			wantRemaining: [2]time.Duration{0, 60 * time.Second},
+			wantOverrun:   5 * time.Second,
+		},
+		{
+			name:          "sudden death keeps the bank",
+			tc:            NewTimeControl(SuddenDeath, 300*time.Second, 0),
+			moves:         []time.Duration{100 * time.Second, 40 * time.Second, 50 * time.Second},
+			wantRemaining: [2]time.Duration{150 * time.Second, 260 * time.Second},
+		},
+		{
+			name:          "sudden death overrun",
+			tc:            NewTimeControl(SuddenDeath, 300*time.Second, 0),
+			moves:         []time.Duration{310 * time.Second},
+			wantRemaining: [2]time.Duration{0, 300 * time.Second},
+			wantOverrun:   10 * time.Second,
+		},
+		{
+			name:          "fischer adds the increment",
+			tc:            NewTimeControl(Fischer, 180*time.Second, 5*time.Second),
+			moves:         []time.Duration{2 * time.Second, 30 * time.Second},
+			wantRemaining: [2]time.Duration{183 * time.Second, 155 * time.Second},
+		},
+		{
+			name:          "fischer does not add the increment after flagging",
+			tc:            NewTimeControl(Fischer, 180*time.Second, 5*time.Second),
+			moves:         []time.Duration{181 * time.Second},
+			wantRemaining: [2]time.Duration{0, 180 * time.Second},
+			wantOverrun:   time.Second,
+		},
+		{
+			name:          "fischer does not add the increment on the exact flag",
+			tc:            NewTimeControl(Fischer, 180*time.Second, 5*time.Second),
+			moves:         []time.Duration{180 * time.Second},
+			wantRemaining: [2]time.Duration{0, 180 * time.Second},
+		},
+		{
+			name:          "bronstein returns the used time up to the increment",
+			tc:            NewTimeControl(Bronstein, 180*time.Second, 5*time.Second),
+			moves:         []time.Duration{2 * time.Second, 30 * time.Second},
+			wantRemaining: [2]time.Duration{180 * time.Second, 155 * time.Second},
+		},
+		{
+			name:          "bronstein does not return time after flagging",
+			tc:            NewTimeControl(Bronstein, 180*time.Second, 5*time.Second),
+			moves:         []time.Duration{182 * time.Second},
+			wantRemaining: [2]time.Duration{0, 180 * time.Second},
+			wantOverrun:   2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClock(tt.tc)
+			now := start
+			c.Start(MyTurn, now)
+			var overrun time.Duration
+			for _, move := range tt.moves {
+				now = now.Add(move)
+				overrun = c.Press(now)
+			}
+			c.Stop(now)
+			for _, turn := range []Turn{MyTurn, OpTurn} {
+				if got := c.Remaining(turn, now); got != tt.wantRemaining[turn] {
+					t.Errorf("Remaining(%d) = %s, want %s", turn, got, tt.wantRemaining[turn])
+				}
+			}
+			if overrun != tt.wantOverrun {
+				t.Errorf("Press() = %s, want %s", overrun, tt.wantOverrun)
+			}
+		})
+	}
+}
+
+func TestClockFlag(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(NewTimeControl(Fischer, 10*time.Second, 5*time.Second))
+	c.Start(MyTurn, start)
+	now := start.Add(12 * time.Second)
+	if !c.IsFlagged(MyTurn, now) {
+		t.Fatal("IsFlagged(MyTurn) = false before pressing, want true")
+	}
+	if got := c.Overrun(MyTurn, now); got != 2*time.Second {
+		t.Errorf("Overrun(MyTurn) = %s, want 2s", got)
+	}
+	c.Press(now)
+	if !c.IsFlagged(MyTurn, now) {
+		t.Error("IsFlagged(MyTurn) = false after pressing late, want true")
+	}
+	if c.IsFlagged(OpTurn, now) {
+		t.Error("IsFlagged(OpTurn) = true, want false")
+	}
+}
+
+func TestNewTimeControlFromText(t *testing.T) {
+	tests := []struct {
+		text      string
+		wantErr   bool
+		kind      TimeControlKind
+		base      time.Duration
+		increment time.Duration
+	}{
+		{text: "move:60", kind: PerMove, base: 60 * time.Second},
+		{text: "bank:300", kind: SuddenDeath, base: 300 * time.Second},
+		{text: "fischer:180+5", kind: Fischer, base: 180 * time.Second, increment: 5 * time.Second},
+		{text: "bronstein:180+5", kind: Bronstein, base: 180 * time.Second, increment: 5 * time.Second},
+		{text: "fischer:180", kind: Fischer, base: 180 * time.Second},
+		{text: "", wantErr: true},
+		{text: "move", wantErr: true},
+		{text: "blitz:60", wantErr: true},
+		{text: "move:0", wantErr: true},
+		{text: "move:-1", wantErr: true},
+		{text: "move:abc", wantErr: true},
+		{text: "move:60+5", wantErr: true},
+		{text: "bank:300+1", wantErr: true},
+		{text: "fischer:180+x", wantErr: true},
+		{text: "fischer:180+-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tc, err := NewTimeControlFromText(tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewTimeControlFromText(%q) = %+v, want error", tt.text, tc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTimeControlFromText(%q) error: %v", tt.text, err)
+			}
+			if tc.Kind() != tt.kind || tc.Base() != tt.base || tc.Increment() != tt.increment {
+				t.Errorf("NewTimeControlFromText(%q) = %s %s %s, want %s %s %s", tt.text, timeControlKindNames[tc.Kind()], tc.Base(), tc.Increment(), timeControlKindNames[tt.kind], tt.base, tt.increment)
+			}
+			// Msg で送った設定は相手側で同じ TimeControl に戻る
+			back, err := NewTimeControlFromText(tc.Msg())
+			if err != nil || *back != *tc {
+				t.Errorf("NewTimeControlFromText(%q) = %+v, %v, want %+v", tc.Msg(), back, err, tc)
+			}
+		})
+	}
+}
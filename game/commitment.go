@@ -0,0 +1,82 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// NewCommitment は hand を明かさずに固定するためのコミットメントと、公開時に添えるノンスを返します
+func NewCommitment(hand *Hand) (string, string) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	nonce := hex.EncodeToString(b)
+	return commitment(hand, nonce), nonce
+}
+
+func commitment(hand *Hand, nonce string) string {
+	sum := sha256.Sum256([]byte(hand.Msg() + ":" + nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCommitment は公開された hand と nonce が c と一致するかを返します
+func VerifyCommitment(c string, hand *Hand, nonce string) bool {
+	return c != "" && commitment(hand, nonce) == c
+}
+
+// Commitment は turn 側の対局開始時の手札のコミットメントを返します
+func (b *Board) Commitment(turn Turn) string {
+	return b.commitments[turn]
+}
+
+// SetOpCommitment は相手から受け取ったコミットメントを記録します
+func (b *Board) SetOpCommitment(c string) {
+	b.commitments[OpTurn] = c
+}
+
+// Nonce は自分のコミットメントのノンスで、終局後に手札と一緒に公開します
+func (b *Board) Nonce() string {
+	return b.nonce
+}
+
+// HandChanged は turn 側が SHUFFLE や CHANGE で手札を変えたかを返します
+// 変えた場合、公開された手札はコミットメントと一致しません
+func (h *History) HandChanged(turn Turn) bool {
+	for _, e := range h.events {
+		if e.handChanged && e.by == turn {
+			return true
+		}
+	}
+	return false
+}
+
+// Digest は player1 から見た対局の経過のハッシュです
+// 両者が同じ経過を記録していれば、手番の向きによらず同じ値になります
+func (h *History) Digest(pNum int) string {
+	player := func(by Turn) int {
+		if pNum == 1 && by == MyTurn || pNum == 2 && by == OpTurn {
+			return 1
+		}
+		return 2
+	}
+	var sb strings.Builder
+	for _, e := range h.events {
+		if e.handChanged {
+			fmt.Fprintf(&sb, "p%d:change\n", player(e.by))
+			continue
+		}
+		fmt.Fprintf(&sb, "p%d:%s:%s:%t\n", player(e.by), e.qa.guess.Msg(), e.qa.answer.Msg(), e.endsTurn)
+	}
+	if f := h.forfeit; f != nil {
+		fmt.Fprintf(&sb, "p%d:forfeit:%d\n", player(f.loser), f.reason)
+	}
+	if h.drawAgreed {
+		sb.WriteString("draw\n")
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	MinCoopPlayers = 2
+	MaxCoopPlayers = 4
+)
+
+type CoopCall struct {
+	by string
+	qa *QA
+}
+
+func (c *CoopCall) By() string {
+	return c.by
+}
+
+func (c *CoopCall) QA() *QA {
+	return c.qa
+}
+
+func (c *CoopCall) View() string {
+	return fmt.Sprintf("%s: %s (%s)", c.by, c.qa.guess.View(), c.qa.answer.Msg())
+}
+
+// Coop は2〜4人のチームが交代で call し、ディーラーの手札を当てる協力プレイの盤面です
+// ディーラー(ホストのクライアント)だけが手札を持ち、Hand.Answer で回答します
+// スコアはチーム全体の call 数で、少ないほど良い結果です
+type Coop struct {
+	state   State
+	me      string
+	secret  *Hand
+	players []string
+	turn    int
+	guess   *Guess
+	calls   []*CoopCall
+}
+
+func NewCoop(me string) *Coop {
+	return &Coop{
+		state: InMenu,
+		me:    me,
+		calls: make([]*CoopCall, 0),
+	}
+}
+
+// Start は ids の順に手番を回して協力プレイを始めます
+// secret はディーラーだけが渡し、他のプレイヤーは nil を渡します
+func (c *Coop) Start(ids []string, secret *Hand) error {
+	if len(ids) < MinCoopPlayers || len(ids) > MaxCoopPlayers {
+		return fmt.Errorf("co-op needs %d-%d players: %d", MinCoopPlayers, MaxCoopPlayers, len(ids))
+	}
+	if secret != nil && !secret.IsValid() {
+		return fmt.Errorf("invalid secret: %v", *secret)
+	}
+	c.players = slices.Clone(ids)
+	c.secret = secret
+	c.turn = 0
+	c.state = Playing
+	return nil
+}
+
+func (c *Coop) IsPlaying() bool {
+	return c.state == Playing
+}
+
+func (c *Coop) IsFinished() bool {
+	return c.state == Finished
+}
+
+func (c *Coop) IsDealer() bool {
+	return c.secret != nil
+}
+
+func (c *Coop) Players() []string {
+	return c.players
+}
+
+func (c *Coop) Calls() []*CoopCall {
+	return c.calls
+}
+
+func (c *Coop) CurrentPlayer() string {
+	return c.players[c.turn]
+}
+
+func (c *Coop) IsMyTurn() bool {
+	return c.IsPlaying() && c.CurrentPlayer() == c.me && c.guess == nil
+}
+
+// Guess は by の call を記録し、ディーラーの回答を待ちます
+func (c *Coop) Guess(by string, guess *Guess) error {
+	if !c.IsPlaying() || c.CurrentPlayer() != by || c.guess != nil {
+		return fmt.Errorf("it is not %s's turn", by)
+	}
+	if !NewHand(*guess).IsValid() {
+		return fmt.Errorf("invalid guess: %v", *guess)
+	}
+	c.guess = guess
+	return nil
+}
+
+// CalcAnswer はディーラーの手札で直前の call に回答します
+func (c *Coop) CalcAnswer() (*Answer, error) {
+	if !c.IsDealer() {
+		return nil, fmt.Errorf("%s is not the dealer", c.me)
+	}
+	if c.guess == nil {
+		return nil, fmt.Errorf("no guess to answer")
+	}
+	return c.secret.Answer(c.guess), nil
+}
+
+// Answer はディーラーの回答を記録し、当たれば終局、外れれば次のプレイヤーに手番を回します
+func (c *Coop) Answer(answer *Answer) error {
+	if c.guess == nil {
+		return fmt.Errorf("no guess to answer")
+	}
+	c.calls = append(c.calls, &CoopCall{c.CurrentPlayer(), NewQA(c.guess, answer)})
+	c.guess = nil
+	if answer.IsAllHit() {
+		c.state = Finished
+		return nil
+	}
+	c.turn = (c.turn + 1) % len(c.players)
+	return nil
+}
+
+// Leave は id をチームから外します
+// 手番のプレイヤーが抜けた場合は call を取り消して次のプレイヤーに回します
+func (c *Coop) Leave(id string) {
+	i := slices.Index(c.players, id)
+	if i < 0 || !c.IsPlaying() {
+		return
+	}
+	if i == c.turn {
+		c.guess = nil
+	}
+	c.players = slices.Delete(c.players, i, i+1)
+	if len(c.players) == 0 {
+		c.state = Finished
+		return
+	}
+	if i < c.turn {
+		c.turn--
+	}
+	c.turn %= len(c.players)
+}
+
+// Score はチームの call 数を返します
+func (c *Coop) Score() int {
+	return len(c.calls)
+}
+
+// Candidates はチームの call の結果と矛盾しない手札の数を返します
+func (c *Coop) Candidates() int {
+	qas := make([]*QA, len(c.calls))
+	for i, call := range c.calls {
+		qas[i] = call.qa
+	}
+	return CountCandidates(qas)
+}
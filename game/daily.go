@@ -0,0 +1,125 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dailyDateLayout = "2006-01-02"
+
+// DailyDate は日付の切り替わりを全員で揃えるため UTC の日付を返します
+func DailyDate(t time.Time) string {
+	return t.UTC().Format(dailyDateLayout)
+}
+
+// NewDailyHand は secret をキーにした date の HMAC から、その日の手札を決めます
+// 同じ日なら誰でも同じ手札になり、secret を知らなければ手札を予測できません
+func NewDailyHand(secret, date string) *Hand {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(date))
+	seed := binary.BigEndian.Uint64(mac.Sum(nil))
+	return NewHandBySeed(int(seed % numOfAllHandsPatturn))
+}
+
+// NewDailyCommitment は date の手札のコミットメントとノンスを返します
+// ノンスも secret から決めるので、サーバを再起動しても同じ日なら同じコミットメントになります
+func NewDailyCommitment(secret, date string) (string, string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("nonce:" + date))
+	nonce := hex.EncodeToString(mac.Sum(nil)[:16])
+	return commitment(NewDailyHand(secret, date), nonce), nonce
+}
+
+// Daily は日替わりの手札を1人で当てるパズルです
+// 手札はサーバだけが知っていて、クライアントはサーバの回答を記録します
+type Daily struct {
+	state      State
+	date       string
+	commitment string
+	qas        []*QA
+}
+
+func NewDaily(date, commitment string) *Daily {
+	return &Daily{
+		state:      Playing,
+		date:       date,
+		commitment: commitment,
+		qas:        make([]*QA, 0),
+	}
+}
+
+func (d *Daily) Date() string {
+	return d.date
+}
+
+func (d *Daily) IsPlaying() bool {
+	return d.state == Playing
+}
+
+func (d *Daily) IsSolved() bool {
+	return d.state == Finished
+}
+
+func (d *Daily) QAs() []*QA {
+	return d.qas
+}
+
+// CanGuess はサーバに送る前に guess を確かめます
+func (d *Daily) CanGuess(guess *Guess) error {
+	if !d.IsPlaying() {
+		return fmt.Errorf("daily puzzle is already solved")
+	}
+	if !NewHand(*guess).IsValid() {
+		return fmt.Errorf("invalid guess: %v", *guess)
+	}
+	return nil
+}
+
+// Record はサーバの回答を記録し、当たれば終了します
+// 当たった場合はサーバが返したノンスで、その日の手札がコミットメントどおりだったかを確かめます
+func (d *Daily) Record(guess *Guess, answer *Answer, nonce string) error {
+	if err := d.CanGuess(guess); err != nil {
+		return err
+	}
+	if answer.IsAllHit() && !VerifyCommitment(d.commitment, NewHand(*guess), nonce) {
+		return fmt.Errorf("daily hand does not match the commitment")
+	}
+	d.qas = append(d.qas, NewQA(guess, answer))
+	if answer.IsAllHit() {
+		d.state = Finished
+	}
+	return nil
+}
+
+// Share は手札の数字を伏せ、各 call の hit を🟩、blow を🟨、はずれを⬜で並べた共有用の結果を返します
+func (d *Daily) Share() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Hit&Blow Daily %s %d\n", d.date, len(d.qas))
+	for _, qa := range d.qas {
+		sb.WriteString(strings.Repeat("🟩", qa.answer.hit))
+		sb.WriteString(strings.Repeat("🟨", qa.answer.blow))
+		sb.WriteString(strings.Repeat("⬜", numOfDigits-qa.answer.hit-qa.answer.blow))
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// NextStreak は前回クリアした日付 last と連続日数 streak から、date にクリアした後の連続日数を返します
+func NextStreak(last string, streak int, date string) int {
+	if last == date {
+		return streak
+	}
+	d, err := time.Parse(dailyDateLayout, date)
+	if err != nil {
+		return 1
+	}
+	if last == d.AddDate(0, 0, -1).Format(dailyDateLayout) {
+		return streak + 1
+	}
+	return 1
+}
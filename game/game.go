@@ -2,7 +2,7 @@ package game
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
 	"time"
 
@@ -143,6 +143,23 @@ func NewQA(guess *Guess, answer *Answer) *QA {
 	return &QA{guess, answer}
 }
 
+// LogValue はログに出力する QA の値です
+func (qa *QA) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("guess", qa.guess.Msg()),
+		slog.Int("hit", qa.answer.Hit()),
+		slog.Int("blow", qa.answer.Blow()),
+	)
+}
+
+func (qa *QA) Guess() *Guess {
+	return qa.guess
+}
+
+func (qa *QA) Answer() *Answer {
+	return qa.answer
+}
+
 type Board struct {
 	state       State
 	initTurn    Turn
@@ -153,16 +170,53 @@ type Board struct {
 	opQA        []*QA
 	myTurnCount int
 	opTurnCount int
+	clock       *Clock
+	rules       *Rules
+	history     *History
+	round       int
+	done        chan struct{}
+	variant     Variant
+	usedItems   [2]map[Item]bool
+	itemLog     []*ItemUse
+	double      *Turn
+	commitments [2]string
+	nonce       string
 }
 
 func NewBoard() *Board {
 	return &Board{
-		state: InMenu,
-		myQA:  make([]*QA, 0),
-		opQA:  make([]*QA, 0),
+		state:     InMenu,
+		myQA:      make([]*QA, 0),
+		opQA:      make([]*QA, 0),
+		rules:     DefaultRules(),
+		history:   NewHistory(),
+		round:     1,
+		usedItems: [2]map[Item]bool{{}, {}},
+		itemLog:   make([]*ItemUse, 0),
 	}
 }
 
+// Rematch は同じ相手との次の対局に向けて盤面を初期化し、先後を入れ替えた初手番を返します
+func (b *Board) Rematch() Turn {
+	next, round, pNum, variant, rules := b.initTurn.Reverse(), b.round+1, b.pNum, b.variant, b.rules
+	*b = *NewBoard()
+	b.round, b.pNum, b.variant, b.rules = round, pNum, variant, rules
+	return next
+}
+
+func (b *Board) Round() int {
+	return b.round
+}
+
+// Done は終局時に close されるチャネルを返します
+func (b *Board) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *Board) IsFinished() bool {
+	return b.state == Finished
+}
+
 func (b *Board) IsInMenu() bool {
 	return b.state == InMenu
 }
@@ -179,6 +233,10 @@ func (b *Board) IsOpTurn() bool {
 	return b.turn == OpTurn
 }
 
+func (b *Board) InitTurn() Turn {
+	return b.initTurn
+}
+
 func (b *Board) IsMyTurnInit() bool {
 	return b.initTurn == MyTurn
 }
@@ -187,8 +245,11 @@ func (b *Board) IsOpTurnInit() bool {
 	return b.initTurn == OpTurn
 }
 
-func (b *Board) ToggleTurn() {
+// ToggleTurn は手番を交代します
+// 手番だった側が通信遅延の許容範囲を超えて持ち時間を使い切っていた場合は false を返します
+func (b *Board) ToggleTurn() bool {
 	b.turn = b.turn.Reverse()
+	return b.clock.Press(time.Now()) <= flagTolerance
 }
 
 func (b *Board) CountTurn() {
@@ -207,11 +268,113 @@ func (b *Board) TurnCount() int {
 	return b.opTurnCount
 }
 
-func (b *Board) Start(hand *Hand, initTurn Turn, pNum int) {
+// GuessCount は turn 側の call 数を返します
+// DOUBLE を使うとターン数より多くなります
+func (b *Board) GuessCount(turn Turn) int {
+	if turn == MyTurn {
+		return len(b.myQA)
+	}
+	return len(b.opQA)
+}
+
+func (b *Board) Start(hand *Hand, initTurn Turn, pNum int, tc *TimeControl) {
 	b.state = Playing
 	b.initTurn, b.turn = initTurn, initTurn
 	b.myHand = hand
+	b.commitments[MyTurn], b.nonce = NewCommitment(hand)
 	b.pNum = pNum
+	b.clock = NewClock(tc)
+	b.done = make(chan struct{})
+}
+
+// StartClock は対局時計を動かし始めます
+// 開室者は start 送信直後、非開室者は start 受信直後に呼び出すことで双方の時計を揃えます
+func (b *Board) StartClock() {
+	b.clock.Start(b.initTurn, time.Now())
+}
+
+func (b *Board) TimeControl() *TimeControl {
+	return b.clock.TimeControl()
+}
+
+func (b *Board) Remaining(turn Turn) time.Duration {
+	return b.clock.Remaining(turn, time.Now())
+}
+
+func (b *Board) Used(turn Turn) time.Duration {
+	return b.clock.Used(turn, time.Now())
+}
+
+func (b *Board) IsFlagged(turn Turn) bool {
+	return b.clock.IsFlagged(turn, time.Now())
+}
+
+// AcceptsFlag は相手から持ち時間切れを指摘された時に、自分の時計でも通信遅延の許容範囲内で切れているかを返します
+// 相手の時計だけで負けにしないよう、指摘を受け入れる前に確かめます
+func (b *Board) AcceptsFlag() bool {
+	return b.IsPlaying() && b.clock.Remaining(MyTurn, time.Now()) <= flagTolerance
+}
+
+// CanClaimTimeout は相手の持ち時間が通信遅延の許容範囲を超えて切れており、勝ちを主張できるかを返します
+func (b *Board) CanClaimTimeout() bool {
+	return b.IsPlaying() && b.IsOpTurn() && b.clock.Overrun(OpTurn, time.Now()) > flagTolerance
+}
+
+type ForfeitReason int
+
+const (
+	Timeout ForfeitReason = iota
+	Abandon
+	Resign
+)
+
+type Forfeit struct {
+	loser  Turn
+	reason ForfeitReason
+}
+
+func (f *Forfeit) Loser() Turn {
+	return f.loser
+}
+
+func (f *Forfeit) Reason() ForfeitReason {
+	return f.reason
+}
+
+// Timeout は turn 側の時間切れ負けを記録します
+func (b *Board) Timeout(turn Turn) {
+	b.history.Forfeit(turn, Timeout)
+}
+
+// Abandon は対局中に接続が切れた turn 側の放棄負けを記録します
+func (b *Board) Abandon(turn Turn) {
+	b.history.Forfeit(turn, Abandon)
+}
+
+// Resign は turn 側の投了を記録します
+func (b *Board) Resign(turn Turn) {
+	b.history.Forfeit(turn, Resign)
+}
+
+// AgreeDraw は双方合意による引き分けを記録します
+func (b *Board) AgreeDraw() {
+	b.history.AgreeDraw()
+}
+
+func (b *Board) Forfeit() *Forfeit {
+	return b.history.forfeit
+}
+
+func (b *Board) Rules() *Rules {
+	return b.rules
+}
+
+func (b *Board) SetRules(rules *Rules) {
+	b.rules = rules
+}
+
+func (b *Board) History() *History {
+	return b.history
 }
 
 type JudgeStatus int
@@ -222,62 +385,47 @@ const (
 	Lose
 	Draw
 )
-const maxTurnCount = 8
+const flagTolerance = 2 * time.Second
 
 func (b *Board) Judge() JudgeStatus {
-	var isMy3hit, isOp3hit bool
-	if len(b.myQA) > 0 {
-		isMy3hit = b.myQA[len(b.myQA)-1].answer.IsAllHit()
-	}
-	if len(b.opQA) > 0 {
-		isOp3hit = b.opQA[len(b.opQA)-1].answer.IsAllHit()
-	}
-	// 自分のターン(answer送信) && 自分スタート
-	if b.IsMyTurn() && b.IsMyTurnInit() {
-		if !isMy3hit && isOp3hit {
-			return Lose
-		}
-		if isMy3hit && !isOp3hit {
-			return Win
-		}
-		if isMy3hit && isOp3hit {
-			return Draw
-		}
-	}
-	// 相手のターン(answer受取) && 相手スタート
-	if b.IsOpTurn() && b.IsOpTurnInit() {
-		if !isOp3hit && isMy3hit {
-			return Win
-		}
-		if isOp3hit && !isMy3hit {
-			return Lose
-		}
-		if isMy3hit && isOp3hit {
-			return Draw
-		}
-	}
-	if b.myTurnCount == b.opTurnCount && b.myTurnCount == maxTurnCount {
-		return Draw
-	}
-	return NotYet
+	return b.Judgement().Status()
+}
+
+func (b *Board) Judgement() *Judgement {
+	return Adjudicate(b.rules, b.history)
 }
 
 func (b *Board) Finish() {
 	b.state = Finished
+	b.clock.Stop(time.Now())
+	close(b.done)
 }
 
 func (b *Board) CalcAnswer(guess *Guess) *Answer {
 	return b.myHand.Answer(guess)
 }
 
+// logger は対局を特定できる属性を持つ Logger を返します
+// 手札は属性に含めません
+func (b *Board) logger() *slog.Logger {
+	return slog.Default().With("component", "game", "round", b.round, "turn", len(b.myQA)+len(b.opQA))
+}
+
 func (b *Board) AddMyQA(qa *QA) {
-	log.Printf("AddMyQA: %v", qa)
 	b.myQA = append(b.myQA, qa)
+	b.logger().Debug("add my QA", "qa", qa)
+	b.history.AddCall(MyTurn, qa, b.endsTurn(qa))
 }
 
 func (b *Board) AddOpQA(qa *QA) {
-	log.Printf("AddOpQA: %v", qa)
 	b.opQA = append(b.opQA, qa)
+	b.logger().Debug("add opponent QA", "qa", qa)
+	b.history.AddCall(OpTurn, qa, b.endsTurn(qa))
+}
+
+// endsTurn は DOUBLE の1回目で当たらなかった call 以外はターンを終えるものとして扱います
+func (b *Board) endsTurn(qa *QA) bool {
+	return b.double == nil || qa.answer.IsAllHit()
 }
 
 func (b *Board) WaitGuess(ch chan *Guess, toChan chan struct{}, to time.Duration) (*Guess, bool) {
@@ -287,6 +435,10 @@ func (b *Board) WaitGuess(ch chan *Guess, toChan chan struct{}, to time.Duration
 		return guess, false
 	case <-time.After(to):
 		return nil, true
+	case <-b.done:
+		// 投了などで手番中に終局した
+		close(toChan)
+		return nil, false
 	}
 }
 
@@ -295,7 +447,7 @@ func (b *Board) MyHandText() string {
 }
 
 func (b *Board) PNum() int {
-	return b.PNum()
+	return b.pNum
 }
 
 func (b *Board) Result() string {
@@ -0,0 +1,362 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Variant int
+
+const (
+	Standard Variant = iota
+	// Numer0n 形式のアイテムありルール
+	Numer0n
+)
+
+var variantNames = map[Variant]string{
+	Standard: "standard",
+	Numer0n:  "numer0n",
+}
+
+func NewVariantFromText(text string) Variant {
+	for v, n := range variantNames {
+		if n == text {
+			return v
+		}
+	}
+	return Standard
+}
+
+func (v Variant) Msg() string {
+	return variantNames[v]
+}
+
+type Item int
+
+const (
+	// 相手の各桁が High(5-9) か Low(0-4) かを知る
+	HighLow Item = iota
+	// 指定した数字が相手の手札にあるか、あればその位置を知る
+	Target
+	// 相手の手札の最大値と最小値の差を知る
+	Slash
+	// 自分の手札の並びを入れ替える
+	Shuffle
+	// 自分の手札の1桁を High/Low を保ったまま手札にない数字に替える
+	Change
+	// 自分の手札の1桁を公開する代わりに、このターンに2回 call できる
+	Double
+)
+
+var itemNames = map[Item]string{
+	HighLow: "high_low",
+	Target:  "target",
+	Slash:   "slash",
+	Shuffle: "shuffle",
+	Change:  "change",
+	Double:  "double",
+}
+
+func NewItemFromText(text string) (Item, error) {
+	for i, n := range itemNames {
+		if n == text {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown item: %q", text)
+}
+
+func (i Item) Msg() string {
+	return itemNames[i]
+}
+
+func (i Item) View() string {
+	switch i {
+	case HighLow:
+		return "HIGH&LOW"
+	case Target:
+		return "TARGET"
+	case Slash:
+		return "SLASH"
+	case Shuffle:
+		return "SHUFFLE"
+	case Change:
+		return "CHANGE"
+	case Double:
+		return "DOUBLE"
+	}
+	return ""
+}
+
+func isHigh(n int) bool {
+	return n >= 5
+}
+
+func highLowText(n int) string {
+	if isHigh(n) {
+		return "H"
+	}
+	return "L"
+}
+
+func (h *Hand) IsValid() bool {
+	if len(*h) != numOfDigits {
+		return false
+	}
+	for i, n := range *h {
+		if n < 0 || n > 9 || slices.Contains((*h)[:i], n) {
+			return false
+		}
+	}
+	return true
+}
+
+// HighLow は各桁の High/Low を "HLH" の形式で返します
+func (h *Hand) HighLow() string {
+	var sb strings.Builder
+	for _, n := range *h {
+		sb.WriteString(highLowText(n))
+	}
+	return sb.String()
+}
+
+// Target は n の位置(1始まり)を返し、手札になければ 0 を返します
+func (h *Hand) Target(n int) int {
+	return slices.Index(*h, n) + 1
+}
+
+func (h *Hand) Slash() int {
+	return slices.Max(*h) - slices.Min(*h)
+}
+
+// Shuffle は同じ数字のまま並びを替えた手札か検証します
+func (h *Hand) Shuffle(next *Hand) error {
+	if !next.IsValid() {
+		return fmt.Errorf("invalid hand: %v", *next)
+	}
+	cur, nxt := slices.Clone(*h), slices.Clone(*next)
+	slices.Sort(cur)
+	slices.Sort(nxt)
+	if !slices.Equal(cur, nxt) {
+		return fmt.Errorf("shuffle must keep the same digits")
+	}
+	return nil
+}
+
+// Change は pos 桁目(1始まり)を n に替えられるか検証し、替えた手札を返します
+func (h *Hand) Change(pos, n int) (*Hand, error) {
+	if pos < 1 || pos > numOfDigits {
+		return nil, fmt.Errorf("invalid position: %d", pos)
+	}
+	if slices.Contains(*h, n) {
+		return nil, fmt.Errorf("%d is already in hand", n)
+	}
+	if isHigh((*h)[pos-1]) != isHigh(n) {
+		return nil, fmt.Errorf("change must keep high/low")
+	}
+	next := NewHand(slices.Clone(*h))
+	(*next)[pos-1] = n
+	if !next.IsValid() {
+		return nil, fmt.Errorf("invalid hand: %v", *next)
+	}
+	return next, nil
+}
+
+// ItemUse はアイテムの使用履歴です
+// after はアイテム使用時点での使用者の call 数で、SHUFFLE や CHANGE より前の結果を推理から外すのに使います
+type ItemUse struct {
+	turn  Turn
+	item  Item
+	after int
+	info  string
+}
+
+func (u *ItemUse) Turn() Turn {
+	return u.turn
+}
+
+func (u *ItemUse) Item() Item {
+	return u.item
+}
+
+func (u *ItemUse) After() int {
+	return u.after
+}
+
+func (u *ItemUse) Info() string {
+	return u.info
+}
+
+func (u *ItemUse) View() string {
+	who := "You"
+	if u.turn == OpTurn {
+		who = "Op"
+	}
+	view := fmt.Sprintf("[%s] %s (after %d calls)", who, u.item.View(), u.after)
+	if u.info != "" {
+		view += ": " + u.info
+	}
+	return view
+}
+
+func (b *Board) Variant() Variant {
+	return b.variant
+}
+
+func (b *Board) SetVariant(v Variant) {
+	b.variant = v
+}
+
+// CanUseItem は turn 側がアイテムを使えるか検証します
+// アイテムは各1回、自分の手番の call 前にのみ使えます
+func (b *Board) CanUseItem(turn Turn, item Item) error {
+	if b.variant != Numer0n {
+		return fmt.Errorf("items are not enabled")
+	}
+	if !b.IsPlaying() || b.turn != turn {
+		return fmt.Errorf("items can only be used in own turn")
+	}
+	if b.usedItems[turn][item] {
+		return fmt.Errorf("%s is already used", item.View())
+	}
+	if b.double != nil {
+		return fmt.Errorf("items can not be used during double")
+	}
+	return nil
+}
+
+// ValidateItemArgs は相手から届いたアイテムの引数を、使用を記録する前に検証します
+// position は1始まりの桁、digit は 0-9 の数字で、アイテムごとに必要なものだけを確かめます
+func ValidateItemArgs(item Item, position, digit *int, info string) error {
+	switch item {
+	case Target:
+		return validateDigit(digit)
+	case Change:
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+		if info != "H" && info != "L" {
+			return fmt.Errorf("invalid high/low: %q", info)
+		}
+	case Double:
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+		return validateDigit(digit)
+	}
+	return nil
+}
+
+func validatePosition(position *int) error {
+	if position == nil || *position < 1 || *position > numOfDigits {
+		return fmt.Errorf("invalid position")
+	}
+	return nil
+}
+
+func validateDigit(digit *int) error {
+	if digit == nil || *digit < 0 || *digit > 9 {
+		return fmt.Errorf("invalid digit")
+	}
+	return nil
+}
+
+// ValidateItemResult は相手から届いた攻撃アイテムの結果の引数を検証します
+func ValidateItemResult(item Item, digit *int) error {
+	switch item {
+	case HighLow, Target, Slash:
+		return validateDigit(digit)
+	}
+	return fmt.Errorf("%s is not an attack item", item.View())
+}
+
+// UseItem は turn 側のアイテム使用を検証して記録します
+func (b *Board) UseItem(turn Turn, item Item) error {
+	if err := b.CanUseItem(turn, item); err != nil {
+		return err
+	}
+	b.usedItems[turn][item] = true
+	return nil
+}
+
+func (b *Board) IsItemUsed(turn Turn, item Item) bool {
+	return b.usedItems[turn][item]
+}
+
+// AddItemUse はアイテムの使用履歴を残します
+func (b *Board) AddItemUse(turn Turn, item Item, info string) *ItemUse {
+	after := len(b.myQA)
+	if turn == OpTurn {
+		after = len(b.opQA)
+	}
+	use := &ItemUse{turn, item, after, info}
+	b.itemLog = append(b.itemLog, use)
+	if item == Shuffle || item == Change {
+		b.history.AddHandChange(turn)
+	}
+	return use
+}
+
+func (b *Board) ItemLog() []*ItemUse {
+	return b.itemLog
+}
+
+func (b *Board) MyHand() *Hand {
+	return b.myHand
+}
+
+// ShuffleMyHand は SHUFFLE で自分の手札を並べ替えます
+func (b *Board) ShuffleMyHand(next *Hand) error {
+	if err := b.myHand.Shuffle(next); err != nil {
+		return err
+	}
+	b.myHand = next
+	return nil
+}
+
+// ChangeMyHand は CHANGE で自分の手札の1桁を替え、相手に伝える High/Low を返します
+func (b *Board) ChangeMyHand(pos, n int) (string, error) {
+	next, err := b.myHand.Change(pos, n)
+	if err != nil {
+		return "", err
+	}
+	b.myHand = next
+	return highLowText(n), nil
+}
+
+// RevealMyDigit は DOUBLE で公開する pos 桁目(1始まり)の数字を返します
+func (b *Board) RevealMyDigit(pos int) (int, error) {
+	if pos < 1 || pos > numOfDigits {
+		return -1, fmt.Errorf("invalid position: %d", pos)
+	}
+	return (*b.myHand)[pos-1], nil
+}
+
+// AnswerItem は相手の攻撃アイテムに自分の手札で回答します
+func (b *Board) AnswerItem(item Item, digit int) (string, error) {
+	switch item {
+	case HighLow:
+		return b.myHand.HighLow(), nil
+	case Target:
+		return strconv.Itoa(b.myHand.Target(digit)), nil
+	case Slash:
+		return strconv.Itoa(b.myHand.Slash()), nil
+	}
+	return "", fmt.Errorf("%s is not an attack item", item.View())
+}
+
+// ArmDouble は turn 側の次の call を手番交代しないボーナスの call にします
+func (b *Board) ArmDouble(turn Turn) {
+	b.double = &turn
+}
+
+// IsBonusCall は現在の call が DOUBLE による1回目の call かを返します
+func (b *Board) IsBonusCall() bool {
+	return b.double != nil && *b.double == b.turn
+}
+
+func (b *Board) EndBonusCall() {
+	b.double = nil
+}
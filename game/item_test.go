@@ -0,0 +1,180 @@
+package game
+
+import "testing"
+
+func TestHandShuffle(t *testing.T) {
+	tests := []struct {
+		next    string
+		wantErr bool
+	}{
+		{next: "543"},
+		{next: "345"},
+		{next: "435"},
+		{next: "346", wantErr: true},
+		{next: "344", wantErr: true},
+		{next: "34", wantErr: true},
+		{next: "3456", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.next, func(t *testing.T) {
+			err := NewHandFromText("345").Shuffle(NewHandFromText(tt.next))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Shuffle(%s) error = %v, wantErr %t", tt.next, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		pos     int
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{name: "low to low", pos: 1, n: 0, want: "045"},
+		{name: "high to high", pos: 3, n: 9, want: "349"},
+		{name: "boundary low", pos: 2, n: 2, want: "325"},
+		{name: "low to high", pos: 1, n: 7, wantErr: true},
+		{name: "high to low", pos: 3, n: 1, wantErr: true},
+		{name: "digit already in hand", pos: 1, n: 4, wantErr: true},
+		{name: "position too small", pos: 0, n: 0, wantErr: true},
+		{name: "position too large", pos: 4, n: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hand := NewHandFromText("345")
+			next, err := hand.Change(tt.pos, tt.n)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Change(%d, %d) = %s, want error", tt.pos, tt.n, next.Msg())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Change(%d, %d) error: %v", tt.pos, tt.n, err)
+			}
+			if next.Msg() != tt.want || next.HighLow() != hand.HighLow() {
+				t.Errorf("Change(%d, %d) = %s (%s), want %s (%s)", tt.pos, tt.n, next.Msg(), next.HighLow(), tt.want, hand.HighLow())
+			}
+			// 元の手札は変えない
+			if hand.Msg() != "345" {
+				t.Errorf("Change modified the original hand to %s", hand.Msg())
+			}
+		})
+	}
+}
+
+func TestBoardUseItem(t *testing.T) {
+	newItemBoard := func(variant Variant) *Board {
+		b := NewBoard()
+		b.SetVariant(variant)
+		b.Start(NewHandFromText("345"), MyTurn, 1, DefaultTimeControl())
+		return b
+	}
+	t.Run("each item once per game", func(t *testing.T) {
+		b := newItemBoard(Numer0n)
+		if err := b.UseItem(MyTurn, Target); err != nil {
+			t.Fatalf("first UseItem error: %v", err)
+		}
+		if err := b.UseItem(MyTurn, Target); err == nil {
+			t.Error("second UseItem of the same item succeeded")
+		}
+		if err := b.UseItem(MyTurn, Slash); err != nil {
+			t.Errorf("UseItem of another item error: %v", err)
+		}
+	})
+	t.Run("a rematch restores the items", func(t *testing.T) {
+		b := newItemBoard(Numer0n)
+		if err := b.UseItem(MyTurn, Target); err != nil {
+			t.Fatal(err)
+		}
+		b.Finish()
+		b.Rematch()
+		b.Start(NewHandFromText("345"), MyTurn, 1, DefaultTimeControl())
+		if err := b.UseItem(MyTurn, Target); err != nil {
+			t.Errorf("UseItem after Rematch error: %v", err)
+		}
+	})
+	t.Run("only in own turn", func(t *testing.T) {
+		b := newItemBoard(Numer0n)
+		if err := b.UseItem(OpTurn, Target); err == nil {
+			t.Error("UseItem in the opponent's turn succeeded")
+		}
+	})
+	t.Run("only with items enabled", func(t *testing.T) {
+		b := newItemBoard(Standard)
+		if err := b.UseItem(MyTurn, Target); err == nil {
+			t.Error("UseItem in the standard variant succeeded")
+		}
+	})
+	t.Run("not during double", func(t *testing.T) {
+		b := newItemBoard(Numer0n)
+		b.ArmDouble(MyTurn)
+		if err := b.UseItem(MyTurn, Target); err == nil {
+			t.Error("UseItem during DOUBLE succeeded")
+		}
+	})
+}
+
+func TestValidateItemArgs(t *testing.T) {
+	p := func(n int) *int { return &n }
+	tests := []struct {
+		name     string
+		item     Item
+		position *int
+		digit    *int
+		info     string
+		wantErr  bool
+	}{
+		{name: "high low needs nothing", item: HighLow},
+		{name: "slash needs nothing", item: Slash},
+		{name: "shuffle needs nothing", item: Shuffle},
+		{name: "target", item: Target, digit: p(9)},
+		{name: "target without digit", item: Target, wantErr: true},
+		{name: "target digit out of range", item: Target, digit: p(10), wantErr: true},
+		{name: "target negative digit", item: Target, digit: p(-1), wantErr: true},
+		{name: "change", item: Change, position: p(1), info: "H"},
+		{name: "change without position", item: Change, info: "L", wantErr: true},
+		{name: "change position out of range", item: Change, position: p(4), info: "L", wantErr: true},
+		{name: "change without high low", item: Change, position: p(2), wantErr: true},
+		{name: "double", item: Double, position: p(3), digit: p(0)},
+		{name: "double without digit", item: Double, position: p(3), wantErr: true},
+		{name: "double without position", item: Double, digit: p(0), wantErr: true},
+		{name: "double position zero", item: Double, position: p(0), digit: p(0), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateItemArgs(tt.item, tt.position, tt.digit, tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateItemArgs() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateItemResult(t *testing.T) {
+	p := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		item    Item
+		digit   *int
+		wantErr bool
+	}{
+		{name: "high low", item: HighLow, digit: p(2)},
+		{name: "target", item: Target, digit: p(0)},
+		{name: "slash", item: Slash, digit: p(9)},
+		{name: "without digit", item: Slash, wantErr: true},
+		{name: "digit out of range", item: Target, digit: p(10), wantErr: true},
+		{name: "defense item", item: Shuffle, digit: p(0), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateItemResult(tt.item, tt.digit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateItemResult() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const maxSuddenDeath = 3
+
+type GameRecord struct {
+	judge     JudgeStatus
+	myGuesses int
+	opGuesses int
+	digest    string
+}
+
+func (r *GameRecord) Judge() JudgeStatus {
+	return r.judge
+}
+
+// Match は同じ相手と先後を入れ替えながら bestOf 局を戦う対局です
+// 規定局数で並んだ場合は総guess数の少ない方、それでも並んだ場合はサドンデスで決着をつけます
+type Match struct {
+	bestOf    int
+	records   []*GameRecord
+	abandoned *Turn
+}
+
+func NewMatch(bestOf int) *Match {
+	if bestOf < 1 {
+		bestOf = 1
+	}
+	return &Match{
+		bestOf:  bestOf,
+		records: make([]*GameRecord, 0),
+	}
+}
+
+func (m *Match) BestOf() int {
+	return m.bestOf
+}
+
+func (m *Match) Records() []*GameRecord {
+	return m.records
+}
+
+// Record は終局した board の結果を記録します
+func (m *Match) Record(b *Board) {
+	// 接続断による放棄はマッチ全体の負けとする
+	if f := b.Forfeit(); f != nil && f.Reason() == Abandon {
+		loser := f.Loser()
+		m.abandoned = &loser
+	}
+	m.records = append(m.records, &GameRecord{
+		judge:     b.Judge(),
+		myGuesses: len(b.myQA),
+		opGuesses: len(b.opQA),
+		digest:    b.history.Digest(b.pNum),
+	})
+}
+
+// Score は規定局数までの勝ち点(勝ち1, 引き分け0.5)を返します
+func (m *Match) Score() (float64, float64) {
+	var my, op float64
+	for _, r := range m.regulation() {
+		switch r.judge {
+		case Win:
+			my++
+		case Lose:
+			op++
+		case Draw:
+			my += 0.5
+			op += 0.5
+		}
+	}
+	return my, op
+}
+
+// Guesses は規定局数までの総guess数を返します
+func (m *Match) Guesses() (int, int) {
+	var my, op int
+	for _, r := range m.regulation() {
+		my += r.myGuesses
+		op += r.opGuesses
+	}
+	return my, op
+}
+
+func (m *Match) regulation() []*GameRecord {
+	return m.records[:min(len(m.records), m.bestOf)]
+}
+
+func (m *Match) IsDecided() bool {
+	return m.Judge() != NotYet
+}
+
+func (m *Match) Judge() JudgeStatus {
+	if m.abandoned != nil {
+		if *m.abandoned == MyTurn {
+			return Lose
+		}
+		return Win
+	}
+	my, op := m.Score()
+	// 規定局数の途中でも過半数を取れば決着
+	half := float64(m.bestOf) / 2
+	if my > half {
+		return Win
+	}
+	if op > half {
+		return Lose
+	}
+	if len(m.records) < m.bestOf {
+		return NotYet
+	}
+	if my != op {
+		if my > op {
+			return Win
+		}
+		return Lose
+	}
+	// 1局勝負の引き分けはそのまま引き分け
+	if m.bestOf == 1 {
+		return Draw
+	}
+	// タイブレーク: 総guess数が少ない方の勝ち
+	myGuesses, opGuesses := m.Guesses()
+	if myGuesses != opGuesses {
+		if myGuesses < opGuesses {
+			return Win
+		}
+		return Lose
+	}
+	// サドンデス: 最初に勝った方の勝ち
+	for _, r := range m.records[m.bestOf:] {
+		if r.judge == Win || r.judge == Lose {
+			return r.judge
+		}
+	}
+	if len(m.records)-m.bestOf >= maxSuddenDeath {
+		return Draw
+	}
+	return NotYet
+}
+
+// Digest は各局の経過をまとめたハッシュで、結果の署名に含めます
+func (m *Match) Digest() string {
+	h := sha256.New()
+	for _, r := range m.records {
+		h.Write([]byte(r.digest))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Result は Board.Result と同じく player1 から見たマッチ全体の結果を返します
+func (m *Match) Result(pNum int) string {
+	j := m.Judge()
+	if j == Draw {
+		return "0.5"
+	}
+	if j == Win && pNum == 1 || j == Lose && pNum == 2 {
+		return "1"
+	}
+	return "0"
+}
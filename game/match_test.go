@@ -0,0 +1,255 @@
+package game
+
+import "testing"
+
+// matchGame はマッチの1局で、draw は合意による引き分け、abandon は自分の接続断です
+type matchGame struct {
+	steps   []step
+	draw    bool
+	abandon bool
+}
+
+// 先に当てた方が勝つルールで、自分と相手の guess 数を局ごとに変えます
+var (
+	// winIn1 は自分1回、相手0回
+	winIn1 = matchGame{steps: []step{crack(MyTurn)}}
+	// winIn1Late は自分1回、相手1回
+	winIn1Late = matchGame{steps: []step{miss(OpTurn), crack(MyTurn)}}
+	// loseIn1 は自分0回、相手1回
+	loseIn1 = matchGame{steps: []step{crack(OpTurn)}}
+	// loseIn1Late は自分1回、相手1回
+	loseIn1Late = matchGame{steps: []step{miss(MyTurn), crack(OpTurn)}}
+	drawn       = matchGame{draw: true}
+	abandoned   = matchGame{abandon: true}
+)
+
+// newTestBoard は g のとおりに進めて終局した盤面を返します
+func newTestBoard(pNum int, g matchGame) *Board {
+	b := NewBoard()
+	b.SetRules(NewRules(FirstToCrack, 8, false))
+	b.Start(NewHandFromText("345"), MyTurn, pNum, DefaultTimeControl())
+	for _, s := range g.steps {
+		qa := NewQA(NewGuessFromText(s.guess), NewAnswer(s.hit, s.blow))
+		if s.by == MyTurn {
+			b.AddMyQA(qa)
+		} else {
+			b.AddOpQA(qa)
+		}
+	}
+	if g.draw {
+		b.AgreeDraw()
+	}
+	if g.abandon {
+		b.Abandon(MyTurn)
+	}
+	b.Finish()
+	return b
+}
+
+func TestMatchJudge(t *testing.T) {
+	tests := []struct {
+		name      string
+		bestOf    int
+		games     []matchGame
+		want      JudgeStatus
+		wantScore [2]float64
+	}{
+		{
+			name:      "best of one",
+			bestOf:    1,
+			games:     []matchGame{winIn1},
+			want:      Win,
+			wantScore: [2]float64{1, 0},
+		},
+		{
+			name:      "best of one draw is not tiebroken",
+			bestOf:    1,
+			games:     []matchGame{drawn},
+			want:      Draw,
+			wantScore: [2]float64{0.5, 0.5},
+		},
+		{
+			name:      "best of three is undecided after one game",
+			bestOf:    3,
+			games:     []matchGame{winIn1},
+			want:      NotYet,
+			wantScore: [2]float64{1, 0},
+		},
+		{
+			name:      "best of three is decided by a majority",
+			bestOf:    3,
+			games:     []matchGame{winIn1, winIn1},
+			want:      Win,
+			wantScore: [2]float64{2, 0},
+		},
+		{
+			name:      "best of three comeback",
+			bestOf:    3,
+			games:     []matchGame{winIn1, loseIn1, loseIn1},
+			want:      Lose,
+			wantScore: [2]float64{1, 2},
+		},
+		{
+			name:      "a draw counts as half a point",
+			bestOf:    3,
+			games:     []matchGame{drawn, winIn1, drawn},
+			want:      Win,
+			wantScore: [2]float64{2, 1},
+		},
+		{
+			name:      "tiebreak by fewer total guesses for the opponent",
+			bestOf:    2,
+			games:     []matchGame{loseIn1Late, winIn1},
+			want:      Lose,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "tiebreak by fewer total guesses for me",
+			bestOf:    2,
+			games:     []matchGame{loseIn1, winIn1Late},
+			want:      Win,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "equal guesses go to sudden death",
+			bestOf:    2,
+			games:     []matchGame{winIn1, loseIn1},
+			want:      NotYet,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "sudden death is won by the first win and does not change the score",
+			bestOf:    2,
+			games:     []matchGame{winIn1, loseIn1, drawn, loseIn1},
+			want:      Lose,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "sudden death continues after a draw",
+			bestOf:    2,
+			games:     []matchGame{winIn1, loseIn1, drawn, drawn},
+			want:      NotYet,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "sudden death is capped",
+			bestOf:    2,
+			games:     []matchGame{winIn1, loseIn1, drawn, drawn, drawn},
+			want:      Draw,
+			wantScore: [2]float64{1, 1},
+		},
+		{
+			name:      "abandon loses the whole match",
+			bestOf:    3,
+			games:     []matchGame{winIn1, abandoned},
+			want:      Lose,
+			wantScore: [2]float64{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch(tt.bestOf)
+			for _, g := range tt.games {
+				m.Record(newTestBoard(1, g))
+			}
+			if got := m.Judge(); got != tt.want {
+				t.Errorf("Judge() = %v, want %v", got, tt.want)
+			}
+			if got := m.IsDecided(); got != (tt.want != NotYet) {
+				t.Errorf("IsDecided() = %t, want %t", got, tt.want != NotYet)
+			}
+			if my, op := m.Score(); my != tt.wantScore[0] || op != tt.wantScore[1] {
+				t.Errorf("Score() = (%v, %v), want (%v, %v)", my, op, tt.wantScore[0], tt.wantScore[1])
+			}
+		})
+	}
+}
+
+func TestMatchResult(t *testing.T) {
+	tests := []struct {
+		name  string
+		games []matchGame
+		pNum  int
+		want  string
+	}{
+		{name: "player1 wins", games: []matchGame{winIn1}, pNum: 1, want: "1"},
+		{name: "player1 loses", games: []matchGame{loseIn1}, pNum: 1, want: "0"},
+		{name: "player2 wins", games: []matchGame{winIn1}, pNum: 2, want: "0"},
+		{name: "player2 loses", games: []matchGame{loseIn1}, pNum: 2, want: "1"},
+		{name: "draw", games: []matchGame{drawn}, pNum: 2, want: "0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMatch(1)
+			for _, g := range tt.games {
+				m.Record(newTestBoard(tt.pNum, g))
+			}
+			if got := m.Result(tt.pNum); got != tt.want {
+				t.Errorf("Result(%d) = %q, want %q", tt.pNum, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoardRematchAlternatesFirst(t *testing.T) {
+	rules := NewRules(EqualTurns, 4, true)
+	b := NewBoard()
+	b.SetRules(rules)
+	b.Start(NewHandFromText("345"), OpTurn, 2, DefaultTimeControl())
+	b.Finish()
+	want := OpTurn
+	for round := 2; round <= 5; round++ {
+		next := b.Rematch()
+		want = want.Reverse()
+		if next != want {
+			t.Fatalf("round %d: Rematch() = %v, want %v", round, next, want)
+		}
+		if b.Round() != round || b.PNum() != 2 || b.Rules() != rules || !b.IsInMenu() {
+			t.Fatalf("round %d: Rematch() kept round %d, pNum %d, rules %v, in menu %t", round, b.Round(), b.PNum(), b.Rules(), b.IsInMenu())
+		}
+		b.Start(NewHandFromText("345"), next, 2, DefaultTimeControl())
+		b.Finish()
+	}
+}
+
+// mirror は g を相手の盤面から見た局にします
+func mirror(g matchGame) matchGame {
+	steps := make([]step, len(g.steps))
+	for i, s := range g.steps {
+		s.by = s.by.Reverse()
+		steps[i] = s
+	}
+	return matchGame{steps: steps, draw: g.draw}
+}
+
+func TestMatchDigest(t *testing.T) {
+	tests := []struct {
+		name  string
+		games []matchGame
+	}{
+		{name: "one game", games: []matchGame{winIn1Late}},
+		{name: "several games", games: []matchGame{winIn1, loseIn1Late, drawn}},
+		{name: "keep turn after a hit", games: []matchGame{{steps: []step{{by: MyTurn, guess: "012", hit: 1, keepTurn: true}, crack(MyTurn)}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 両者がそれぞれの盤面で記録したマッチの Digest は一致する
+			m1, m2 := NewMatch(len(tt.games)), NewMatch(len(tt.games))
+			for _, g := range tt.games {
+				m1.Record(newTestBoard(1, g))
+				m2.Record(newTestBoard(2, mirror(g)))
+			}
+			if m1.Digest() != m2.Digest() {
+				t.Errorf("Digest() = %s for player1, %s for player2", m1.Digest(), m2.Digest())
+			}
+			// 同じ盤面を反対の席で記録すると経過が食い違う
+			swapped := NewMatch(len(tt.games))
+			for _, g := range tt.games {
+				swapped.Record(newTestBoard(2, g))
+			}
+			if swapped.Digest() == m1.Digest() {
+				t.Error("Digest() agrees although the players are swapped")
+			}
+		})
+	}
+}
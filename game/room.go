@@ -0,0 +1,269 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	MinRoomPlayers = 3
+	MaxRoomPlayers = 6
+)
+
+// AllTargets は生存している全員を対象にした call を表します
+const AllTargets = ""
+
+type Player struct {
+	id         string
+	eliminated bool
+	place      int
+}
+
+func (p *Player) ID() string {
+	return p.id
+}
+
+func (p *Player) IsEliminated() bool {
+	return p.eliminated
+}
+
+// Place は確定した順位を返し、未確定のときは 0 を返します
+func (p *Player) Place() int {
+	return p.place
+}
+
+type RoomCall struct {
+	by     string
+	target string
+	guess  *Guess
+	answer *Answer
+}
+
+func (c *RoomCall) By() string {
+	return c.by
+}
+
+func (c *RoomCall) Target() string {
+	return c.target
+}
+
+func (c *RoomCall) View() string {
+	return fmt.Sprintf("%s -> %s: %s (%s)", c.by, c.target, c.guess.View(), c.answer.Msg())
+}
+
+// Room は3〜6人で手札を当て合うバトルロイヤルの盤面です
+// 手番のプレイヤーは生存している1人または全員の手札に call し、手札を当てられたプレイヤーは脱落します
+// 全員が同じ順序でメッセージを適用すれば、どのクライアントでも同じ状態になります
+type Room struct {
+	state   State
+	me      string
+	myHand  *Hand
+	players []*Player
+	turn    int
+	guess   *Guess
+	pending []string
+	cracked []string
+	calls   []*RoomCall
+}
+
+func NewRoom(me string) *Room {
+	return &Room{
+		state: InMenu,
+		me:    me,
+		calls: make([]*RoomCall, 0),
+	}
+}
+
+func (r *Room) Start(hand *Hand, ids []string) error {
+	if len(ids) < MinRoomPlayers || len(ids) > MaxRoomPlayers {
+		return fmt.Errorf("room needs %d-%d players: %d", MinRoomPlayers, MaxRoomPlayers, len(ids))
+	}
+	if !slices.Contains(ids, r.me) {
+		return fmt.Errorf("%s is not in the room", r.me)
+	}
+	r.players = make([]*Player, len(ids))
+	for i, id := range ids {
+		r.players[i] = &Player{id: id}
+	}
+	r.myHand = hand
+	r.turn = 0
+	r.state = Playing
+	return nil
+}
+
+func (r *Room) IsPlaying() bool {
+	return r.state == Playing
+}
+
+func (r *Room) IsFinished() bool {
+	return r.state == Finished
+}
+
+func (r *Room) Me() string {
+	return r.me
+}
+
+func (r *Room) MyHand() *Hand {
+	return r.myHand
+}
+
+func (r *Room) Players() []*Player {
+	return r.players
+}
+
+func (r *Room) Calls() []*RoomCall {
+	return r.calls
+}
+
+func (r *Room) player(id string) *Player {
+	for _, p := range r.players {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *Room) Alive() []string {
+	ids := make([]string, 0, len(r.players))
+	for _, p := range r.players {
+		if !p.eliminated {
+			ids = append(ids, p.id)
+		}
+	}
+	return ids
+}
+
+func (r *Room) CurrentPlayer() string {
+	return r.players[r.turn].id
+}
+
+func (r *Room) IsMyTurn() bool {
+	return r.IsPlaying() && r.CurrentPlayer() == r.me && r.guess == nil
+}
+
+// IsAnswering は直前の call への回答を待っているかを返します
+func (r *Room) IsAnswering() bool {
+	return r.guess != nil
+}
+
+// Guess は by による target(AllTargets なら生存者全員)への call を記録し、回答すべきプレイヤーを返します
+func (r *Room) Guess(by, target string, guess *Guess) ([]string, error) {
+	if !r.IsPlaying() || r.CurrentPlayer() != by || r.guess != nil {
+		return nil, fmt.Errorf("it is not %s's turn", by)
+	}
+	if !NewHand(*guess).IsValid() {
+		return nil, fmt.Errorf("invalid guess: %v", *guess)
+	}
+	var targets []string
+	if target == AllTargets {
+		for _, id := range r.Alive() {
+			if id != by {
+				targets = append(targets, id)
+			}
+		}
+	} else {
+		p := r.player(target)
+		if p == nil || p.eliminated || target == by {
+			return nil, fmt.Errorf("invalid target: %s", target)
+		}
+		targets = []string{target}
+	}
+	r.guess = guess
+	r.pending = targets
+	r.cracked = make([]string, 0)
+	return slices.Clone(targets), nil
+}
+
+// IsMyAnswerNeeded は自分が直前の call に回答すべきかを返します
+func (r *Room) IsMyAnswerNeeded() bool {
+	return slices.Contains(r.pending, r.me)
+}
+
+func (r *Room) CalcAnswer() *Answer {
+	return r.myHand.Answer(r.guess)
+}
+
+// Answer は target の回答を記録し、全員が回答し終えたら脱落と手番の移動を確定します
+func (r *Room) Answer(target string, answer *Answer) error {
+	i := slices.Index(r.pending, target)
+	if r.guess == nil || i < 0 {
+		return fmt.Errorf("%s is not expected to answer", target)
+	}
+	r.pending = slices.Delete(r.pending, i, i+1)
+	r.calls = append(r.calls, &RoomCall{r.CurrentPlayer(), target, r.guess, answer})
+	if answer.IsAllHit() {
+		r.cracked = append(r.cracked, target)
+	}
+	if len(r.pending) == 0 {
+		r.resolve()
+	}
+	return nil
+}
+
+// Eliminate は切断などで target を脱落させます
+func (r *Room) Eliminate(target string) {
+	p := r.player(target)
+	if p == nil || p.eliminated || !r.IsPlaying() {
+		return
+	}
+	current := r.CurrentPlayer()
+	r.eliminate(p)
+	if i := slices.Index(r.pending, target); i >= 0 {
+		r.pending = slices.Delete(r.pending, i, i+1)
+		if len(r.pending) == 0 {
+			r.resolve()
+			return
+		}
+	}
+	if current == target && r.IsPlaying() {
+		r.guess, r.pending = nil, nil
+		r.next()
+	}
+}
+
+func (r *Room) eliminate(p *Player) {
+	p.eliminated = true
+	p.place = len(r.Alive()) + 1
+	if alive := r.Alive(); len(alive) == 1 {
+		r.player(alive[0]).place = 1
+		r.state = Finished
+	}
+}
+
+// resolve は1回の call で当てられたプレイヤーを席順に脱落させ、次の手番に進めます
+// 回答の到着順に依らず全員が同じ順位になるよう、脱落は回答が揃ってからまとめて確定します
+func (r *Room) resolve() {
+	for _, p := range r.players {
+		if slices.Contains(r.cracked, p.id) && !p.eliminated && r.IsPlaying() {
+			r.eliminate(p)
+		}
+	}
+	r.guess, r.pending, r.cracked = nil, nil, nil
+	if r.IsPlaying() {
+		r.next()
+	}
+}
+
+func (r *Room) next() {
+	for i := 1; i <= len(r.players); i++ {
+		j := (r.turn + i) % len(r.players)
+		if !r.players[j].eliminated {
+			r.turn = j
+			return
+		}
+	}
+}
+
+// Standings は順位順にプレイヤーIDを返します
+func (r *Room) Standings() []string {
+	players := slices.Clone(r.players)
+	slices.SortStableFunc(players, func(a, b *Player) int {
+		return a.place - b.place
+	})
+	ids := make([]string, len(players))
+	for i, p := range players {
+		ids[i] = p.id
+	}
+	return ids
+}
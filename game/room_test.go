@@ -0,0 +1,170 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+// roomStep は by から target への1回の call で、cracked に含まれるプレイヤーは手札を当てられます
+// leave は call の代わりに切断で脱落するプレイヤーです
+type roomStep struct {
+	by      string
+	target  string
+	cracked []string
+	leave   string
+}
+
+// play は steps のとおりに各プレイヤーの回答を適用します
+func play(t *testing.T, r *Room, steps []roomStep) {
+	t.Helper()
+	for i, s := range steps {
+		if s.leave != "" {
+			r.Eliminate(s.leave)
+			continue
+		}
+		targets, err := r.Guess(s.by, s.target, NewGuessFromText("012"))
+		if err != nil {
+			t.Fatalf("step %d: Guess() error: %v", i, err)
+		}
+		for _, target := range targets {
+			answer := NewAnswer(0, 1)
+			if slices.Contains(s.cracked, target) {
+				answer = NewAnswer(3, 0)
+			}
+			if err := r.Answer(target, answer); err != nil {
+				t.Fatalf("step %d: Answer(%s) error: %v", i, target, err)
+			}
+		}
+	}
+}
+
+func TestRoom(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []roomStep
+		wantTurn      string
+		wantAlive     []string
+		wantStandings []string
+	}{
+		{
+			name:      "turn rotates by seat",
+			steps:     []roomStep{{by: "a", target: "b"}, {by: "b", target: "c"}, {by: "c", target: AllTargets}},
+			wantTurn:  "d",
+			wantAlive: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "eliminated player is skipped",
+			steps:     []roomStep{{by: "a", target: "b", cracked: []string{"b"}}},
+			wantTurn:  "c",
+			wantAlive: []string{"a", "c", "d"},
+		},
+		{
+			name:      "current player leaving passes the turn",
+			steps:     []roomStep{{by: "a", target: "b"}, {leave: "b"}},
+			wantTurn:  "c",
+			wantAlive: []string{"a", "c", "d"},
+		},
+		{
+			name:      "call to all targets cracks several players",
+			steps:     []roomStep{{by: "a", target: AllTargets, cracked: []string{"b", "d"}}},
+			wantTurn:  "c",
+			wantAlive: []string{"a", "c"},
+		},
+		{
+			name: "standings follow elimination order",
+			steps: []roomStep{
+				{by: "a", target: "c", cracked: []string{"c"}},
+				{by: "b", target: "a"},
+				{by: "d", target: "b", cracked: []string{"b"}},
+				{by: "a", target: "d", cracked: []string{"d"}},
+			},
+			wantAlive:     []string{"a"},
+			wantStandings: []string{"a", "d", "b", "c"},
+		},
+		{
+			name: "simultaneous cracks are ranked by seat",
+			steps: []roomStep{
+				{by: "a", target: "b"},
+				{by: "b", target: AllTargets, cracked: []string{"a", "c", "d"}},
+			},
+			wantAlive:     []string{"b"},
+			wantStandings: []string{"b", "d", "c", "a"},
+		},
+		{
+			name: "leavers are ranked below later eliminations",
+			steps: []roomStep{
+				{leave: "d"},
+				{by: "a", target: "b", cracked: []string{"b"}},
+				{by: "c", target: "a", cracked: []string{"a"}},
+			},
+			wantAlive:     []string{"c"},
+			wantStandings: []string{"c", "a", "b", "d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRoom("a")
+			if err := r.Start(NewHandFromText("345"), []string{"a", "b", "c", "d"}); err != nil {
+				t.Fatal(err)
+			}
+			play(t, r, tt.steps)
+			if got := r.Alive(); !slices.Equal(got, tt.wantAlive) {
+				t.Errorf("Alive() = %v, want %v", got, tt.wantAlive)
+			}
+			if tt.wantStandings == nil {
+				if got := r.CurrentPlayer(); got != tt.wantTurn {
+					t.Errorf("CurrentPlayer() = %s, want %s", got, tt.wantTurn)
+				}
+				return
+			}
+			if !r.IsFinished() {
+				t.Fatal("room is not finished")
+			}
+			if got := r.Standings(); !slices.Equal(got, tt.wantStandings) {
+				t.Errorf("Standings() = %v, want %v", got, tt.wantStandings)
+			}
+		})
+	}
+}
+
+func TestRoomRejects(t *testing.T) {
+	newTestRoom := func(t *testing.T) *Room {
+		r := NewRoom("a")
+		if err := r.Start(NewHandFromText("345"), []string{"a", "b", "c"}); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	guess := NewGuessFromText("012")
+	t.Run("guess out of turn", func(t *testing.T) {
+		if _, err := newTestRoom(t).Guess("b", "a", guess); err == nil {
+			t.Error("Guess() by a waiting player succeeded")
+		}
+	})
+	t.Run("guess at self", func(t *testing.T) {
+		if _, err := newTestRoom(t).Guess("a", "a", guess); err == nil {
+			t.Error("Guess() at self succeeded")
+		}
+	})
+	t.Run("guess at eliminated player", func(t *testing.T) {
+		r := newTestRoom(t)
+		r.Eliminate("c")
+		if _, err := r.Guess("a", "c", guess); err == nil {
+			t.Error("Guess() at an eliminated player succeeded")
+		}
+	})
+	t.Run("answer from a player not called", func(t *testing.T) {
+		r := newTestRoom(t)
+		if _, err := r.Guess("a", "b", guess); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Answer("c", NewAnswer(3, 0)); err == nil {
+			t.Error("Answer() by a player not called succeeded")
+		}
+	})
+	t.Run("too few players", func(t *testing.T) {
+		if err := NewRoom("a").Start(NewHandFromText("345"), []string{"a", "b"}); err == nil {
+			t.Error("Start() with two players succeeded")
+		}
+	})
+}
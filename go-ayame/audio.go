@@ -0,0 +1,168 @@
+//go:build !js
+// +build !js
+
+package ayame
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// audioState はローカルの音声トラックです。
+// ブラウザ以外ではマイクを扱わないので、WriteAudioSample で書き込んだサンプルを送信します。
+type audioState struct {
+	track *webrtc.TrackLocalStaticSample
+	muted bool
+	mu    sync.Mutex
+}
+
+// newAPI は Options の Codecs を登録した API を生成します。
+// Codecs の指定がない場合は pion のデフォルトのコーデックを使います。
+func (c *Connection) newAPI() (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	for _, codec := range c.Options.Audio.Codecs {
+		if err := m.RegisterCodec(*codec, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, err
+		}
+	}
+	for _, codec := range c.Options.Video.Codecs {
+		if err := m.RegisterCodec(*codec, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Options.Audio.Codecs) == 0 && len(c.Options.Video.Codecs) == 0 {
+		if err := m.RegisterDefaultCodecs(); err != nil {
+			return nil, err
+		}
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithSettingEngine(webrtc.SettingEngine{})), nil
+}
+
+// AttachLocalAudio は送信する音声トラックを作成します。
+// PeerConnection の作成前に呼び出してください。
+func (c *Connection) AttachLocalAudio() error {
+	c.audio.mu.Lock()
+	defer c.audio.mu.Unlock()
+	if c.audio.track != nil {
+		return nil
+	}
+	capability := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}
+	if len(c.Options.Audio.Codecs) > 0 {
+		capability = c.Options.Audio.Codecs[0].RTPCodecCapability
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(capability, "audio", c.Options.ClientID)
+	if err != nil {
+		return err
+	}
+	c.audio.track = track
+	return nil
+}
+
+// WriteAudioSample は音声のサンプルを送信します。ミュート中は破棄します。
+func (c *Connection) WriteAudioSample(sample media.Sample) error {
+	c.audio.mu.Lock()
+	track, muted := c.audio.track, c.audio.muted
+	c.audio.mu.Unlock()
+	if track == nil {
+		return fmt.Errorf("local audio is not attached")
+	}
+	if muted {
+		return nil
+	}
+	return track.WriteSample(sample)
+}
+
+// SetAudioMuted はローカルの音声の送信を止めたり再開したりします。
+func (c *Connection) SetAudioMuted(muted bool) {
+	c.audio.mu.Lock()
+	defer c.audio.mu.Unlock()
+	c.audio.muted = muted
+}
+
+// addAudioTransceiver は offer 側で音声のトランシーバを追加します。
+func (c *Connection) addAudioTransceiver(pc *webrtc.PeerConnection) error {
+	c.audio.mu.Lock()
+	track := c.audio.track
+	c.audio.mu.Unlock()
+	init := webrtc.RTPTransceiverInit{Direction: c.Options.Audio.Direction}
+	var t *webrtc.RTPTransceiver
+	var err error
+	if track != nil && init.Direction == webrtc.RTPTransceiverDirectionSendrecv {
+		t, err = pc.AddTransceiverFromTrack(track, init)
+	} else {
+		t, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, init)
+	}
+	if err != nil {
+		return err
+	}
+	return c.setAudioCodecPreferences(t)
+}
+
+// prepareAudioAnswer は answer の作成前に、offer の音声に送信するトラックを割り当てます。
+// Audio が無効なら inactive にして、相手が音声を有効にしていても送受信しません。
+func (c *Connection) prepareAudioAnswer() error {
+	if !c.Options.Audio.Enabled || c.Options.Audio.Direction == webrtc.RTPTransceiverDirectionInactive {
+		// pion では Stop したトランシーバが answer で inactive になります
+		for _, t := range c.pc.GetTransceivers() {
+			if t.Kind() != webrtc.RTPCodecTypeAudio {
+				continue
+			}
+			if err := t.Stop(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.audio.mu.Lock()
+	track := c.audio.track
+	c.audio.mu.Unlock()
+	if track == nil || c.Options.Audio.Direction != webrtc.RTPTransceiverDirectionSendrecv {
+		return nil
+	}
+	// offer で作成された受信用のトランシーバが再利用されます
+	if _, err := c.pc.AddTrack(track); err != nil {
+		return err
+	}
+	for _, t := range c.pc.GetTransceivers() {
+		if t.Kind() == webrtc.RTPCodecTypeAudio {
+			if err := c.setAudioCodecPreferences(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Connection) setAudioCodecPreferences(t *webrtc.RTPTransceiver) error {
+	if len(c.Options.Audio.Codecs) == 0 {
+		return nil
+	}
+	codecs := make([]webrtc.RTPCodecParameters, len(c.Options.Audio.Codecs))
+	for i, codec := range c.Options.Audio.Codecs {
+		codecs[i] = *codec
+	}
+	return t.SetCodecPreferences(codecs)
+}
+
+// handleRemoteAudio は相手の音声トラックを受け取ったら OnRemoteAudio のコールバックを呼びます。
+func (c *Connection) handleRemoteAudio(pc *webrtc.PeerConnection) {
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			return
+		}
+		c.callbackMu.Lock()
+		onRemoteAudio := c.onRemoteAudioHandler
+		c.callbackMu.Unlock()
+		onRemoteAudio()
+	})
+}
+
+func (c *Connection) stopLocalAudio() {
+	c.audio.mu.Lock()
+	defer c.audio.mu.Unlock()
+	c.audio.track = nil
+	c.audio.muted = false
+}
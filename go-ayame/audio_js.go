@@ -0,0 +1,196 @@
+//go:build js && wasm
+// +build js,wasm
+
+package ayame
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// audioState はブラウザのマイクの MediaStreamTrack と、相手の音声を再生する audio 要素です。
+type audioState struct {
+	track   js.Value
+	muted   bool
+	element js.Value
+	mu      sync.Mutex
+}
+
+// newAPI は API を生成します。
+// ブラウザではコーデックはブラウザが持つので、Codecs はトランシーバの優先順位として使います。
+func (c *Connection) newAPI() (*webrtc.API, error) {
+	return webrtc.NewAPI(webrtc.WithSettingEngine(webrtc.SettingEngine{})), nil
+}
+
+// AttachLocalAudio はマイクの使用許可を求め、送信する音声トラックを取得します。
+// 許可を待つ間ブロックするので、JS のコールバックから呼ぶ場合は goroutine で呼び出してください。
+func (c *Connection) AttachLocalAudio() error {
+	mediaDevices := js.Global().Get("navigator").Get("mediaDevices")
+	if mediaDevices.IsUndefined() {
+		return fmt.Errorf("media devices are not available")
+	}
+	done := make(chan error, 1)
+	onStream := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		tracks := args[0].Call("getAudioTracks")
+		if tracks.Length() == 0 {
+			done <- fmt.Errorf("no audio track")
+			return nil
+		}
+		c.audio.mu.Lock()
+		c.audio.track = tracks.Index(0)
+		c.audio.track.Set("enabled", !c.audio.muted)
+		c.audio.mu.Unlock()
+		done <- nil
+		return nil
+	})
+	defer onStream.Release()
+	onError := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		done <- fmt.Errorf("getUserMedia: %s", args[0].Get("message").String())
+		return nil
+	})
+	defer onError.Release()
+	constraints := map[string]interface{}{"audio": true, "video": false}
+	mediaDevices.Call("getUserMedia", constraints).Call("then", onStream).Call("catch", onError)
+	if err := <-done; err != nil {
+		return err
+	}
+	// 接続後に許可された場合は既存のトランシーバに割り当てる
+	if c.pc != nil {
+		for _, t := range audioTransceivers(c.pc) {
+			if t.Get("direction").String() == webrtc.RTPTransceiverDirectionSendrecv.String() {
+				c.attachTrack(t)
+			}
+		}
+	}
+	return nil
+}
+
+// SetAudioMuted はローカルの音声の送信を止めたり再開したりします。
+// 再ネゴシエーションせずにトラックの enabled を切り替えます。
+func (c *Connection) SetAudioMuted(muted bool) {
+	c.audio.mu.Lock()
+	defer c.audio.mu.Unlock()
+	c.audio.muted = muted
+	if !c.audio.track.IsUndefined() {
+		c.audio.track.Set("enabled", !muted)
+	}
+}
+
+// audioTransceivers は音声の RTCRtpTransceiver を返します。
+func audioTransceivers(pc *webrtc.PeerConnection) []js.Value {
+	all := pc.JSValue().Call("getTransceivers")
+	transceivers := make([]js.Value, 0, all.Length())
+	for i := 0; i < all.Length(); i++ {
+		t := all.Index(i)
+		if t.Get("receiver").Get("track").Get("kind").String() == "audio" {
+			transceivers = append(transceivers, t)
+		}
+	}
+	return transceivers
+}
+
+// addAudioTransceiver は offer 側で音声のトランシーバを追加します。
+func (c *Connection) addAudioTransceiver(pc *webrtc.PeerConnection) error {
+	if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: c.Options.Audio.Direction,
+	}); err != nil {
+		return err
+	}
+	for _, t := range audioTransceivers(pc) {
+		c.setAudioCodecPreferences(t)
+		c.attachTrack(t)
+	}
+	return nil
+}
+
+// prepareAudioAnswer は answer の作成前に、offer の音声の送受信方向を決めます。
+// Audio が無効なら inactive にして、相手が音声を有効にしていても送受信しません。
+func (c *Connection) prepareAudioAnswer() error {
+	for _, t := range audioTransceivers(c.pc) {
+		if !c.Options.Audio.Enabled {
+			t.Set("direction", webrtc.RTPTransceiverDirectionInactive.String())
+			continue
+		}
+		t.Set("direction", c.Options.Audio.Direction.String())
+		c.setAudioCodecPreferences(t)
+		c.attachTrack(t)
+	}
+	return nil
+}
+
+func (c *Connection) attachTrack(t js.Value) {
+	c.audio.mu.Lock()
+	track := c.audio.track
+	c.audio.mu.Unlock()
+	if track.IsUndefined() {
+		return
+	}
+	t.Get("sender").Call("replaceTrack", track)
+}
+
+// setAudioCodecPreferences は Codecs の MimeType の順にブラウザのコーデックを並べ替えます。
+func (c *Connection) setAudioCodecPreferences(t js.Value) {
+	if len(c.Options.Audio.Codecs) == 0 || t.Get("setCodecPreferences").IsUndefined() {
+		return
+	}
+	capabilities := js.Global().Get("RTCRtpReceiver").Call("getCapabilities", "audio")
+	if capabilities.IsNull() {
+		return
+	}
+	available := capabilities.Get("codecs")
+	preferred := make([]interface{}, 0)
+	for _, codec := range c.Options.Audio.Codecs {
+		for i := 0; i < available.Length(); i++ {
+			if strings.EqualFold(available.Index(i).Get("mimeType").String(), codec.MimeType) {
+				preferred = append(preferred, available.Index(i))
+			}
+		}
+	}
+	if len(preferred) == 0 {
+		c.logger().Warn("no supported audio codec", "codecs", len(c.Options.Audio.Codecs))
+		return
+	}
+	t.Call("setCodecPreferences", preferred)
+}
+
+// handleRemoteAudio は相手の音声トラックを audio 要素で再生し、OnRemoteAudio のコールバックを呼びます。
+func (c *Connection) handleRemoteAudio(pc *webrtc.PeerConnection) {
+	pc.JSValue().Set("ontrack", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		event := args[0]
+		if event.Get("track").Get("kind").String() != "audio" {
+			return nil
+		}
+		c.audio.mu.Lock()
+		if c.audio.element.IsUndefined() {
+			c.audio.element = js.Global().Get("document").Call("createElement", "audio")
+			c.audio.element.Set("autoplay", true)
+			js.Global().Get("document").Get("body").Call("appendChild", c.audio.element)
+		}
+		stream := js.Global().Get("MediaStream").New([]interface{}{event.Get("track")})
+		c.audio.element.Set("srcObject", stream)
+		c.audio.mu.Unlock()
+		c.callbackMu.Lock()
+		onRemoteAudio := c.onRemoteAudioHandler
+		c.callbackMu.Unlock()
+		onRemoteAudio()
+		return nil
+	}))
+}
+
+func (c *Connection) stopLocalAudio() {
+	c.audio.mu.Lock()
+	defer c.audio.mu.Unlock()
+	if !c.audio.track.IsUndefined() {
+		c.audio.track.Call("stop")
+		c.audio.track = js.Undefined()
+	}
+	if !c.audio.element.IsUndefined() {
+		c.audio.element.Call("remove")
+		c.audio.element = js.Undefined()
+	}
+	c.audio.muted = false
+}
@@ -5,23 +5,11 @@ import (
 	"github.com/pion/webrtc/v3"
 )
 
-var (
-	turnHost string
-	turnUser string
-	turnPass string
-)
-
 // DefaultOptions は Ayame 接続オプションのデフォルト値を生成して返します。
+// TURN サーバは一時的な認証情報を使うため、Dial の Strategy で直接つながらなかった場合にだけ使います。
 func DefaultOptions() *ConnectionOptions {
 	return &ConnectionOptions{
 		ICEServers: []webrtc.ICEServer{
-			// 本番環境では TURN サーバを指定した方が良さそうです
-			// {
-			// 	URLs:           []string{"turn:" + turnHost},
-			// 	Username:       turnUser,
-			// 	Credential:     turnPass,
-			// 	CredentialType: webrtc.ICECredentialTypePassword,
-			// },
 			{
 				URLs: []string{"stun:stun.l.google.com:19302"},
 			},
@@ -62,12 +50,14 @@ func NewConnection(signalingURL string, roomID string, options *ConnectionOption
 		isExistClient: false,
 
 		dataChannels: map[string]*webrtc.DataChannel{},
+		progress:     newProgress(),
 
 		onOpenHandler:        func(metadata *interface{}) {},
 		onConnectHandler:     func() {},
 		onDisconnectHandler:  func(reason string, err error) {},
 		onByeHandler:         func() {},
 		onDataChannelHandler: func(dc *webrtc.DataChannel) {},
+		onRemoteAudioHandler: func() {},
 	}
 
 	return c
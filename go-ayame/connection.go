@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/webrtc/v3"
@@ -30,7 +31,7 @@ type Connection struct {
 	// Ayame の接続オプション
 	Options *ConnectionOptions
 
-	// デバッグログの出力の可否
+	// SDP や ICE 候補などの詳しいログを Debug レベルで出力するか
 	Debug bool
 
 	// 送信する認証用のメタデータ
@@ -46,13 +47,22 @@ type Connection struct {
 	isOffer       bool
 	isExistClient bool
 
+	// dataChannels は callbackMu で守ります
 	dataChannels map[string]*webrtc.DataChannel
+	audio        audioState
+	progress     *progress
+
+	// turnFallback はつながらなかった場合にこちらが TURN だけで接続し直すかで、offer と answer で相手に伝えます
+	turnFallback bool
+	// peerTURNFallback は相手の offer または answer で伝えられた、相手が TURN だけで接続し直すかです
+	peerTURNFallback atomic.Bool
 
 	onOpenHandler        func(metadata *interface{})
 	onConnectHandler     func()
 	onDisconnectHandler  func(reason string, err error)
 	onByeHandler         func()
 	onDataChannelHandler func(dc *webrtc.DataChannel)
+	onRemoteAudioHandler func()
 
 	callbackMu sync.Mutex
 }
@@ -63,8 +73,7 @@ func (c *Connection) Connect() error {
 		c.trace("connection already exists")
 		return fmt.Errorf("connection alreay exists")
 	}
-	c.signaling()
-	return nil
+	return c.signaling()
 }
 
 // Disconnect は PeerConnection 接続を切断します。
@@ -78,6 +87,7 @@ func (c *Connection) Disconnect() {
 
 	c.closePeerConnection()
 	c.CloseWebSocketConnection()
+	c.stopLocalAudio()
 	c.authzMetadata = nil
 	c.connectionID = ""
 	c.connectionState = webrtc.ICEConnectionStateNew
@@ -91,6 +101,7 @@ func (c *Connection) Disconnect() {
 	c.onDisconnectHandler = func(reason string, err error) {}
 	c.onByeHandler = func() {}
 	c.onDataChannelHandler = func(dc *webrtc.DataChannel) {}
+	c.onRemoteAudioHandler = func() {}
 }
 
 // CreateDataChannel は指定した label と options から新しい DataChannel 作成して、追加します。
@@ -101,7 +112,10 @@ func (c *Connection) CreateDataChannel(label string, options *webrtc.DataChannel
 	if c.isOffer {
 		return nil, fmt.Errorf("PeerConnection Has Local Offer")
 	}
-	if _, ok := c.dataChannels[label]; ok {
+	c.callbackMu.Lock()
+	_, exists := c.dataChannels[label]
+	c.callbackMu.Unlock()
+	if exists {
 		return nil, fmt.Errorf("DataChannel Already Exists. label=%s", label)
 	}
 
@@ -116,13 +130,17 @@ func (c *Connection) CreateDataChannel(label string, options *webrtc.DataChannel
 		})
 		dc.OnClose(func() {
 			c.trace("datachannel OnClose")
+			c.callbackMu.Lock()
 			delete(c.dataChannels, label)
+			c.callbackMu.Unlock()
 		})
 		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
 			c.trace("datachannel OnMessage")
 		})
 
+		c.callbackMu.Lock()
 		c.dataChannels[label] = dc
+		c.callbackMu.Unlock()
 		return dc, nil
 	}
 	return nil, fmt.Errorf("client does not exist")
@@ -163,10 +181,22 @@ func (c *Connection) OnDataChannel(f func(dc *webrtc.DataChannel)) {
 	c.onDataChannelHandler = f
 }
 
-func (c *Connection) trace(format string, v ...interface{}) {
-	if c.Debug {
-		logf(format, v...)
-	}
+// OnRemoteAudio は相手の音声トラックを受信した時のコールバック関数を設定します。
+func (c *Connection) OnRemoteAudio(f func()) {
+	c.callbackMu.Lock()
+	defer c.callbackMu.Unlock()
+	c.onRemoteAudioHandler = f
+}
+
+// disconnectWithReason は切断した上で disconnect イベントを通知します
+// Disconnect はコールバック関数を初期化するため、呼び出す前に退避しておきます
+func (c *Connection) disconnectWithReason(reason string, err error) {
+	c.callbackMu.Lock()
+	onDisconnect := c.onDisconnectHandler
+	c.callbackMu.Unlock()
+	c.progress.fail(reason)
+	c.Disconnect()
+	onDisconnect(reason, err)
 }
 
 func (c *Connection) signaling() error {
@@ -192,12 +222,12 @@ func (c *Connection) signaling() error {
 }
 
 func (c *Connection) openWS(ctx context.Context) (*websocket.Conn, error) {
-	c.trace("Connecting to %s", c.SignalingURL)
+	c.trace("connecting to signaling server", "url", c.SignalingURL)
 	conn, _, err := websocket.Dial(ctx, c.SignalingURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	c.trace("Connected to %s", c.SignalingURL)
+	c.trace("connected to signaling server", "url", c.SignalingURL)
 	return conn, nil
 }
 
@@ -205,9 +235,9 @@ func (c *Connection) sendMsg(v interface{}) error {
 	if c.ws != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
 		defer cancel()
-		c.trace("send %v", v)
+		c.trace("send message", "type", fmt.Sprintf("%T", v))
 		if err := wsjson.Write(ctx, c.ws, v); err != nil {
-			c.trace("failed to send %v: %v", v, err)
+			c.logger().Warn("failed to send message", "type", fmt.Sprintf("%T", v), "err", err)
 			return err
 		}
 	}
@@ -250,27 +280,29 @@ func (c *Connection) sendRegisterMessage() error {
 }
 
 func (c *Connection) sendSdp(sessionDescription *webrtc.SessionDescription) {
-	c.sendMsg(sessionDescription)
+	c.sendMsg(sdpMessage{SessionDescription: *sessionDescription, TURNFallback: c.turnFallback})
 }
 
 func (c *Connection) createPeerConnection() error {
-	s := webrtc.SettingEngine{}
-	api := webrtc.NewAPI(webrtc.WithSettingEngine(s))
+	api, err := c.newAPI()
+	if err != nil {
+		return err
+	}
 
-	c.trace("RTCConfiguration: %v", c.pcConfig)
+	c.trace("create peer connection", "ice_servers", iceServerURLs(c.pcConfig.ICEServers), "ice_transport_policy", c.pcConfig.ICETransportPolicy.String())
 	pc, err := api.NewPeerConnection(c.pcConfig)
 	if err != nil {
 		return err
 	}
 
-	if c.Options.Audio.Enabled {
-		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RtpTransceiverInit{
-			Direction: c.Options.Audio.Direction,
-		})
-		if err != nil {
+	// 音声は offer 側だけがトランシーバを追加し、answer 側は offer に合わせて prepareAudioAnswer で送受信方向を決めます
+	// 双方が Audio を有効にしている場合だけ音声がやり取りされます
+	if c.Options.Audio.Enabled && c.isExistClient {
+		if err := c.addAudioTransceiver(pc); err != nil {
 			return err
 		}
 	}
+	c.handleRemoteAudio(pc)
 
 	if c.Options.Video.Enabled {
 		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RtpTransceiverInit{
@@ -284,7 +316,7 @@ func (c *Connection) createPeerConnection() error {
 	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
 		if candidate != nil {
 			json := candidate.ToJSON()
-			c.trace("ICE candidate: %v", json)
+			c.trace("ICE candidate", "candidate", json.Candidate)
 			msg := candidateMessage{
 				Type:         "candidate",
 				ICECandidate: &json,
@@ -295,24 +327,24 @@ func (c *Connection) createPeerConnection() error {
 	// Set the Handler for ICE connection state
 	// This will notify you when the peer has connected/disconnected
 	pc.OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
-		c.trace("ICE connection Status has changed to %s", connectionState.String())
+		c.logger().Info("ICE connection state changed", "state", connectionState.String())
 		if c.connectionState != connectionState {
 			c.connectionState = connectionState
 			switch c.connectionState {
 			case webrtc.ICEConnectionStateConnected:
 				c.isOffer = false
+				c.progress.connect()
 				c.onConnectHandler()
 			case webrtc.ICEConnectionStateDisconnected:
 				fallthrough
 			case webrtc.ICEConnectionStateFailed:
-				c.Disconnect()
-				c.onDisconnectHandler("ICE-CONNECTION-STATE-FAILED", nil)
+				c.disconnectWithReason("ICE-CONNECTION-STATE-FAILED", nil)
 			}
 		}
 	})
 	// Set the Handler for Signaling connection state
 	pc.OnSignalingStateChange(func(signalingState webrtc.SignalingState) {
-		c.trace("signaling state changes: %s", signalingState.String())
+		c.trace("signaling state changed", "state", signalingState.String())
 	})
 
 	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
@@ -337,12 +369,13 @@ func (c *Connection) sendOffer() error {
 	if err != nil {
 		return err
 	}
-	c.trace("create offer sdp=%s", offer.SDP)
+	c.trace("create offer", "sdp", offer.SDP)
 	c.pc.SetLocalDescription(offer)
 	if c.pc.LocalDescription() != nil {
 		c.sendSdp(c.pc.LocalDescription())
 	}
 	c.isOffer = true
+	c.progress.negotiate()
 	return nil
 }
 
@@ -353,11 +386,10 @@ func (c *Connection) createAnswer() error {
 
 	answer, err := c.pc.CreateAnswer(nil)
 	if err != nil {
-		c.Disconnect()
-		c.onDisconnectHandler("CREATE-ANSWER-ERROR", err)
+		c.disconnectWithReason("CREATE-ANSWER-ERROR", err)
 		return err
 	}
-	c.trace("create answer sdp=%s", answer.SDP)
+	c.trace("create answer", "sdp", answer.SDP)
 	c.pc.SetLocalDescription(answer)
 	if c.pc.LocalDescription() != nil {
 		c.sendSdp(c.pc.LocalDescription())
@@ -373,7 +405,7 @@ func (c *Connection) setAnswer(sessionDescription webrtc.SessionDescription) err
 	if err != nil {
 		return err
 	}
-	c.trace("set answer sdp=%s", sessionDescription.SDP)
+	c.trace("set answer", "sdp", sessionDescription.SDP)
 	return nil
 }
 
@@ -383,11 +415,13 @@ func (c *Connection) setOffer(sessionDescription webrtc.SessionDescription) erro
 	}
 	err := c.pc.SetRemoteDescription(sessionDescription)
 	if err != nil {
-		c.Disconnect()
-		c.onDisconnectHandler("CREATE-OFFER-ERROR", err)
+		c.disconnectWithReason("CREATE-OFFER-ERROR", err)
 		return err
 	}
-	c.trace("set offer sdp=%s", sessionDescription.SDP)
+	c.trace("set offer", "sdp", sessionDescription.SDP)
+	if err := c.prepareAudioAnswer(); err != nil {
+		c.logger().Warn("failed to prepare audio", "err", err)
+	}
 	err = c.createAnswer()
 	if err != nil {
 		return err
@@ -402,14 +436,14 @@ func (c *Connection) addICECandidate(candidate webrtc.ICECandidateInit) {
 	err := c.pc.AddICECandidate(candidate)
 	if err != nil {
 		// ignore error
-		c.trace("invalid ice candidate, %v", candidate)
+		c.logger().Warn("invalid ICE candidate", "candidate", candidate.Candidate, "err", err)
 		return
 	}
 }
 
 func (c *Connection) onDataChannel(dc *webrtc.DataChannel) {
 	label := dc.Label()
-	c.trace("on data channel, label='%s'", label)
+	c.trace("on data channel", "label", label)
 	if c.pc == nil {
 		return
 	}
@@ -430,11 +464,14 @@ func (c *Connection) onDataChannel(dc *webrtc.DataChannel) {
 		c.trace("datachannel OnMessage")
 	})
 
+	c.callbackMu.Lock()
 	if _, ok := c.dataChannels[label]; !ok {
 		c.dataChannels[label] = dc
 	}
+	onDataChannel := c.onDataChannelHandler
+	c.callbackMu.Unlock()
 
-	c.onDataChannelHandler(dc)
+	onDataChannel(dc)
 }
 
 func (c *Connection) closeDataChannel(dc *webrtc.DataChannel) {
@@ -521,7 +558,7 @@ loop:
 		_, rawMessage, err := c.ws.Read(cctx)
 		cancel()
 		if err != nil {
-			c.trace("failed to ReadMessage: %v", err)
+			c.trace("failed to read message", "err", err)
 			break loop
 		}
 		messageChannel <- rawMessage
@@ -532,6 +569,7 @@ loop:
 	c.trace("EXITED-MAIN")
 	//  even if the Signaling server is down, there is no need to close the DataChannel.
 	// c.Disconnect()
+	c.progress.fail("EXIT-RECV")
 	c.onDisconnectHandler("EXIT-RECV", nil)
 	c.trace("EXIT-RECV")
 }
@@ -539,11 +577,12 @@ loop:
 func (c *Connection) handleMessage(rawMessage []byte) error {
 	message := &message{}
 	if err := json.Unmarshal(rawMessage, &message); err != nil {
-		c.trace("invalid JSON, rawMessage: %s, error: %v", rawMessage, err)
+		c.logger().Warn("invalid JSON", "size", len(rawMessage), "err", err)
 		return errorInvalidJSON
 	}
 
-	c.trace("recv type: %s, rawMessage: %s", message.Type, string(rawMessage))
+	// accept は TURN の認証情報を含むので、メッセージ自体は出力しない
+	c.trace("recv message", "type", message.Type)
 
 	switch message.Type {
 	case "ping":
@@ -558,7 +597,7 @@ func (c *Connection) handleMessage(rawMessage []byte) error {
 		c.connectionID = acceptMsg.ConnectionID
 		c.authzMetadata = acceptMsg.AuthzMetadata
 		if acceptMsg.IceServers != nil && len(*acceptMsg.IceServers) != 0 {
-			c.trace("IceServers: %v", *acceptMsg.IceServers)
+			c.trace("ICE servers from signaling server", "count", len(*acceptMsg.IceServers))
 			iceServers := make([]webrtc.ICEServer, len(*acceptMsg.IceServers))
 			for i, s := range *acceptMsg.IceServers {
 				iceServers[i] = webrtc.ICEServer{
@@ -572,9 +611,13 @@ func (c *Connection) handleMessage(rawMessage []byte) error {
 					iceServers[i].CredentialType = webrtc.ICECredentialTypePassword
 				}
 			}
+			// TURN だけで接続し直す場合は、Ayame の ICEServer に加えて取得した TURN サーバを使う
+			if c.pcConfig.ICETransportPolicy == webrtc.ICETransportPolicyRelay {
+				iceServers = append(iceServers, c.Options.ICEServers...)
+			}
 			c.pcConfig.ICEServers = iceServers
 		}
-		c.trace("isExistClient: %v", acceptMsg.IsExistClient)
+		c.logger().Info("accepted", "is_exist_client", acceptMsg.IsExistClient)
 		c.isExistClient = acceptMsg.IsExistClient
 		c.createPeerConnection()
 		if c.isExistClient {
@@ -585,39 +628,41 @@ func (c *Connection) handleMessage(rawMessage []byte) error {
 		if err := unmarshalMessage(c, rawMessage, &rejectMsg); err != nil {
 			return err
 		}
-		c.trace("rejected, reason: %s", rejectMsg.Reason)
+		c.logger().Warn("rejected", "reason", rejectMsg.Reason)
 		rejectReason := rejectMsg.Reason
 		if rejectReason == "" {
 			rejectReason = "REJECTED"
 		}
-		c.Disconnect()
-		c.onDisconnectHandler(rejectReason, nil)
+		c.disconnectWithReason(rejectReason, nil)
 	case "offer":
-		offerMsg := webrtc.SessionDescription{}
+		offerMsg := sdpMessage{}
 		if err := unmarshalMessage(c, rawMessage, &offerMsg); err != nil {
 			return err
 		}
+		c.peerTURNFallback.Store(offerMsg.TURNFallback)
 		if c.pc != nil && c.pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
 			c.createPeerConnection()
 		}
-		return c.setOffer(offerMsg)
+		c.progress.negotiate()
+		return c.setOffer(offerMsg.SessionDescription)
 	case "answer":
-		answerMsg := webrtc.SessionDescription{}
+		answerMsg := sdpMessage{}
 		if err := unmarshalMessage(c, rawMessage, &answerMsg); err != nil {
 			return err
 		}
-		return c.setAnswer(answerMsg)
+		c.peerTURNFallback.Store(answerMsg.TURNFallback)
+		return c.setAnswer(answerMsg.SessionDescription)
 	case "candidate":
 		candidateMsg := candidateMessage{}
 		if err := unmarshalMessage(c, rawMessage, &candidateMsg); err != nil {
 			return err
 		}
 		if candidateMsg.ICECandidate != nil {
-			c.trace("Received ICE candidate: %v", *candidateMsg.ICECandidate)
+			c.trace("received ICE candidate", "candidate", candidateMsg.ICECandidate.Candidate)
 			c.addICECandidate(*candidateMsg.ICECandidate)
 		}
 	default:
-		c.trace("invalid message type %s", message.Type)
+		c.logger().Warn("invalid message type", "type", message.Type)
 		return errorInvalidMessageType
 	}
 	return nil
@@ -0,0 +1,153 @@
+package ayame
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// DefaultDeadline はネゴシエーションを始めてから相手とつながるまでの期限です
+const DefaultDeadline = 15 * time.Second
+
+var (
+	ErrConnectTimeout = errors.New("connection timed out")
+	ErrICEFailed      = errors.New("ICE connection failed")
+)
+
+// Path は相手とつながった経路です
+type Path string
+
+const (
+	// PathDirect はホストや STUN で得た候補で相手と直接つながった経路です
+	PathDirect Path = "direct"
+	// PathTURN は TURN サーバが中継する経路です
+	PathTURN Path = "turn"
+)
+
+// Strategy は接続の手順です
+// まずすべての候補で接続し、Deadline までにつながらなければ Credentials で得た TURN サーバだけで接続し直します
+// 接続し直すのは両者が Credentials を持っている場合だけで、offer と answer で互いに伝えて決めます
+// 片方だけが入り直して相手を待ち続けないよう、どちらかが持っていなければ両者とも接続し直さずにエラーを返します
+type Strategy struct {
+	// ネゴシエーションを始めてから相手とつながるまでの期限
+	// 相手の入室を待つ間は含みません
+	Deadline time.Duration
+
+	// TURN サーバの一時的な認証情報を取得します。nil の場合は接続し直しません
+	Credentials func() ([]webrtc.ICEServer, error)
+}
+
+// progress は Dial が接続の進み具合を待つための通知です
+type progress struct {
+	negotiating chan struct{}
+	connected   chan struct{}
+	failed      chan struct{}
+	reason      string
+
+	negotiateOnce sync.Once
+	connectOnce   sync.Once
+	failOnce      sync.Once
+}
+
+func newProgress() *progress {
+	return &progress{
+		negotiating: make(chan struct{}),
+		connected:   make(chan struct{}),
+		failed:      make(chan struct{}),
+	}
+}
+
+func (p *progress) negotiate() {
+	p.negotiateOnce.Do(func() { close(p.negotiating) })
+}
+
+func (p *progress) connect() {
+	p.connectOnce.Do(func() { close(p.connected) })
+}
+
+func (p *progress) fail(reason string) {
+	p.failOnce.Do(func() {
+		p.reason = reason
+		close(p.failed)
+	})
+}
+
+func (p *progress) err() error {
+	if p.reason == "ICE-CONNECTION-STATE-FAILED" {
+		return ErrICEFailed
+	}
+	return fmt.Errorf("disconnected: %s", p.reason)
+}
+
+// Dial は strategy に従って roomID のルームの相手とつながり、Connection とつながった経路を返します
+// setup は Connection を作るたびに Connect の前に呼ばれるので、コールバック関数はそこで設定してください
+// 相手とつながるまでブロックします
+func Dial(signalingURL, roomID string, options *ConnectionOptions, debug bool, strategy Strategy, setup func(c *Connection)) (*Connection, Path, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if strategy.Deadline == 0 {
+		strategy.Deadline = DefaultDeadline
+	}
+	c := NewConnection(signalingURL, roomID, options, debug, false)
+	c.turnFallback = strategy.Credentials != nil
+	setup(c)
+	err := c.connectWithin(strategy.Deadline)
+	if err == nil {
+		return c, PathDirect, nil
+	}
+	c.Disconnect()
+	if !(errors.Is(err, ErrConnectTimeout) || errors.Is(err, ErrICEFailed)) {
+		return nil, "", err
+	}
+	if !c.turnFallback || !c.peerTURNFallback.Load() {
+		c.logger().Info("direct connection failed, skip TURN because either side has no credentials", "err", err, "self", c.turnFallback, "peer", c.peerTURNFallback.Load())
+		return nil, "", err
+	}
+	c.logger().Info("direct connection failed, retry with TURN", "err", err)
+
+	iceServers, err := strategy.Credentials()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get TURN credentials: %w", err)
+	}
+	relayOptions := *options
+	relayOptions.ICEServers = iceServers
+	// 前の接続の登録が残っていても入室できるように、別のクライアントとして入り直す
+	relayOptions.ClientID = getULID()
+	c = NewConnection(signalingURL, roomID, &relayOptions, debug, true)
+	c.turnFallback = true
+	setup(c)
+	if err := c.connectWithin(strategy.Deadline); err != nil {
+		c.Disconnect()
+		return nil, "", err
+	}
+	return c, PathTURN, nil
+}
+
+// connectWithin は接続を始め、ネゴシエーションを始めてから deadline までにつながるのを待ちます
+func (c *Connection) connectWithin(deadline time.Duration) error {
+	p := c.progress
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	select {
+	case <-p.negotiating:
+	case <-p.connected:
+		return nil
+	case <-p.failed:
+		return p.err()
+	}
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	select {
+	case <-p.connected:
+		return nil
+	case <-p.failed:
+		return p.err()
+	case <-timer.C:
+		return ErrConnectTimeout
+	}
+}
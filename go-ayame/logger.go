@@ -1,30 +1,47 @@
 package ayame
 
-// 参考: Golang でログを吐くコツ https://www.kaoriya.net/blog/2018/12/16/
-
 import (
-	"log"
-	"os"
+	"log/slog"
 	"sync"
 )
 
 var (
-	logger = log.New(os.Stderr, "", log.LstdFlags)
+	// logger が nil の場合は slog.Default() に出力します
+	logger *slog.Logger
 	logMu  sync.Mutex
 )
 
-// SetLogger は ayame パッケージ内で出力される *log.Logger を任意のものに設定します。
-func SetLogger(l *log.Logger) {
-	if l == nil {
-		l = log.New(os.Stderr, "", log.LstdFlags)
-	}
+// SetLogger は ayame パッケージ内で出力される *slog.Logger を任意のものに設定します。
+// nil を設定すると slog.Default() に戻ります。
+func SetLogger(l *slog.Logger) {
 	logMu.Lock()
 	logger = l
 	logMu.Unlock()
 }
 
-func logf(format string, v ...interface{}) {
+func packageLogger() *slog.Logger {
 	logMu.Lock()
-	logger.Printf(format, v...)
-	logMu.Unlock()
+	defer logMu.Unlock()
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// logger は接続を特定できる属性を持つ Logger を返します
+// connectionID は accept を受け取るまで空です
+func (c *Connection) logger() *slog.Logger {
+	return packageLogger().With(
+		"component", "ayame",
+		"room_id", c.RoomID,
+		"client_id", c.Options.ClientID,
+		"connection_id", c.connectionID,
+	)
+}
+
+// trace は Debug が有効な場合だけ、SDP や ICE 候補などの詳しいログを Debug レベルで出力します
+func (c *Connection) trace(msg string, args ...any) {
+	if c.Debug {
+		c.logger().Debug(msg, args...)
+	}
 }
@@ -37,7 +37,8 @@ type ConnectionVideoOption struct {
 
 // ConnectionAudioOption は Audio に関数するオプションです。
 type ConnectionAudioOption struct {
-	// 送受信方向。現在、'recvonly' のみサポート
+	// 送受信方向。'sendrecv' と 'recvonly' をサポート
+	// 送信するには接続前に Connection.AttachLocalAudio でトラックを用意します
 	Direction webrtc.RTPTransceiverDirection
 
 	// 有効かどうかのフラグ
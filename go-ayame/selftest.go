@@ -0,0 +1,150 @@
+package ayame
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// DefaultSelfTestSTUNURLs は自己診断で問い合わせる STUN サーバです
+// 変換後のアドレスを比べるので2つ以上必要です
+var DefaultSelfTestSTUNURLs = []string{
+	"stun:stun.l.google.com:19302",
+	"stun:stun1.l.google.com:19302",
+}
+
+// NATType は自己診断で推定した NAT の種類です
+type NATType string
+
+const (
+	// NATNone は NAT を通らずにグローバルなアドレスを持っています
+	NATNone NATType = "none"
+	// NATCone は宛先によらず同じアドレスに変換され、直接つながりやすい NAT です
+	NATCone NATType = "cone"
+	// NATSymmetric は宛先ごとに異なるアドレスに変換され、直接つながりにくく TURN が必要になりやすい NAT です
+	NATSymmetric NATType = "symmetric"
+	// NATBlocked は STUN サーバに届かず、UDP が塞がれている可能性があります
+	NATBlocked NATType = "blocked"
+	NATUnknown NATType = "unknown"
+)
+
+// SelfTestResult は対局前の接続の自己診断の結果です
+type SelfTestResult struct {
+	STUNReachable bool
+	NAT           NATType
+	// Candidates は集まった候補で、不具合の報告に使います
+	Candidates []string
+	Duration   time.Duration
+}
+
+func (r *SelfTestResult) String() string {
+	return fmt.Sprintf("stun=%t nat=%s candidates=%d duration=%s", r.STUNReachable, r.NAT, len(r.Candidates), r.Duration.Round(time.Millisecond))
+}
+
+// SelfTest は STUN サーバに問い合わせて、到達できるかと NAT の種類を調べます
+// 候補の収集が終わるか timeout が過ぎるまでブロックします
+func SelfTest(stunURLs []string, timeout time.Duration) (*SelfTestResult, error) {
+	if len(stunURLs) == 0 {
+		stunURLs = DefaultSelfTestSTUNURLs
+	}
+	start := time.Now()
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{
+		ICEServers: []webrtc.ICEServer{{URLs: stunURLs}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+
+	var (
+		candidates []webrtc.ICECandidate
+		mu         sync.Mutex
+	)
+	gathered := make(chan struct{})
+	var once sync.Once
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			once.Do(func() { close(gathered) })
+			return
+		}
+		mu.Lock()
+		candidates = append(candidates, *candidate)
+		mu.Unlock()
+	})
+	// 候補を集めるには何か1つ送受信するものが必要
+	if _, err := pc.CreateDataChannel("selftest", nil); err != nil {
+		return nil, err
+	}
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return nil, err
+	}
+	select {
+	case <-gathered:
+	case <-time.After(timeout):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	result := &SelfTestResult{NAT: classifyNAT(candidates), Duration: time.Since(start)}
+	for _, c := range candidates {
+		if c.Typ == webrtc.ICECandidateTypeSrflx {
+			result.STUNReachable = true
+		}
+		result.Candidates = append(result.Candidates, c.String())
+	}
+	return result, nil
+}
+
+// classifyNAT は STUN サーバごとの変換後のアドレスを比べて NAT の種類を推定します
+// 同じソケットから問い合わせた候補で、変換後のポートが STUN サーバごとに異なれば symmetric です
+// ブラウザは変換前のアドレスを 0.0.0.0:0 として隠すので、その場合は変換後の IP アドレスが同じ候補を同じソケットのものとみなします
+// ブラウザは変換後のアドレスが同じ候補を1つにまとめるため、srflx 候補が2つ未満の場合は比べられず unknown です
+// そのためブラウザでは cone の NAT も unknown になり、NATCone は返りません
+// また変換前のアドレスが分からないので、1つの cone の NAT の内側にある2つのソケットの候補は symmetric と誤って推定します
+func classifyNAT(candidates []webrtc.ICECandidate) NATType {
+	hosts := map[string]bool{}
+	for _, c := range candidates {
+		if c.Typ == webrtc.ICECandidateTypeHost {
+			hosts[c.Address] = true
+		}
+	}
+	// mappings は送信元のソケットごとの変換後のアドレスです
+	mappings := map[string]map[string]bool{}
+	srflx := map[string]bool{}
+	for _, c := range candidates {
+		if c.Protocol != webrtc.ICEProtocolUDP || c.Typ != webrtc.ICECandidateTypeSrflx {
+			continue
+		}
+		if hosts[c.Address] || c.Address == c.RelatedAddress {
+			return NATNone
+		}
+		source := c.Address
+		if c.RelatedAddress != "" && c.RelatedAddress != "0.0.0.0" && c.RelatedPort != 0 {
+			source = fmt.Sprintf("%s:%d", c.RelatedAddress, c.RelatedPort)
+		}
+		if mappings[source] == nil {
+			mappings[source] = map[string]bool{}
+		}
+		mapped := fmt.Sprintf("%s:%d", c.Address, c.Port)
+		mappings[source][mapped] = true
+		srflx[mapped] = true
+	}
+	if len(srflx) == 0 {
+		return NATBlocked
+	}
+	for _, mapped := range mappings {
+		if len(mapped) > 1 {
+			return NATSymmetric
+		}
+	}
+	if len(srflx) < 2 {
+		return NATUnknown
+	}
+	return NATCone
+}
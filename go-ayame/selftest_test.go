@@ -0,0 +1,124 @@
+package ayame
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+func hostCandidate(address string, port uint16) webrtc.ICECandidate {
+	return webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Protocol: webrtc.ICEProtocolUDP, Address: address, Port: port}
+}
+
+// srflxCandidate は related から address に変換された候補です
+// ブラウザは related を 0.0.0.0:0 として隠します
+func srflxCandidate(address string, port uint16, related string, relatedPort uint16) webrtc.ICECandidate {
+	return webrtc.ICECandidate{
+		Typ:            webrtc.ICECandidateTypeSrflx,
+		Protocol:       webrtc.ICEProtocolUDP,
+		Address:        address,
+		Port:           port,
+		RelatedAddress: related,
+		RelatedPort:    relatedPort,
+	}
+}
+
+func TestClassifyNAT(t *testing.T) {
+	tcp := srflxCandidate("203.0.113.1", 6000, "192.168.1.2", 5000)
+	tcp.Protocol = webrtc.ICEProtocolTCP
+	tests := []struct {
+		name       string
+		candidates []webrtc.ICECandidate
+		want       NATType
+	}{
+		{
+			name:       "no srflx",
+			candidates: []webrtc.ICECandidate{hostCandidate("192.168.1.2", 5000)},
+			want:       NATBlocked,
+		},
+		{
+			name:       "only tcp srflx",
+			candidates: []webrtc.ICECandidate{hostCandidate("192.168.1.2", 5000), tcp},
+			want:       NATBlocked,
+		},
+		{
+			name: "global host address",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("203.0.113.1", 5000),
+				srflxCandidate("203.0.113.1", 5000, "203.0.113.1", 5000),
+			},
+			want: NATNone,
+		},
+		{
+			name: "mapped address of a hidden host",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("abcd.local", 5000),
+				srflxCandidate("203.0.113.1", 5000, "203.0.113.1", 5000),
+			},
+			want: NATNone,
+		},
+		{
+			name: "native symmetric",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("192.168.1.2", 5000),
+				srflxCandidate("203.0.113.1", 6000, "192.168.1.2", 5000),
+				srflxCandidate("203.0.113.1", 6001, "192.168.1.2", 5000),
+			},
+			want: NATSymmetric,
+		},
+		{
+			name: "native cone with two sockets",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("192.168.1.2", 5000),
+				hostCandidate("10.0.0.2", 5001),
+				srflxCandidate("203.0.113.1", 6000, "192.168.1.2", 5000),
+				srflxCandidate("203.0.113.1", 6001, "10.0.0.2", 5001),
+			},
+			want: NATCone,
+		},
+		{
+			name: "native single mapping",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("192.168.1.2", 5000),
+				srflxCandidate("203.0.113.1", 6000, "192.168.1.2", 5000),
+			},
+			want: NATUnknown,
+		},
+		{
+			name: "browser symmetric",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("abcd.local", 5000),
+				srflxCandidate("203.0.113.1", 6000, "0.0.0.0", 0),
+				srflxCandidate("203.0.113.1", 6001, "0.0.0.0", 0),
+			},
+			want: NATSymmetric,
+		},
+		{
+			// 同じ変換後のアドレスが1つにまとめられるので比べられない
+			name: "browser cone",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("abcd.local", 5000),
+				srflxCandidate("203.0.113.1", 6000, "0.0.0.0", 0),
+			},
+			want: NATUnknown,
+		},
+		{
+			// 変換前のアドレスが隠されるので、2つのソケットの候補を区別できずに symmetric と推定する
+			name: "browser cone with two sockets",
+			candidates: []webrtc.ICECandidate{
+				hostCandidate("abcd.local", 5000),
+				hostCandidate("efgh.local", 5001),
+				srflxCandidate("203.0.113.1", 6000, "0.0.0.0", 0),
+				srflxCandidate("203.0.113.1", 6001, "0.0.0.0", 0),
+			},
+			want: NATSymmetric,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyNAT(tt.candidates); got != tt.want {
+				t.Errorf("classifyNAT() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
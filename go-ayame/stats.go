@@ -0,0 +1,89 @@
+package ayame
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+var ErrNotConnected = errors.New("not connected")
+
+// Stats は接続の品質の概要です
+type Stats struct {
+	ICEConnectionState webrtc.ICEConnectionState
+	// SelectedPair は相手とのやり取りに選ばれた候補のペアで、まだ選ばれていなければ nil です
+	SelectedPair  *CandidatePairStats
+	BytesSent     uint64
+	BytesReceived uint64
+	DataChannels  []DataChannelStats
+}
+
+// CandidatePairStats は選ばれた候補のペアです
+// 種類が relay なら TURN サーバを経由しています
+type CandidatePairStats struct {
+	LocalType  webrtc.ICECandidateType
+	RemoteType webrtc.ICECandidateType
+	Protocol   string
+	RTT        time.Duration
+}
+
+type DataChannelStats struct {
+	Label          string
+	State          webrtc.DataChannelState
+	BufferedAmount uint64
+}
+
+// Path は選ばれた候補のペアから経路を返します
+func (s *Stats) Path() Path {
+	if s.SelectedPair != nil && (s.SelectedPair.LocalType == webrtc.ICECandidateTypeRelay || s.SelectedPair.RemoteType == webrtc.ICECandidateTypeRelay) {
+		return PathTURN
+	}
+	return PathDirect
+}
+
+// String は不具合の報告に貼れる1行の要約です
+func (s *Stats) String() string {
+	parts := []string{"ice=" + s.ICEConnectionState.String()}
+	if p := s.SelectedPair; p != nil {
+		parts = append(parts,
+			fmt.Sprintf("pair=%s/%s/%s", p.LocalType, p.RemoteType, p.Protocol),
+			fmt.Sprintf("rtt=%s", p.RTT.Round(time.Millisecond)))
+	}
+	parts = append(parts, fmt.Sprintf("sent=%dB", s.BytesSent), fmt.Sprintf("recv=%dB", s.BytesReceived))
+	for _, dc := range s.DataChannels {
+		parts = append(parts, fmt.Sprintf("dc[%s]=%s/%dB", dc.Label, dc.State, dc.BufferedAmount))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Stats は接続の品質を集計して返します
+// ブラウザでは getStats の結果を待つ間ブロックするので、JS のコールバックから呼ぶ場合は goroutine で呼び出してください
+func (c *Connection) Stats() (*Stats, error) {
+	pc := c.pc
+	if pc == nil {
+		return nil, ErrNotConnected
+	}
+	s, err := collectStats(pc)
+	if err != nil {
+		return nil, err
+	}
+	s.ICEConnectionState = c.connectionState
+
+	c.callbackMu.Lock()
+	for label, dc := range c.dataChannels {
+		s.DataChannels = append(s.DataChannels, DataChannelStats{
+			Label:          label,
+			State:          dc.ReadyState(),
+			BufferedAmount: dc.BufferedAmount(),
+		})
+	}
+	c.callbackMu.Unlock()
+	sort.Slice(s.DataChannels, func(i, j int) bool {
+		return s.DataChannels[i].Label < s.DataChannels[j].Label
+	})
+	return s, nil
+}
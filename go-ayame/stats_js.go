@@ -0,0 +1,100 @@
+//go:build js && wasm
+// +build js,wasm
+
+package ayame
+
+import (
+	"fmt"
+	"syscall/js"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// collectStats はブラウザの getStats から選ばれた候補のペアと転送量を取り出します
+func collectStats(pc *webrtc.PeerConnection) (*Stats, error) {
+	report, err := await(pc.JSValue().Call("getStats"))
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]js.Value{}
+	var selectedID string
+	forEach := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		stat := args[0]
+		stats[stat.Get("id").String()] = stat
+		if stat.Get("type").String() == "transport" && stat.Get("selectedCandidatePairId").Truthy() {
+			selectedID = stat.Get("selectedCandidatePairId").String()
+		}
+		return nil
+	})
+	report.Call("forEach", forEach)
+	forEach.Release()
+
+	// Firefox は transport に選ばれたペアを持たないので、ペアの selected を見る
+	if selectedID == "" {
+		for id, stat := range stats {
+			if stat.Get("type").String() == "candidate-pair" && (stat.Get("selected").Truthy() ||
+				(stat.Get("nominated").Truthy() && stat.Get("state").String() == "succeeded")) {
+				selectedID = id
+				break
+			}
+		}
+	}
+	s := &Stats{}
+	pair, ok := stats[selectedID]
+	if !ok {
+		return s, nil
+	}
+	local, remote := stats[pair.Get("localCandidateId").String()], stats[pair.Get("remoteCandidateId").String()]
+	s.SelectedPair = &CandidatePairStats{
+		LocalType:  candidateType(local),
+		RemoteType: candidateType(remote),
+		Protocol:   stringOrEmpty(local, "protocol"),
+		RTT:        time.Duration(numberOrZero(pair, "currentRoundTripTime") * float64(time.Second)),
+	}
+	s.BytesSent = uint64(numberOrZero(pair, "bytesSent"))
+	s.BytesReceived = uint64(numberOrZero(pair, "bytesReceived"))
+	return s, nil
+}
+
+// await は Promise の結果を待ちます
+func await(promise js.Value) (js.Value, error) {
+	done := make(chan js.Value, 1)
+	failed := make(chan error, 1)
+	then := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		done <- args[0]
+		return nil
+	})
+	defer then.Release()
+	catch := js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		failed <- fmt.Errorf("%s", args[0].Call("toString").String())
+		return nil
+	})
+	defer catch.Release()
+	promise.Call("then", then).Call("catch", catch)
+	select {
+	case v := <-done:
+		return v, nil
+	case err := <-failed:
+		return js.Undefined(), err
+	}
+}
+
+func candidateType(stat js.Value) webrtc.ICECandidateType {
+	t, _ := webrtc.NewICECandidateType(stringOrEmpty(stat, "candidateType"))
+	return t
+}
+
+func stringOrEmpty(stat js.Value, key string) string {
+	if stat.IsUndefined() || stat.Get(key).IsUndefined() {
+		return ""
+	}
+	return stat.Get(key).String()
+}
+
+func numberOrZero(stat js.Value, key string) float64 {
+	if stat.IsUndefined() || stat.Get(key).Type() != js.TypeNumber {
+		return 0
+	}
+	return stat.Get(key).Float()
+}
@@ -0,0 +1,46 @@
+//go:build !js
+// +build !js
+
+package ayame
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// collectStats は pion の GetStats から選ばれた候補のペアと転送量を取り出します
+func collectStats(pc *webrtc.PeerConnection) (*Stats, error) {
+	report := pc.GetStats()
+	s := &Stats{}
+	var sctpRTT float64
+	for _, stats := range report {
+		switch stats := stats.(type) {
+		case webrtc.TransportStats:
+			s.BytesSent, s.BytesReceived = stats.BytesSent, stats.BytesReceived
+		case webrtc.SCTPTransportStats:
+			sctpRTT = stats.SmoothedRoundTripTime
+		case webrtc.ICECandidatePairStats:
+			if !stats.Nominated || stats.State != webrtc.StatsICECandidatePairStateSucceeded {
+				continue
+			}
+			local, _ := report[stats.LocalCandidateID].(webrtc.ICECandidateStats)
+			remote, _ := report[stats.RemoteCandidateID].(webrtc.ICECandidateStats)
+			s.SelectedPair = &CandidatePairStats{
+				LocalType:  local.CandidateType,
+				RemoteType: remote.CandidateType,
+				Protocol:   local.Protocol,
+				RTT:        seconds(stats.CurrentRoundTripTime),
+			}
+		}
+	}
+	// pion は候補のペアの RTT を測っていないことがあるので、SCTP の RTT で補う
+	if s.SelectedPair != nil && s.SelectedPair.RTT == 0 {
+		s.SelectedPair.RTT = seconds(sctpRTT)
+	}
+	return s, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
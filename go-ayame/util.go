@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/oklog/ulid/v2"
+	"github.com/pion/webrtc/v3"
 )
 
 func getULID() string {
@@ -16,12 +17,22 @@ func getULID() string {
 
 func unmarshalMessage(c *Connection, rawMessage []byte, v interface{}) error {
 	if err := json.Unmarshal(rawMessage, v); err != nil {
-		c.trace("invalid JSON, rawMessage: %s, error: %v", rawMessage, err)
+		// 認証情報を含むことがあるので、メッセージ自体は出力しない
+		c.logger().Warn("invalid JSON", "size", len(rawMessage), "err", err)
 		return errorInvalidJSON
 	}
 	return nil
 }
 
+// iceServerURLs は認証情報を除いた ICE サーバの URL です
+func iceServerURLs(servers []webrtc.ICEServer) []string {
+	var urls []string
+	for _, s := range servers {
+		urls = append(urls, s.URLs...)
+	}
+	return urls
+}
+
 func strPtr(s string) *string {
 	return &s
 }
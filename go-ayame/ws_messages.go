@@ -35,6 +35,13 @@ type rejectMessage struct {
 	Reason string `json:"reason"`
 }
 
+// sdpMessage は offer と answer のメッセージです
+// Ayame は offer と answer をそのまま相手に転送するので、TURN だけで接続し直すかも一緒に伝えます
+type sdpMessage struct {
+	webrtc.SessionDescription
+	TURNFallback bool `json:"turnFallback,omitempty"`
+}
+
 type candidateMessage struct {
 	Type         string                   `json:"type"`
 	ICECandidate *webrtc.ICECandidateInit `json:"ice,omitempty"`
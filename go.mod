@@ -5,6 +5,8 @@ go 1.22.2
 require (
 	github.com/mowshon/iterium v1.0.0
 	github.com/oklog/ulid/v2 v2.1.0
+	github.com/pion/logging v0.2.2
+	github.com/pion/turn/v2 v2.1.6
 	github.com/pion/webrtc/v3 v3.2.50
 	nhooyr.io/websocket v1.8.11
 )
@@ -16,7 +18,6 @@ require (
 	github.com/pion/dtls/v2 v2.2.12 // indirect
 	github.com/pion/ice/v2 v2.3.31 // indirect
 	github.com/pion/interceptor v0.1.29 // indirect
-	github.com/pion/logging v0.2.2 // indirect
 	github.com/pion/mdns v0.0.12 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
 	github.com/pion/rtcp v1.2.14 // indirect
@@ -26,7 +27,6 @@ require (
 	github.com/pion/srtp/v2 v2.0.20 // indirect
 	github.com/pion/stun v0.6.1 // indirect
 	github.com/pion/transport/v2 v2.2.8 // indirect
-	github.com/pion/turn/v2 v2.1.6 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	github.com/wlynxg/anet v0.0.3 // indirect
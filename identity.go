@@ -0,0 +1,131 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"syscall/js"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+)
+
+var (
+	identityOrigin string
+	// identityKey はアカウントの秘密鍵で、identity サーバに登録できなかった場合は nil です
+	identityKey ed25519.PrivateKey
+	// accountID は identity サーバが発行した userID です
+	accountID string
+	// identityClient は相手のアカウントを引く identity サーバのクライアントです
+	// 引いたアカウントをキャッシュするので、対局ごとに作り直さず使い回します
+	identityClient *identity.Client
+)
+
+type identityRegisterReqMsg struct {
+	DisplayName string `json:"display_name"`
+	PublicKey   string `json:"public_key"`
+}
+
+// loadIdentity は localStorage の鍵でアカウントを登録し、発行された userID を返します
+// 鍵がなければ作って保存します。同じ鍵での登録は既存のアカウントが返るので、毎回登録し直します
+func loadIdentity(identityURL url.URL, displayName string) (string, error) {
+	localStorage := js.Global().Get("window").Get("localStorage")
+	var key ed25519.PrivateKey
+	if stored := localStorage.Get("identityKey"); !stored.IsUndefined() && !stored.IsNull() {
+		k, err := identity.DecodePrivateKey(stored.String())
+		if err != nil {
+			return "", err
+		}
+		key = k
+	} else {
+		_, k, err := identity.GenerateKey()
+		if err != nil {
+			return "", err
+		}
+		key = k
+		localStorage.Set("identityKey", identity.EncodeKey(key))
+	}
+
+	identityURL.Path = path.Join(identityURL.Path, "/account/register")
+	body, err := json.Marshal(identityRegisterReqMsg{
+		DisplayName: displayName,
+		PublicKey:   identity.EncodeKey(key.Public().(ed25519.PublicKey)),
+	})
+	if err != nil {
+		return "", err
+	}
+	res, err := http.Post(identityURL.String(), "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to register account: %v", res.Status)
+	}
+	var account identity.Account
+	if err := json.NewDecoder(res.Body).Decode(&account); err != nil {
+		return "", err
+	}
+	identityKey = key
+	accountID = account.ID
+	return account.ID, nil
+}
+
+// authToken は audience 宛てのトークンを作ります
+// アカウントがなければ空文字を返し、各サーバは従来の hash で検証します
+func authToken(audience string) string {
+	if identityKey == nil {
+		return ""
+	}
+	token, err := identity.Sign(identityKey, identity.NewClaims(accountID, audience, time.Now()))
+	if err != nil {
+		logger.Error("failed to sign token", "err", err)
+		return ""
+	}
+	return token
+}
+
+// authorize は req に audience 宛てのトークンを付けます
+func authorize(req *http.Request, audience string) {
+	if token := authToken(audience); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// authorizeSignaling は Ayame の認証ウェブフックで検証するトークンを authnMetadata に載せます
+func authorizeSignaling(conn *ayame.Connection) {
+	token := authToken(identity.AudienceSignaling)
+	if token == "" {
+		return
+	}
+	var metadata interface{} = map[string]string{"token": token}
+	conn.AuthnMetadata = &metadata
+}
+
+// getAuthorized と postAuthorized は audience 宛てのトークンを付けて送ります
+func getAuthorized(u, audience string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, audience)
+	return http.DefaultClient.Do(req)
+}
+
+func postAuthorized(u, audience string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorize(req, audience)
+	return http.DefaultClient.Do(req)
+}
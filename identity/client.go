@@ -0,0 +1,70 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+// Client は別のサーバから identity サーバのアカウントを参照してトークンを検証します
+// 公開鍵は変わらないので、参照したアカウントはキャッシュします
+type Client struct {
+	baseURL url.URL
+	http    *http.Client
+	cache   map[string]*Account
+	mu      sync.Mutex
+}
+
+func NewClient(baseURL url.URL) *Client {
+	return &Client{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 5 * time.Second},
+		cache:   make(map[string]*Account),
+	}
+}
+
+func (c *Client) Lookup(id string) (*Account, error) {
+	c.mu.Lock()
+	account, ok := c.cache[id]
+	c.mu.Unlock()
+	if ok {
+		return account, nil
+	}
+	u := c.baseURL
+	u.Path = path.Join(u.Path, "/account", url.PathEscape(id))
+	res, err := c.http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to lookup account %s: %v", id, res.Status)
+	}
+	account = &Account{}
+	if err := json.NewDecoder(res.Body).Decode(account); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cache[id] = account
+	c.mu.Unlock()
+	return account, nil
+}
+
+func (c *Client) Authenticate(token, audience string, now time.Time) (*Account, error) {
+	claims, err := ParseUnverified(token)
+	if err != nil {
+		return nil, err
+	}
+	account, err := c.Lookup(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := account.Verify(token, audience, now); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,147 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const AudienceIdentity = "identity"
+
+type registerReqMsg struct {
+	DisplayName string `json:"display_name"`
+	PublicKey   string `json:"public_key"`
+}
+
+type renameReqMsg struct {
+	DisplayName string `json:"display_name"`
+}
+
+// ayameAuthnReqMsg は Ayame の認証ウェブフックに届くリクエストです
+type ayameAuthnReqMsg struct {
+	RoomID        string `json:"roomId"`
+	ClientID      string `json:"clientId"`
+	AuthnMetadata struct {
+		Token string `json:"token"`
+	} `json:"authnMetadata"`
+}
+
+type ayameAuthnResMsg struct {
+	Allowed       bool     `json:"allowed"`
+	Reason        string   `json:"reason,omitempty"`
+	AuthzMetadata *Account `json:"authzMetadata,omitempty"`
+}
+
+type accountKey struct{}
+
+// AccountFrom は RequireToken で認証したアカウントを返します
+func AccountFrom(ctx context.Context) (*Account, bool) {
+	account, ok := ctx.Value(accountKey{}).(*Account)
+	return account, ok
+}
+
+// BearerToken は Authorization ヘッダのトークンを返します
+func BearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// Authenticator はトークンを検証してアカウントを返します
+// 同じプロセスでは Registry を、別のサーバからは Client を使います
+type Authenticator interface {
+	Authenticate(token, audience string, now time.Time) (*Account, error)
+}
+
+// RequireToken は audience 宛てのトークンで認証したリクエストだけを next に渡します
+// マッチングやレーティング、大会のサーバで使います
+func RequireToken(auth Authenticator, audience string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		account, err := auth.Authenticate(BearerToken(req), audience, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), accountKey{}, account)))
+	})
+}
+
+// Handler はアカウントの発行、参照、表示名の変更と Ayame の認証ウェブフックを提供します
+func (r *Registry) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /account/register", r.register)
+	mux.HandleFunc("GET /account/{id}", r.lookup)
+	mux.Handle("POST /account/rename", RequireToken(r, AudienceIdentity, http.HandlerFunc(r.rename)))
+	mux.HandleFunc("POST /authn/ayame", r.ayameAuthn)
+	return mux
+}
+
+func (r *Registry) register(w http.ResponseWriter, req *http.Request) {
+	var reqMsg registerReqMsg
+	if err := json.NewDecoder(req.Body).Decode(&reqMsg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := DecodePublicKey(reqMsg.PublicKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	account, err := r.Register(reqMsg.DisplayName, key, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, account)
+}
+
+func (r *Registry) lookup(w http.ResponseWriter, req *http.Request) {
+	account, ok := r.Lookup(req.PathValue("id"))
+	if !ok {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, account)
+}
+
+func (r *Registry) rename(w http.ResponseWriter, req *http.Request) {
+	var reqMsg renameReqMsg
+	if err := json.NewDecoder(req.Body).Decode(&reqMsg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	account, _ := AccountFrom(req.Context())
+	if err := r.Rename(account.ID, reqMsg.DisplayName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, account)
+}
+
+// ayameAuthn は authnMetadata のトークンを検証し、認証したアカウントを authzMetadata として相手に渡します
+func (r *Registry) ayameAuthn(w http.ResponseWriter, req *http.Request) {
+	var reqMsg ayameAuthnReqMsg
+	if err := json.NewDecoder(req.Body).Decode(&reqMsg); err != nil {
+		writeJSON(w, ayameAuthnResMsg{Allowed: false, Reason: "invalid request"})
+		return
+	}
+	account, err := r.Authenticate(reqMsg.AuthnMetadata.Token, AudienceSignaling, time.Now())
+	if err != nil {
+		log.Printf("rejected %s in %s: %v", reqMsg.ClientID, reqMsg.RoomID, err)
+		writeJSON(w, ayameAuthnResMsg{Allowed: false, Reason: err.Error()})
+		return
+	}
+	writeJSON(w, ayameAuthnResMsg{Allowed: true, AuthzMetadata: account})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
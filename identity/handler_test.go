@@ -0,0 +1,110 @@
+package identity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAyameAuthn は Ayame の認証ウェブフックとの取り決めです
+func TestAyameAuthn(t *testing.T) {
+	r := NewRegistry(nil)
+	alice, priv := newTestAccount(t, r, "alice")
+	sign := func(audience string) string {
+		token, err := Sign(priv, NewClaims(alice.ID, audience, time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{name: "signaling token", body: `{"roomId":"room","clientId":"c","authnMetadata":{"token":"` + sign(AudienceSignaling) + `"}}`, want: true},
+		{name: "token for another server", body: `{"roomId":"room","clientId":"c","authnMetadata":{"token":"` + sign(AudienceRating) + `"}}`},
+		{name: "without token", body: `{"roomId":"room","clientId":"c"}`},
+		{name: "malformed body", body: `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/authn/ayame", strings.NewReader(tt.body)))
+			// Ayame は拒否もステータス 200 の allowed=false で受け取る
+			if rec.Code != http.StatusOK {
+				t.Fatalf("POST /authn/ayame = %d, want %d", rec.Code, http.StatusOK)
+			}
+			var res ayameAuthnResMsg
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed != tt.want {
+				t.Errorf("allowed = %t (%s), want %t", res.Allowed, res.Reason, tt.want)
+			}
+			if tt.want && (res.AuthzMetadata == nil || res.AuthzMetadata.ID != alice.ID) {
+				t.Errorf("authzMetadata = %+v, want %s", res.AuthzMetadata, alice.ID)
+			}
+		})
+	}
+}
+
+func TestClientAuthenticate(t *testing.T) {
+	r := NewRegistry(nil)
+	alice, priv := newTestAccount(t, r, "alice")
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(*u)
+
+	protected := RequireToken(c, AudienceTournament, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		account, ok := AccountFrom(req.Context())
+		if !ok || account.ID != alice.ID {
+			t.Errorf("AccountFrom() = %v, %t, want %s", account, ok, alice.ID)
+		}
+	}))
+	tests := []struct {
+		name     string
+		audience string
+		want     int
+	}{
+		{name: "token for this server", audience: AudienceTournament, want: http.StatusOK},
+		{name: "token for another server", audience: AudienceRating, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Sign(priv, NewClaims(alice.ID, tt.audience, time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/tournament/report", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			protected.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+	t.Run("unknown account", func(t *testing.T) {
+		_, strangerPriv, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := Sign(strangerPriv, NewClaims("nobody", AudienceTournament, time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Authenticate(token, AudienceTournament, time.Now()); err == nil {
+			t.Error("Authenticate() of an unknown account succeeded")
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package identity
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const maxDisplayNameLength = 20
+
+// Account はサーバが発行したアカウントです
+// 秘密鍵はクライアントだけが持ち、サーバは登録された公開鍵で署名を検証します
+type Account struct {
+	ID          string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	PublicKey   string    `json:"public_key"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Verify は token がこのアカウントの秘密鍵で署名されているかを検証します
+func (a *Account) Verify(token, audience string, now time.Time) error {
+	key, err := DecodePublicKey(a.PublicKey)
+	if err != nil {
+		return err
+	}
+	claims, err := Verify(token, key, audience, now)
+	if err != nil {
+		return err
+	}
+	if claims.Subject != a.ID {
+		return fmt.Errorf("%w: subject %s", ErrInvalidToken, claims.Subject)
+	}
+	return nil
+}
+
+// Registry はアカウントを管理します
+// w を渡すと登録と表示名の変更を JSON Lines で書き出し、Load で読み戻せます
+type Registry struct {
+	accounts map[string]*Account
+	byKey    map[string]*Account
+	w        io.Writer
+	mu       sync.RWMutex
+}
+
+func NewRegistry(w io.Writer) *Registry {
+	return &Registry{
+		accounts: make(map[string]*Account),
+		byKey:    make(map[string]*Account),
+		w:        w,
+	}
+}
+
+// Load は書き出したアカウントを読み込みます
+// 同じアカウントが複数行ある場合は後の行の表示名を使います
+func (r *Registry) Load(reader io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var account Account
+		if err := json.Unmarshal(scanner.Bytes(), &account); err != nil {
+			return err
+		}
+		key, err := DecodePublicKey(account.PublicKey)
+		if err != nil {
+			return err
+		}
+		if existing, ok := r.accounts[account.ID]; ok {
+			existing.DisplayName = account.DisplayName
+			continue
+		}
+		r.add(&account, key)
+	}
+	return scanner.Err()
+}
+
+func (r *Registry) add(account *Account, key ed25519.PublicKey) {
+	r.accounts[account.ID] = account
+	r.byKey[string(key)] = account
+}
+
+// write は書き出し先があればアカウントを1行追記します
+func (r *Registry) write(account *Account) error {
+	if r.w == nil {
+		return nil
+	}
+	b, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
+
+func validateDisplayName(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return fmt.Errorf("display name must be 1-%d characters", maxDisplayNameLength)
+	}
+	return nil
+}
+
+// Register は公開鍵 key のアカウントを発行します
+// 同じ公開鍵で登録し直した場合は既存のアカウントを返します
+func (r *Registry) Register(displayName string, key ed25519.PublicKey, now time.Time) (*Account, error) {
+	if err := validateDisplayName(displayName); err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if account, ok := r.byKey[string(key)]; ok {
+		return account, nil
+	}
+	account := &Account{
+		ID:          strings.ToLower(ulid.MustNew(ulid.Timestamp(now), rand.Reader).String()),
+		DisplayName: strings.TrimSpace(displayName),
+		PublicKey:   EncodeKey(key),
+		CreatedAt:   now,
+	}
+	if err := r.write(account); err != nil {
+		return nil, err
+	}
+	r.add(account, key)
+	return account, nil
+}
+
+func (r *Registry) Lookup(id string) (*Account, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	account, ok := r.accounts[id]
+	return account, ok
+}
+
+// Rename は表示名を変更します
+func (r *Registry) Rename(id, displayName string) error {
+	if err := validateDisplayName(displayName); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[id]
+	if !ok {
+		return fmt.Errorf("account not found: %s", id)
+	}
+	renamed := *account
+	renamed.DisplayName = strings.TrimSpace(displayName)
+	if err := r.write(&renamed); err != nil {
+		return err
+	}
+	account.DisplayName = renamed.DisplayName
+	return nil
+}
+
+// Authenticate はトークンを検証し、署名したアカウントを返します
+func (r *Registry) Authenticate(token, audience string, now time.Time) (*Account, error) {
+	claims, err := ParseUnverified(token)
+	if err != nil {
+		return nil, err
+	}
+	account, ok := r.Lookup(claims.Subject)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown account %s", ErrInvalidToken, claims.Subject)
+	}
+	if err := account.Verify(token, audience, now); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
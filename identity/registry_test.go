@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAccount(t *testing.T, r *Registry, name string) (*Account, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := r.Register(name, pub, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account, priv
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry(nil)
+	pub, _, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := r.Register(" alice ", pub, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.DisplayName != "alice" {
+		t.Errorf("DisplayName = %q, want %q", first.DisplayName, "alice")
+	}
+	// 同じ公開鍵で登録し直しても別のアカウントは作らない
+	again, err := r.Register("alice2", pub, time.Now())
+	if err != nil || again.ID != first.ID {
+		t.Errorf("Register() with the same key = %v, %v, want %s", again, err, first.ID)
+	}
+
+	tests := []struct {
+		name        string
+		displayName string
+		key         []byte
+	}{
+		{name: "empty name", displayName: " ", key: pub},
+		{name: "too long name", displayName: strings.Repeat("あ", maxDisplayNameLength+1), key: pub},
+		{name: "short key", displayName: "bob", key: pub[:16]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Register(tt.displayName, tt.key, time.Now()); err == nil {
+				t.Error("Register() succeeded")
+			}
+		})
+	}
+}
+
+func TestRegistryLoad(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRegistry(&buf)
+	alice, _ := newTestAccount(t, r, "alice")
+	bob, _ := newTestAccount(t, r, "bob")
+	if err := r.Rename(alice.ID, "alice2"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewRegistry(nil)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: alice.ID, want: "alice2"},
+		{id: bob.ID, want: "bob"},
+	}
+	for _, tt := range tests {
+		account, ok := loaded.Lookup(tt.id)
+		if !ok || account.DisplayName != tt.want {
+			t.Errorf("Lookup(%s) = %v, %t, want %s", tt.id, account, ok, tt.want)
+		}
+	}
+	// 読み戻した後も同じ公開鍵は同じアカウントになる
+	key, err := DecodePublicKey(alice.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account, err := loaded.Register("alice3", key, time.Now()); err != nil || account.ID != alice.ID {
+		t.Errorf("Register() after Load = %v, %v, want %s", account, err, alice.ID)
+	}
+}
+
+func TestRegistryAuthenticate(t *testing.T) {
+	r := NewRegistry(nil)
+	alice, alicePriv := newTestAccount(t, r, "alice")
+	bob, _ := newTestAccount(t, r, "bob")
+	_, strangerPriv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	sign := func(priv ed25519.PrivateKey, subject, audience string) string {
+		token, err := Sign(priv, NewClaims(subject, audience, now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{name: "own token", token: sign(alicePriv, alice.ID, AudienceRating), want: alice.ID},
+		{name: "other audience", token: sign(alicePriv, alice.ID, AudienceTURN), wantErr: ErrInvalidToken},
+		{name: "impersonating another account", token: sign(alicePriv, bob.ID, AudienceRating), wantErr: ErrInvalidToken},
+		{name: "unregistered key", token: sign(strangerPriv, alice.ID, AudienceRating), wantErr: ErrInvalidToken},
+		{name: "unknown account", token: sign(strangerPriv, "nobody", AudienceRating), wantErr: ErrInvalidToken},
+		{name: "malformed", token: "not a token", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := r.Authenticate(tt.token, AudienceRating, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error: %v", err)
+			}
+			if account.ID != tt.want {
+				t.Errorf("Authenticate() = %s, want %s", account.ID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnsignedResult = errors.New("result is not signed by both players")
+
+// ResultStatement は対局の両者が終局時に署名する結果です
+// Players と Commitments は Ayame の開室者(player1)、非開室者(player2)の順で、Result は player1 から見た結果です
+type ResultStatement struct {
+	MatchID     string    `json:"match_id"`
+	Players     [2]string `json:"players"`
+	Commitments [2]string `json:"commitments"`
+	MoveDigest  string    `json:"move_digest"`
+	Result      string    `json:"result"`
+}
+
+// payload は署名対象のバイト列です
+// トークンなど他の署名と取り違えないよう、先頭に種別を付けます
+func (s *ResultStatement) payload() []byte {
+	b, _ := json.Marshal(s)
+	return append([]byte("hit-and-blow/result\n"), b...)
+}
+
+// SignResult は statement に key で署名します
+func SignResult(key ed25519.PrivateKey, statement *ResultStatement) string {
+	return EncodeKey(ed25519.Sign(key, statement.payload()))
+}
+
+// VerifyResult は signature が account の statement への署名かを検証します
+func (a *Account) VerifyResult(statement *ResultStatement, signature string) error {
+	key, err := DecodePublicKey(a.PublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := encoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, statement.payload(), sig) {
+		return fmt.Errorf("invalid result signature by %s", a.ID)
+	}
+	return nil
+}
+
+// SignedResult はレーティングや大会のサーバに報告する、両者の署名付きの結果です
+// 相手が署名を返さなかった場合、Signatures の相手の分は空です
+type SignedResult struct {
+	Statement  ResultStatement `json:"statement"`
+	Signatures [2]string       `json:"signatures"`
+}
+
+// Verify は両者の署名を検証します
+// accounts は Statement.Players の順に対応するアカウントです
+func (r *SignedResult) Verify(accounts [2]*Account) error {
+	for i, account := range accounts {
+		if account == nil || account.ID != r.Statement.Players[i] {
+			return fmt.Errorf("account mismatch for player%d", i+1)
+		}
+		if r.Signatures[i] == "" {
+			return ErrUnsignedResult
+		}
+		if err := account.VerifyResult(&r.Statement, r.Signatures[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArbiterDecision は署名が揃わない対局について、運営の裁定者が決めた結果です
+// Players と Result は ResultStatement と同じく player1 から並べ、player1 から見た結果です
+type ArbiterDecision struct {
+	MatchID   string    `json:"match_id"`
+	Players   [2]string `json:"players"`
+	Result    string    `json:"result"`
+	Reason    string    `json:"reason"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+func (d *ArbiterDecision) payload() []byte {
+	b, _ := json.Marshal(ArbiterDecision{MatchID: d.MatchID, Players: d.Players, Result: d.Result, Reason: d.Reason})
+	return append([]byte("hit-and-blow/arbiter\n"), b...)
+}
+
+// SignDecision は裁定者の key で d に署名します
+func SignDecision(key ed25519.PrivateKey, d *ArbiterDecision) {
+	d.Signature = EncodeKey(ed25519.Sign(key, d.payload()))
+}
+
+// Verify は d が裁定者の公開鍵 key で署名されているかを検証します
+func (d *ArbiterDecision) Verify(key ed25519.PublicKey) error {
+	sig, err := encoding.DecodeString(d.Signature)
+	if err != nil || !ed25519.Verify(key, d.payload(), sig) {
+		return errors.New("invalid arbiter signature")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignedResultVerify(t *testing.T) {
+	r := NewRegistry(nil)
+	alice, alicePriv := newTestAccount(t, r, "alice")
+	bob, bobPriv := newTestAccount(t, r, "bob")
+	statement := ResultStatement{
+		MatchID:     "room-1",
+		Players:     [2]string{alice.ID, bob.ID},
+		Commitments: [2]string{"c1", "c2"},
+		MoveDigest:  "digest",
+		Result:      "1",
+	}
+	signed := func() *SignedResult {
+		return &SignedResult{
+			Statement:  statement,
+			Signatures: [2]string{SignResult(alicePriv, &statement), SignResult(bobPriv, &statement)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*SignedResult)
+		accounts [2]*Account
+		wantErr  error
+		anyErr   bool
+	}{
+		{name: "signed by both", modify: func(*SignedResult) {}, accounts: [2]*Account{alice, bob}},
+		{name: "opponent did not sign", modify: func(s *SignedResult) { s.Signatures[1] = "" }, accounts: [2]*Account{alice, bob}, wantErr: ErrUnsignedResult},
+		{name: "result changed after signing", modify: func(s *SignedResult) { s.Statement.Result = "0" }, accounts: [2]*Account{alice, bob}, anyErr: true},
+		{name: "move digest changed after signing", modify: func(s *SignedResult) { s.Statement.MoveDigest = "other" }, accounts: [2]*Account{alice, bob}, anyErr: true},
+		{name: "signatures swapped", modify: func(s *SignedResult) { s.Signatures[0], s.Signatures[1] = s.Signatures[1], s.Signatures[0] }, accounts: [2]*Account{alice, bob}, anyErr: true},
+		{name: "both signed by one player", modify: func(s *SignedResult) { s.Signatures[1] = s.Signatures[0] }, accounts: [2]*Account{alice, bob}, anyErr: true},
+		{name: "accounts in the wrong order", modify: func(*SignedResult) {}, accounts: [2]*Account{bob, alice}, anyErr: true},
+		{name: "missing account", modify: func(*SignedResult) {}, accounts: [2]*Account{alice, nil}, anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := signed()
+			tt.modify(s)
+			err := s.Verify(tt.accounts)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Error("Verify() succeeded")
+				}
+			case err != nil:
+				t.Errorf("Verify() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestArbiterDecisionVerify(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &ArbiterDecision{MatchID: "room-1", Players: [2]string{"alice", "bob"}, Result: "0.5", Reason: "disconnected"}
+	SignDecision(priv, d)
+	if err := d.Verify(pub); err != nil {
+		t.Errorf("Verify() error: %v", err)
+	}
+	if err := d.Verify(otherPub); err == nil {
+		t.Error("Verify() with another key succeeded")
+	}
+	d.Result = "1"
+	if err := d.Verify(pub); err == nil {
+		t.Error("Verify() of a changed decision succeeded")
+	}
+}
@@ -0,0 +1,128 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 各サーバは自分宛てのトークンだけを受け付けます
+const (
+	AudienceMatchmaking = "matchmaking"
+	AudienceProfile     = "profile"
+	AudienceRating      = "rating"
+	AudienceRelay       = "relay"
+	AudienceSignaling   = "signaling"
+	AudienceTournament  = "tournament"
+	AudienceTURN        = "turn"
+)
+
+// TokenTTL はトークンの有効期間です
+// リクエストごとに作り直すので、漏れても再利用できる時間を短くしておきます
+// トークンには nonce がなく、サーバも使用済みかを記録しないため、期間内なら同じ audience へ何度でも送り直せます
+// 受け取る側は冪等な操作か、対局ごとの ID で重複を弾く操作だけをトークンで認証してください
+const TokenTTL = 5 * time.Minute
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token is expired")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Claims はクライアントが自分の秘密鍵で署名するトークンの内容です
+type Claims struct {
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func NewClaims(userID, audience string, now time.Time) *Claims {
+	return &Claims{
+		Subject:   userID,
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(TokenTTL).Unix(),
+	}
+}
+
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+func EncodeKey(key []byte) string {
+	return encoding.EncodeToString(key)
+}
+
+func DecodePublicKey(text string) (ed25519.PublicKey, error) {
+	key, err := encoding.DecodeString(text)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func DecodePrivateKey(text string) (ed25519.PrivateKey, error) {
+	key, err := encoding.DecodeString(text)
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// Sign は "<claims>.<signature>" 形式のトークンを作ります
+func Sign(key ed25519.PrivateKey, claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	body := encoding.EncodeToString(payload)
+	return body + "." + encoding.EncodeToString(ed25519.Sign(key, []byte(body))), nil
+}
+
+// ParseUnverified は署名を検証せずにトークンの内容を返します
+// 検証に使う公開鍵を Subject から引くためだけに使います
+func ParseUnverified(token string) (*Claims, error) {
+	body, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	payload, err := encoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// Verify は key で署名され、audience 宛てで now に有効なトークンかを検証します
+func Verify(token string, key ed25519.PublicKey, audience string, now time.Time) (*Claims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	signature, err := encoding.DecodeString(sig)
+	if err != nil || !ed25519.Verify(key, []byte(body), signature) {
+		return nil, ErrInvalidToken
+	}
+	claims, err := ParseUnverified(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != audience {
+		return nil, fmt.Errorf("%w: audience %q", ErrInvalidToken, claims.Audience)
+	}
+	if now.Unix() > claims.ExpiresAt || now.Add(TokenTTL).Unix() < claims.IssuedAt {
+		return nil, ErrExpiredToken
+	}
+	return claims, nil
+}
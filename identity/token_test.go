@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := Sign(priv, NewClaims("alice", AudienceRating, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, _ := strings.Cut(token, ".")
+	forged, err := Sign(priv, NewClaims("bob", AudienceRating, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, forgedSig, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name     string
+		token    string
+		key      []byte
+		audience string
+		now      time.Time
+		wantErr  error
+	}{
+		{name: "valid", token: token, key: pub, audience: AudienceRating, now: now},
+		{name: "valid until expiry", token: token, key: pub, audience: AudienceRating, now: now.Add(TokenTTL)},
+		{name: "expired", token: token, key: pub, audience: AudienceRating, now: now.Add(TokenTTL + time.Second), wantErr: ErrExpiredToken},
+		{name: "issued too far in the future", token: token, key: pub, audience: AudienceRating, now: now.Add(-TokenTTL - time.Second), wantErr: ErrExpiredToken},
+		{name: "other audience", token: token, key: pub, audience: AudienceTournament, now: now, wantErr: ErrInvalidToken},
+		{name: "other key", token: token, key: otherPub, audience: AudienceRating, now: now, wantErr: ErrInvalidToken},
+		{name: "swapped signature", token: body + "." + forgedSig, key: pub, audience: AudienceRating, now: now, wantErr: ErrInvalidToken},
+		{name: "without signature", token: body, key: pub, audience: AudienceRating, now: now, wantErr: ErrInvalidToken},
+		{name: "empty", token: "", key: pub, audience: AudienceRating, now: now, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.token, tt.key, tt.audience, tt.now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error: %v", err)
+			}
+			if claims.Subject != "alice" || claims.Audience != AudienceRating {
+				t.Errorf("Verify() = %+v, want alice for %s", claims, AudienceRating)
+			}
+		})
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecodePublicKey(EncodeKey(pub)); err != nil || !got.Equal(pub) {
+		t.Errorf("DecodePublicKey(EncodeKey(pub)) = %v, %v", got, err)
+	}
+	if got, err := DecodePrivateKey(EncodeKey(priv)); err != nil || !got.Equal(priv) {
+		t.Errorf("DecodePrivateKey(EncodeKey(priv)) = %v, %v", got, err)
+	}
+	// 秘密鍵を公開鍵として登録させない
+	if _, err := DecodePublicKey(EncodeKey(priv)); err == nil {
+		t.Error("DecodePublicKey() accepted a private key")
+	}
+}
@@ -0,0 +1,88 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall/js"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/leaderboard"
+)
+
+var leaderboardOrigin string
+
+// friends は localStorage に保存したフレンドの userID です
+func friends() []string {
+	stored := js.Global().Get("window").Get("localStorage").Get("friends")
+	if stored.IsUndefined() || stored.IsNull() || stored.String() == "" {
+		return nil
+	}
+	return strings.Split(stored.String(), ",")
+}
+
+func addFriend(id string) {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.Contains(id, ",") || slices.Contains(friends(), id) {
+		return
+	}
+	js.Global().Get("window").Get("localStorage").Set("friends", strings.Join(append(friends(), id), ","))
+	logElem(fmt.Sprintf("[Sys]: Added %s to friends\n", id))
+}
+
+// showLeaderboard は view(top, around, friends) の順位表を表示します
+func showLeaderboard(leaderboardURL url.URL, view, myID string) {
+	leaderboardURL.Path = path.Join(leaderboardURL.Path, view)
+	q := url.Values{"player_id": {myID}}
+	if view == "friends" {
+		q.Set("ids", strings.Join(friends(), ","))
+	}
+	leaderboardURL.RawQuery = q.Encode()
+	res, err := http.Get(leaderboardURL.String())
+	if err != nil {
+		logger.Error("failed to get leaderboard", "err", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logger.Error("failed to get leaderboard", "status", res.Status)
+		return
+	}
+	var standings []*leaderboard.Standing
+	if err := json.NewDecoder(res.Body).Decode(&standings); err != nil {
+		logger.Error("failed to decode leaderboard", "err", err)
+		return
+	}
+	list := getElementByID("leaderboard-list")
+	list.Set("innerHTML", "")
+	if len(standings) == 0 {
+		appendLeaderboard("No games this season", false)
+		return
+	}
+	for _, s := range standings {
+		// 暫定レーティングには ? を、非活動で下がった分は ↓ を付ける
+		rating := fmt.Sprintf("%d", s.Rating)
+		if s.Provisional {
+			rating += "?"
+		}
+		if s.Decay > 0 {
+			rating += fmt.Sprintf(" (↓%d)", s.Decay)
+		}
+		appendLeaderboard(fmt.Sprintf("#%d %s %s / %d games", s.Rank, s.PlayerID, rating, s.Games), s.PlayerID == myID)
+	}
+}
+
+func appendLeaderboard(text string, isMe bool) {
+	item := js.Global().Get("document").Call("createElement", "li")
+	item.Set("textContent", text)
+	if isMe {
+		item.Get("style").Set("fontWeight", "bold")
+	}
+	getElementByID("leaderboard-list").Call("appendChild", item)
+}
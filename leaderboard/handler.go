@@ -0,0 +1,107 @@
+package leaderboard
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTop    = 50
+	defaultAround = 5
+	maxListed     = 200
+)
+
+// updateReqMsg はレーティングサーバが対局ごとに送る、各プレイヤーの対局後のレーティングです
+// レーティングサーバは /rating/finish で両者の結果が揃って計算した後に、
+// Authorization: Bearer <LEADERBOARD_SECRET> を付けて POST /leaderboard/update へ送ります
+// 同じ match_id を送り直しても対局数は増えないので、失敗した場合はそのまま再送できます
+type updateReqMsg struct {
+	MatchID string `json:"match_id"`
+	Players []struct {
+		ID   string `json:"id"`
+		Rate int    `json:"rate"`
+	} `json:"players"`
+}
+
+// Handler は順位表の参照と、レーティングサーバからの更新を提供します
+// 更新は secret を Bearer トークンとして送ったものだけを受け付けます
+func (l *Leaderboard) Handler(secret string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /leaderboard/seasons", l.seasonsHandler)
+	mux.HandleFunc("GET /leaderboard/top", l.top)
+	mux.HandleFunc("GET /leaderboard/around", l.around)
+	mux.HandleFunc("GET /leaderboard/friends", l.friends)
+	mux.HandleFunc("POST /leaderboard/update", func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		l.update(w, r)
+	})
+	return mux
+}
+
+func (l *Leaderboard) update(w http.ResponseWriter, r *http.Request) {
+	var req updateReqMsg
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	for _, p := range req.Players {
+		if err := l.Update(req.MatchID, p.ID, p.Rate, now); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (l *Leaderboard) seasonsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, l.Seasons())
+}
+
+func (l *Leaderboard) top(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, l.Top(season(r), count(r, defaultTop), time.Now()))
+}
+
+func (l *Leaderboard) around(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, l.Around(season(r), r.URL.Query().Get("player_id"), count(r, defaultAround), time.Now()))
+}
+
+// friends は ids にカンマ区切りで渡したプレイヤーと player_id 自身の順位を返します
+func (l *Leaderboard) friends(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ids := strings.Split(q.Get("ids"), ",")
+	ids = append(ids[:min(len(ids), maxListed)], q.Get("player_id"))
+	writeJSON(w, l.Friends(season(r), ids, time.Now()))
+}
+
+// season は指定がなければ現在のシーズンです
+func season(r *http.Request) string {
+	if s := r.URL.Query().Get("season"); s != "" {
+		return s
+	}
+	return SeasonOf(time.Now())
+}
+
+func count(r *http.Request, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return min(n, maxListed)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package leaderboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSecret = "secret"
+
+func postUpdate(t *testing.T, h http.Handler, token, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/leaderboard/update", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func getTop(t *testing.T, h http.Handler) []*Standing {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/leaderboard/top", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /leaderboard/top = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var standings []*Standing
+	if err := json.NewDecoder(rec.Body).Decode(&standings); err != nil {
+		t.Fatal(err)
+	}
+	return standings
+}
+
+// TestHandlerUpdate はレーティングサーバが POST /leaderboard/update に送る内容の取り決めです
+func TestHandlerUpdate(t *testing.T) {
+	const body = `{"match_id":"room-1","players":[{"id":"alice","rate":1620},{"id":"bob","rate":1480}]}`
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		body   string
+		want   int
+	}{
+		{name: "accepts the shared secret", secret: testSecret, token: testSecret, body: body, want: http.StatusOK},
+		{name: "rejects a missing token", secret: testSecret, body: body, want: http.StatusUnauthorized},
+		{name: "rejects a wrong token", secret: testSecret, token: "guess", body: body, want: http.StatusUnauthorized},
+		{name: "rejects every update without a secret", token: "", body: body, want: http.StatusUnauthorized},
+		{name: "rejects a malformed body", secret: testSecret, token: testSecret, body: "{", want: http.StatusBadRequest},
+		{name: "rejects an update without match_id", secret: testSecret, token: testSecret, body: `{"players":[{"id":"alice","rate":1620}]}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(DefaultRules(), nil).Handler(tt.secret)
+			if got := postUpdate(t, h, tt.token, tt.body); got != tt.want {
+				t.Errorf("POST /leaderboard/update = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerUpdateIsIdempotent(t *testing.T) {
+	h := New(DefaultRules(), nil).Handler(testSecret)
+	for _, body := range []string{
+		`{"match_id":"room-1","players":[{"id":"alice","rate":1620},{"id":"bob","rate":1480}]}`,
+		// 送り直した対局は数え直さない
+		`{"match_id":"room-1","players":[{"id":"alice","rate":1620},{"id":"bob","rate":1480}]}`,
+		`{"match_id":"room-2","players":[{"id":"alice","rate":1640},{"id":"carol","rate":1450}]}`,
+	} {
+		if got := postUpdate(t, h, testSecret, body); got != http.StatusOK {
+			t.Fatalf("POST /leaderboard/update = %d, want %d", got, http.StatusOK)
+		}
+	}
+	want := []Standing{
+		{Rank: 1, PlayerID: "alice", Rating: 1640, Games: 2, Provisional: true},
+		{Rank: 2, PlayerID: "bob", Rating: 1480, Games: 1, Provisional: true},
+		{Rank: 3, PlayerID: "carol", Rating: 1450, Games: 1, Provisional: true},
+	}
+	got := getTop(t, h)
+	if len(got) != len(want) {
+		t.Fatalf("GET /leaderboard/top returned %d standings, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("standing %d = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(DefaultRules(), &buf).Handler(testSecret)
+	if got := postUpdate(t, h, testSecret, `{"match_id":"room-1","players":[{"id":"alice","rate":1620}]}`); got != http.StatusOK {
+		t.Fatalf("POST /leaderboard/update = %d, want %d", got, http.StatusOK)
+	}
+	// 再起動後に読み戻した順位表は同じで、同じ対局の再送も数えない
+	l := New(DefaultRules(), nil)
+	if err := l.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restarted := l.Handler(testSecret)
+	if got := postUpdate(t, restarted, testSecret, `{"match_id":"room-1","players":[{"id":"alice","rate":1620}]}`); got != http.StatusOK {
+		t.Fatalf("POST /leaderboard/update = %d, want %d", got, http.StatusOK)
+	}
+	got := getTop(t, restarted)
+	if len(got) != 1 || got[0].PlayerID != "alice" || got[0].Rating != 1620 || got[0].Games != 1 {
+		t.Errorf("GET /leaderboard/top after Load = %+v, want alice 1620 with 1 game", got)
+	}
+}
@@ -0,0 +1,227 @@
+package leaderboard
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Rules は順位表の表示のルールです
+type Rules struct {
+	// provisionalGames 局未満のプレイヤーは暫定として印を付けます
+	provisionalGames int
+	// inactiveAfter より長く対局していないと、decayInterval ごとに decayPerInterval ずつ下げます
+	inactiveAfter    time.Duration
+	decayInterval    time.Duration
+	decayPerInterval int
+	// decayFloor より下には下げません
+	decayFloor int
+}
+
+func NewRules(provisionalGames int, inactiveAfter, decayInterval time.Duration, decayPerInterval, decayFloor int) *Rules {
+	return &Rules{provisionalGames, inactiveAfter, decayInterval, decayPerInterval, decayFloor}
+}
+
+func DefaultRules() *Rules {
+	return NewRules(10, 14*24*time.Hour, 7*24*time.Hour, 15, 1000)
+}
+
+// decay は lastPlayed から now までの非活動による減点を返します
+func (r *Rules) decay(rating int, lastPlayed, now time.Time) int {
+	idle := now.Sub(lastPlayed) - r.inactiveAfter
+	if idle <= 0 || r.decayInterval <= 0 {
+		return 0
+	}
+	d := int(idle/r.decayInterval+1) * r.decayPerInterval
+	return min(d, max(rating-r.decayFloor, 0))
+}
+
+// SeasonOf は t が属するシーズンで、UTC の四半期ごとに区切ります
+func SeasonOf(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+}
+
+type entry struct {
+	rating     int
+	games      int
+	lastPlayed time.Time
+}
+
+// Standing は順位表の1行です
+type Standing struct {
+	Rank        int    `json:"rank"`
+	PlayerID    string `json:"player_id"`
+	Rating      int    `json:"rating"`
+	Games       int    `json:"games"`
+	Provisional bool   `json:"provisional"`
+	// Decay は非活動で下げた分で、Rating は下げた後の値です
+	Decay int `json:"decay"`
+}
+
+// update は1局ごとに反映した、1人の対局後のレーティングです
+type update struct {
+	MatchID  string    `json:"match_id"`
+	PlayerID string    `json:"player_id"`
+	Rating   int       `json:"rating"`
+	At       time.Time `json:"at"`
+}
+
+func (u *update) key() string {
+	return u.MatchID + "/" + u.PlayerID
+}
+
+// Leaderboard はシーズンごとの順位表です
+// レーティング自体はレーティングサーバが計算し、対局ごとに Update で反映します
+// シーズンが変わると対局数は 0 から数え直し、そのシーズンに対局したプレイヤーだけが載ります
+// w を渡すと反映した内容を JSON Lines で書き出し、Load で読み戻せます
+type Leaderboard struct {
+	rules   *Rules
+	seasons map[string]map[string]*entry
+	seen    map[string]bool
+	w       io.Writer
+	mu      sync.RWMutex
+}
+
+func New(rules *Rules, w io.Writer) *Leaderboard {
+	return &Leaderboard{
+		rules:   rules,
+		seasons: make(map[string]map[string]*entry),
+		seen:    make(map[string]bool),
+		w:       w,
+	}
+}
+
+// Load は書き出した内容を読み込みます
+func (l *Leaderboard) Load(r io.Reader) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var u update
+		if err := json.Unmarshal(scanner.Bytes(), &u); err != nil {
+			return err
+		}
+		l.add(&u)
+	}
+	return scanner.Err()
+}
+
+// Update は playerID の matchID の対局後のレーティングを at の対局として反映します
+// レーティングサーバが送り直した場合に対局数を重ねて数えないよう、同じ対局の同じプレイヤーは1度しか反映しません
+func (l *Leaderboard) Update(matchID, playerID string, rating int, at time.Time) error {
+	if matchID == "" || playerID == "" {
+		return fmt.Errorf("match_id and player_id are required")
+	}
+	u := &update{MatchID: matchID, PlayerID: playerID, Rating: rating, At: at}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[u.key()] {
+		return nil
+	}
+	if l.w != nil {
+		b, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		if _, err := l.w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	l.add(u)
+	return nil
+}
+
+func (l *Leaderboard) add(u *update) {
+	l.seen[u.key()] = true
+	season := SeasonOf(u.At)
+	entries, ok := l.seasons[season]
+	if !ok {
+		entries = make(map[string]*entry)
+		l.seasons[season] = entries
+	}
+	e, ok := entries[u.PlayerID]
+	if !ok {
+		e = &entry{}
+		entries[u.PlayerID] = e
+	}
+	e.rating = u.Rating
+	e.games++
+	if u.At.After(e.lastPlayed) {
+		e.lastPlayed = u.At
+	}
+}
+
+// Seasons は記録のあるシーズンを新しい順に返します
+func (l *Leaderboard) Seasons() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	seasons := make([]string, 0, len(l.seasons))
+	for s := range l.seasons {
+		seasons = append(seasons, s)
+	}
+	slices.Sort(seasons)
+	slices.Reverse(seasons)
+	return seasons
+}
+
+// standings は season の全員の順位を now 時点の減点込みで返します
+// 過去のシーズンはシーズン終了時点で固定し、減点しません
+func (l *Leaderboard) standings(season string, now time.Time) []*Standing {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	current := season == SeasonOf(now)
+	standings := make([]*Standing, 0, len(l.seasons[season]))
+	for id, e := range l.seasons[season] {
+		s := &Standing{
+			PlayerID:    id,
+			Rating:      e.rating,
+			Games:       e.games,
+			Provisional: e.games < l.rules.provisionalGames,
+		}
+		if current {
+			s.Decay = l.rules.decay(e.rating, e.lastPlayed, now)
+			s.Rating -= s.Decay
+		}
+		standings = append(standings, s)
+	}
+	slices.SortFunc(standings, func(a, b *Standing) int {
+		return cmp.Or(cmp.Compare(b.Rating, a.Rating), cmp.Compare(a.PlayerID, b.PlayerID))
+	})
+	for i, s := range standings {
+		s.Rank = i + 1
+	}
+	return standings
+}
+
+// Top は上位 n 人を返します
+func (l *Leaderboard) Top(season string, n int, now time.Time) []*Standing {
+	standings := l.standings(season, now)
+	return standings[:min(n, len(standings))]
+}
+
+// Around は playerID の前後 n 人ずつを返します
+// playerID がそのシーズンに対局していなければ空です
+func (l *Leaderboard) Around(season, playerID string, n int, now time.Time) []*Standing {
+	standings := l.standings(season, now)
+	i := slices.IndexFunc(standings, func(s *Standing) bool {
+		return s.PlayerID == playerID
+	})
+	if i < 0 {
+		return standings[:0]
+	}
+	return standings[max(i-n, 0):min(i+n+1, len(standings))]
+}
+
+// Friends は ids の中でそのシーズンに対局したプレイヤーを、全体の順位のまま返します
+func (l *Leaderboard) Friends(season string, ids []string, now time.Time) []*Standing {
+	standings := l.standings(season, now)
+	return slices.DeleteFunc(standings, func(s *Standing) bool {
+		return !slices.Contains(ids, s.PlayerID)
+	})
+}
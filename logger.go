@@ -0,0 +1,82 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall/js"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+	"github.com/ponyo877/go-wasm-hit-and-blow/logging"
+)
+
+var (
+	// logger はクライアントのログの出力先で、setupLogger で設定します
+	logger = slog.Default()
+	// logRing は不具合の報告に添付する最近のログです
+	logRing = logging.NewRing(logging.DefaultRingSize)
+	// debugLog は URL に ?debug=1 がある場合に true で、コンソールにも Debug のログと ayame の詳しいログを出力します
+	debugLog bool
+)
+
+// debugBundle は不具合の報告に添付するファイルです
+type debugBundle struct {
+	CreatedAt time.Time         `json:"created_at"`
+	UserAgent string            `json:"user_agent"`
+	Logs      []json.RawMessage `json:"logs"`
+}
+
+// setupLogger は slog の出力先を設定します
+// コンソールには Info 以上、リングバッファには Debug 以上を JSON で残し、どちらも秘密の値は伏せます
+// ayame と game も slog.Default() に出力するので、component の属性で区別します
+func setupLogger() {
+	debugLog = js.Global().Get("URLSearchParams").New(js.Global().Get("location").Get("search")).Call("get", "debug").String() == "1"
+	consoleLevel := slog.LevelInfo
+	if debugLog {
+		consoleLevel = slog.LevelDebug
+	}
+	handler := logging.Fanout(
+		slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: consoleLevel, ReplaceAttr: logging.ReplaceSecrets}),
+		slog.NewJSONHandler(logRing, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: logging.ReplaceSecrets}),
+	)
+	slog.SetDefault(slog.New(handler))
+	logger = logging.Component(slog.Default(), "client")
+}
+
+// setLogRoom は以降のクライアントのログに対局のルームID を付けます
+func setLogRoom(roomID string) {
+	logger = logging.Component(slog.Default(), "client").With("room_id", roomID)
+}
+
+// boardLogger は対局中のログに局と手番の属性を付けます
+func boardLogger(board *game.Board) *slog.Logger {
+	return logger.With("round", board.Round(), "turn", board.GuessCount(game.MyTurn)+board.GuessCount(game.OpTurn))
+}
+
+// exportDebugBundle はリングバッファのログを JSON ファイルとしてダウンロードさせます
+func exportDebugBundle() {
+	bundle := debugBundle{
+		CreatedAt: time.Now(),
+		UserAgent: js.Global().Get("navigator").Get("userAgent").String(),
+	}
+	for _, record := range logRing.Records() {
+		bundle.Logs = append(bundle.Logs, record)
+	}
+	by, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		logger.Error("failed to encode debug bundle", "err", err)
+		return
+	}
+	blob := js.Global().Get("Blob").New([]interface{}{string(by)}, map[string]interface{}{"type": "application/json"})
+	objectURL := js.Global().Get("URL").Call("createObjectURL", blob)
+	defer js.Global().Get("URL").Call("revokeObjectURL", objectURL)
+	a := js.Global().Get("document").Call("createElement", "a")
+	a.Set("href", objectURL)
+	a.Set("download", fmt.Sprintf("hit-and-blow-debug-%d.json", bundle.CreatedAt.Unix()))
+	a.Call("click")
+}
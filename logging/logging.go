@@ -0,0 +1,110 @@
+// Package logging は log/slog による構造化ログの共通の部品です
+// 秘密の値を伏せる ReplaceAttr、複数の出力先に配る Handler、不具合の報告に使うリングバッファを提供します
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// Redacted は伏せた値の代わりに出力する文字列です
+const Redacted = "[REDACTED]"
+
+// secretKeys は値を出力しない属性のキーです
+// 手札は対局が終わるまで相手に知られてはいけないので、ログにも残しません
+var secretKeys = map[string]bool{
+	"hand":          true,
+	"password":      true,
+	"credential":    true,
+	"token":         true,
+	"secret":        true,
+	"signaling_key": true,
+	"authn":         true,
+}
+
+// sdpKey は SDP を出力する属性のキーです
+// 接続の不具合を調べるのに使うので全体は伏せず、sdpSecretPrefix の行の値だけを伏せます
+const sdpKey = "sdp"
+
+// sdpSecretPrefix は ICE の接続確認に使うパスワードの行です
+// 知られると接続確認のメッセージを偽造できるので、ログにも残しません
+const sdpSecretPrefix = "a=ice-pwd:"
+
+// ReplaceSecrets は秘密の値を持つ属性を Redacted に置き換えます
+// slog.HandlerOptions の ReplaceAttr に設定して使います
+func ReplaceSecrets(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if secretKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+	if key == sdpKey && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, redactSDP(a.Value.String()))
+	}
+	return a
+}
+
+// redactSDP は SDP の ice-pwd の値を Redacted に置き換えます
+func redactSDP(sdp string) string {
+	lines := strings.Split(sdp, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, sdpSecretPrefix) {
+			continue
+		}
+		lines[i] = sdpSecretPrefix + Redacted
+		if strings.HasSuffix(line, "\r") {
+			lines[i] += "\r"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Component はコンポーネント名を属性に持つ Logger を返します
+func Component(l *slog.Logger, name string) *slog.Logger {
+	return l.With("component", name)
+}
+
+// fanout は1つのレコードを複数の Handler に配ります
+type fanout []slog.Handler
+
+// Fanout は handlers のそれぞれに出力する Handler を返します
+// レベルは Handler ごとに判定するので、コンソールは Info 以上、リングバッファは Debug 以上のように分けられます
+func Fanout(handlers ...slog.Handler) slog.Handler {
+	return fanout(handlers)
+}
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(fanout, len(f))
+	for i, h := range f {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	next := make(fanout, len(f))
+	for i, h := range f {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestReplaceSecrets(t *testing.T) {
+	const sdp = "v=0\r\na=ice-ufrag:abcd\r\na=ice-pwd:s3cr3tpassword\r\na=fingerprint:sha-256 00:11\r\n"
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want slog.Value
+	}{
+		{name: "hand", attr: slog.String("hand", "345"), want: slog.StringValue(Redacted)},
+		{name: "case insensitive", attr: slog.String("Token", "abc"), want: slog.StringValue(Redacted)},
+		{name: "not a string", attr: slog.Int("secret", 42), want: slog.StringValue(Redacted)},
+		{name: "public value", attr: slog.String("guess", "012"), want: slog.StringValue("012")},
+		{
+			name: "sdp keeps all but ice-pwd",
+			attr: slog.String("sdp", sdp),
+			want: slog.StringValue("v=0\r\na=ice-ufrag:abcd\r\na=ice-pwd:" + Redacted + "\r\na=fingerprint:sha-256 00:11\r\n"),
+		},
+		{name: "sdp without ice-pwd", attr: slog.String("sdp", "v=0\n"), want: slog.StringValue("v=0\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReplaceSecrets(nil, tt.attr)
+			if got.Key != tt.attr.Key || !got.Value.Equal(tt.want) {
+				t.Errorf("ReplaceSecrets(%v) = %v, want %s=%v", tt.attr, got, tt.attr.Key, tt.want)
+			}
+		})
+	}
+}
+
+// message は手札を hand のキーで出力する、対局のメッセージの LogValue と同じ形の値です
+type message struct {
+	kind string
+	hand string
+}
+
+func (m message) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("type", m.kind)}
+	if m.hand != "" {
+		attrs = append(attrs, slog.String("hand", m.hand))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// TestReplaceSecretsInLogValue はグループとして出力した値の中の手札も伏せることを確かめます
+func TestReplaceSecretsInLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: ReplaceSecrets}))
+	logger.Debug("receive message", "message", message{kind: "expose", hand: "345"})
+	if strings.Contains(buf.String(), "345") {
+		t.Fatalf("hand is logged: %s", buf.String())
+	}
+	var record struct {
+		Message map[string]string `json:"message"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Message["type"] != "expose" || record.Message["hand"] != Redacted {
+		t.Errorf("message = %v, want type expose and hand %s", record.Message, Redacted)
+	}
+}
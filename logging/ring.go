@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+)
+
+// DefaultRingSize はリングバッファに残すレコードの数です
+const DefaultRingSize = 1000
+
+// Ring は最近のログを一定の数だけ残す io.Writer です
+// slog の Handler は1レコードを1回の Write で書き込むので、Write ごとに1レコードとして扱います
+type Ring struct {
+	records [][]byte
+	// next は次に書き込む位置です
+	next int
+	full bool
+	mu   sync.Mutex
+}
+
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &Ring{records: make([][]byte, size)}
+}
+
+func (r *Ring) Write(p []byte) (int, error) {
+	record := bytes.TrimRight(p, "\n")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = append(r.records[r.next][:0], record...)
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+	return len(p), nil
+}
+
+// Records は残っているレコードを古い順に返します
+func (r *Ring) Records() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records [][]byte
+	if r.full {
+		records = append(records, r.records[r.next:]...)
+	}
+	records = append(records, r.records[:r.next]...)
+	copied := make([][]byte, len(records))
+	for i, record := range records {
+		copied[i] = bytes.Clone(record)
+	}
+	return copied
+}
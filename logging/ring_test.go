@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes int
+		want   []string
+	}{
+		{name: "empty", size: 3, writes: 0, want: []string{}},
+		{name: "not full", size: 3, writes: 2, want: []string{"r0", "r1"}},
+		{name: "exactly full", size: 3, writes: 3, want: []string{"r0", "r1", "r2"}},
+		{name: "wraps around", size: 3, writes: 5, want: []string{"r2", "r3", "r4"}},
+		{name: "wraps around twice", size: 3, writes: 7, want: []string{"r4", "r5", "r6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRing(tt.size)
+			for i := 0; i < tt.writes; i++ {
+				if _, err := fmt.Fprintf(r, "r%d\n", i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := make([]string, 0)
+			for _, record := range r.Records() {
+				got = append(got, string(record))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Records() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRingRecordsAreCopies(t *testing.T) {
+	r := NewRing(2)
+	fmt.Fprint(r, "first")
+	records := r.Records()
+	// 書き込み先のバッファを使い回しても、返したレコードは変わらない
+	fmt.Fprint(r, "second")
+	fmt.Fprint(r, "third")
+	if string(records[0]) != "first" {
+		t.Errorf("Records()[0] = %q after later writes, want %q", records[0], "first")
+	}
+	if r := NewRing(0); len(r.records) != DefaultRingSize {
+		t.Errorf("NewRing(0) keeps %d records, want %d", len(r.records), DefaultRingSize)
+	}
+}
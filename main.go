@@ -4,27 +4,27 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"syscall/js"
 	"time"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/ponyo877/go-wasm-hit-and-blow/game"
 	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
-
-	"nhooyr.io/websocket"
-	"nhooyr.io/websocket/wsjson"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/rating"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
 )
 
 var (
@@ -33,30 +33,52 @@ var (
 	matchmakingOrigin string
 	signalingOrigin   string
 	ratingOrigin      string
+	tournamentOrigin  string
 	solt              string
 	recentGuess       *game.Guess
+	pendingOffer      string
+	match             *game.Match
 )
 
 type mmReqMsg struct {
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	Type        string    `json:"type,omitempty"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Variant     string    `json:"variant,omitempty"`
+	TimeControl string    `json:"time_control,omitempty"`
+	Rated       *bool     `json:"rated,omitempty"`
+	Region      string    `json:"region,omitempty"`
+	ProposalID  string    `json:"proposal_id,omitempty"`
+	Token       string    `json:"token,omitempty"`
+	// Rate と Deviation は join に載せる自分のレーティングで、キューはこれで対戦相手を選びます
+	Rate      int     `json:"rate,omitempty"`
+	Deviation float64 `json:"deviation,omitempty"`
 }
 
 type mmResMsg struct {
-	Type      string    `json:"type"`
-	RoomID    string    `json:"room_id"`
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+	Type          string    `json:"type"`
+	RoomID        string    `json:"room_id"`
+	UserID        string    `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Position      int       `json:"position,omitempty"`
+	EstimatedWait int       `json:"estimated_wait,omitempty"`
+	ProposalID    string    `json:"proposal_id,omitempty"`
+	AcceptWithin  int       `json:"accept_within,omitempty"`
+	// Rate と Deviation は PROPOSE で提示された相手のレーティングです
+	Rate      int     `json:"rate,omitempty"`
+	Deviation float64 `json:"deviation,omitempty"`
 }
 
 type getRatingResMsg struct {
 	Player1 struct {
-		ID   string `json:"id"`
-		Rate int    `json:"rate"`
+		ID        string  `json:"id"`
+		Rate      int     `json:"rate"`
+		Deviation float64 `json:"deviation,omitempty"`
 	} `json:"player1"`
 	Player2 struct {
-		ID   string `json:"id"`
-		Rate int    `json:"rate"`
+		ID        string  `json:"id"`
+		Rate      int     `json:"rate"`
+		Deviation float64 `json:"deviation,omitempty"`
 	} `json:"player2"`
 }
 
@@ -66,12 +88,21 @@ type updateRatingResMsg struct {
 	Number   int    `json:"number"`
 	Hash     string `json:"hash"`
 	Result   string `json:"result"`
+	// 両者の署名付きの結果で、レーティングサーバは署名が揃うか裁定者が決めた結果だけを受け付けます
+	Signed *identity.SignedResult `json:"signed,omitempty"`
 }
 
 func main() {
+	setupLogger()
 	mmURL := url.URL{Scheme: wsScheme, Host: matchmakingOrigin, Path: "/matchmaking"}
 	signalingURL := url.URL{Scheme: wsScheme, Host: signalingOrigin, Path: "/signaling"}
 	ratingURL := url.URL{Scheme: httpScheme, Host: ratingOrigin, Path: "/rating"}
+	tournamentURL := url.URL{Scheme: httpScheme, Host: tournamentOrigin, Path: "/tournament"}
+	identityURL := url.URL{Scheme: httpScheme, Host: identityOrigin}
+	identityClient = identity.NewClient(identityURL)
+	profileURL := url.URL{Scheme: httpScheme, Host: profileOrigin, Path: "/profile"}
+	leaderboardURL := url.URL{Scheme: httpScheme, Host: leaderboardOrigin, Path: "/leaderboard"}
+	dailyURL := url.URL{Scheme: httpScheme, Host: dailyOrigin, Path: "/daily"}
 
 	now := time.Now()
 	window := js.Global().Get("window")
@@ -87,15 +118,16 @@ func main() {
 		localStorage.Set("hash", sha256Hash(solt+userID+solt))
 		hash = sha256Hash(solt + userID + solt)
 	}
-	reqMsg, err := json.Marshal(mmReqMsg{
-		UserID:    userID,
-		CreatedAt: now,
-	})
-	if err != nil {
-		log.Fatal(err)
+	// identity サーバに登録できればそのアカウントで遊び、できなければ従来の userID と hash を使う
+	if identityOrigin != "" {
+		if id, err := loadIdentity(identityURL, userID); err != nil {
+			logger.Error("failed to load identity", "err", err)
+		} else {
+			userID = id
+		}
 	}
 	var resMsg mmResMsg
-	var dc *webrtc.DataChannel
+	var dc transport.Transport
 	ch := make(chan *game.Guess)
 	defer func() {
 		if dc != nil {
@@ -103,123 +135,695 @@ func main() {
 		}
 	}()
 	var conn *ayame.Connection
-	connected := make(chan bool)
+	finChan := make(chan *finishedGame)
 	board := game.NewBoard()
 
-	js.Global().Set("Search", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
-		js.Global().Get("document").Call("getElementById", "start").Set("disabled", true)
-		go func() {
-			ws, _, err := websocket.Dial(context.Background(), mmURL.String(), nil)
+	// connectMatch は resMsg のルームで相手と接続し、対局を始めます
+	// プライベートルームでは相手の userID を hello で受け取るまで空です
+	connectMatch := func() {
+		setLogRoom(resMsg.RoomID)
+		private := resMsg.UserID == ""
+		onHello := func(message Message) {
+			resMsg.UserID = message.From
+			spectators.setOpponent(message.From)
+			logElem(fmt.Sprintf("[Sys]: %s joined\n", message.From))
+			myRating, opRating, err := getRating(ratingURL, userID, resMsg.UserID)
 			if err != nil {
-				log.Fatal(err)
+				logger.Error("failed to get rating", "err", err)
+				return
 			}
-			defer ws.Close(websocket.StatusNormalClosure, "close connection")
+			setProfile(userID, resMsg.UserID, myRating, opRating)
+		}
+		// host は後から入室した側で、経路が開いたら対局を始めます
+		host := func() {
+			go func() {
+				rand.NewSource(time.Now().UnixNano())
+				seed := rand.Int()
 
-			if err := ws.Write(context.Background(), websocket.MessageText, reqMsg); err != nil {
-				log.Fatal(err)
+				initTurn := game.NewTurnBySeed(seed)
+				// 大会では先手の回数を揃えるため大会が指定した先手に従う
+				if tournamentFirst != "" {
+					initTurn = game.OpTurn
+					if tournamentFirst == userID {
+						initTurn = game.MyTurn
+					}
+				}
+				tc, err := game.NewTimeControlFromText(getElementByID("time-control").Get("value").String())
+				if err != nil {
+					logger.Warn("invalid time control, use default", "err", err)
+					tc = game.DefaultTimeControl()
+				}
+				if !private {
+					myRating, opRating, err := getRating(ratingURL, userID, resMsg.UserID)
+					if err != nil {
+						logger.Error("failed to get rating", "err", err)
+						return
+					}
+					setProfile(userID, resMsg.UserID, myRating, opRating)
+				}
+				spectators = newSpectatorHub(userID, resMsg.UserID)
+				spectators.open(signalingURL, resMsg.RoomID)
+				bestOf, err := strconv.Atoi(getElementByID("best-of").Get("value").String())
+				if err != nil {
+					bestOf = 1
+				}
+				match = game.NewMatch(bestOf)
+				board.SetVariant(game.NewVariantFromText(getElementByID("variant").Get("value").String()))
+				rules, err := game.NewRulesFromText(getElementByID("rules").Get("value").String())
+				if err != nil {
+					logger.Warn("invalid rules, use default", "err", err)
+					rules = game.DefaultRules()
+				}
+				board.SetRules(rules)
+				startProcess(dc, board, initTurn, tc)
+			}()
+			handler := onMessage(dc, ch, finChan, board)
+			if private {
+				sendHello(dc, userID)
+				handler = withHello(handler, onHello)
 			}
-			logElem("[Sys]: Waiting match...\n")
-			for {
-				if err := wsjson.Read(context.Background(), ws, &resMsg); err != nil {
-					log.Fatal(err)
-					break
+			dc.OnMessage(handler)
+			dc.OnClose(onClose(dc, finChan, board))
+			go func() {
+				for g := range finChan {
+					id := matchID(resMsg.RoomID, g.round)
+					reportGameRecord(profileURL, id, userID, resMsg.UserID, hash, g)
+					// マッチ全体で1つの結果として報告する
+					if !g.decided {
+						continue
+					}
+					signed := signResult(dc, g, id, [2]string{userID, resMsg.UserID})
+					if unrated {
+						logElem("[Sys]: Unrated match, rating is not updated\n")
+					} else if err := updateRating(ratingURL, id, userID, hash, 1, g.result, signed); err != nil {
+						logger.Error("failed to update rating", "err", err)
+					}
+					if tournamentID != "" {
+						finishTournamentMatch(tournamentURL, id, userID, hash, 1, g.result, signed)
+					}
 				}
-				if resMsg.Type == "MATCH" {
-					break
+			}()
+		}
+		// guest は先に入室した側で、host が始めた対局に応じます
+		guest := func() {
+			logger.Debug("ready to receive")
+			handler := onMessage(dc, ch, finChan, board)
+			if private {
+				sendHello(dc, userID)
+				handler = withHello(handler, onHello)
+			} else {
+				myRating, opRating, err := getRating(ratingURL, userID, resMsg.UserID)
+				if err != nil {
+					logger.Error("failed to get rating", "err", err)
+					return
 				}
+				setProfile(userID, resMsg.UserID, myRating, opRating)
 			}
-			ws.Close(websocket.StatusNormalClosure, "close connection")
-			if resMsg.Type == "MATCH" {
-				conn = ayame.NewConnection(signalingURL.String(), resMsg.RoomID, ayame.DefaultOptions(), false, false)
-				conn.OnOpen(func(metadata *interface{}) {
-					var err error
-					dc, err = conn.CreateDataChannel("matchmaking-hit-and-blow", nil)
-					if err != nil && err != fmt.Errorf("client does not exist") {
-						log.Printf("CreateDataChannel error: %v", err)
-						return
+			dc.OnMessage(handler)
+			dc.OnClose(onClose(dc, finChan, board))
+			go func() {
+				for g := range finChan {
+					id := matchID(resMsg.RoomID, g.round)
+					reportGameRecord(profileURL, id, userID, resMsg.UserID, hash, g)
+					if !g.decided {
+						continue
 					}
-					log.Printf("CreateDataChannel: label=%s", dc.Label())
-					go func() {
-						rand.NewSource(time.Now().UnixNano())
-						seed := rand.Int()
-
-						initTurn := game.NewTurnBySeed(seed)
-						myHand := game.NewHandBySeed(seed)
-						log.Printf("myHand(opener): %v", myHand)
-						setHand(true, myHand)
-						board.Start(myHand, initTurn, 1)
-						if board.IsMyTurnInit() {
-							log.Printf("YOU FIRST !!!")
-							setTurn("It's Your Turn !")
-						}
-						turn := int(initTurn)
-						myRate, opRate, err := getRating(ratingURL, userID, resMsg.UserID)
-						if err != nil {
-							log.Printf("failed to get rating: %v", err)
-							return
-						}
-						setProfile(userID, resMsg.UserID, myRate, opRate)
-						startMsg := Message{Type: "start", Turn: &turn}
-						by, _ := json.Marshal(startMsg)
-						time.Sleep(1 * time.Second)
-						log.Printf("startMsg(opener): %v", string(by))
-						if err := dc.SendText(string(by)); err != nil {
-							log.Printf("failed to send startMsg: %v", err)
-							return
-						}
-					}()
-					finChan := make(chan struct{})
-					dc.OnMessage(onMessage(dc, ch, finChan, board))
-					go func() {
-						select {
-						case <-finChan:
-							if err := updateRating(ratingURL, resMsg.RoomID, userID, hash, 1, board.Result()); err != nil {
-								log.Printf("failed to update rating: %v", err)
-								return
-							}
-						}
-					}()
-				})
-
-				conn.OnConnect(func() {
-					logElem("[Sys]: Matching! Start P2P chat not via server\n")
-					conn.CloseWebSocketConnection()
-					connected <- true
-				})
-
-				conn.OnDataChannel(func(c *webrtc.DataChannel) {
-					log.Printf("OnDataChannel: label=%s", c.Label())
-					if dc == nil {
-						dc = c
+					signed := signResult(dc, g, id, [2]string{resMsg.UserID, userID})
+					if unrated {
+						logElem("[Sys]: Unrated match, rating is not updated\n")
+					} else if err := updateRating(ratingURL, id, userID, hash, 2, g.result, signed); err != nil {
+						logger.Error("failed to update rating", "err", err)
 					}
-					log.Println("ready to recieve")
-					myRate, opRate, err := getRating(ratingURL, userID, resMsg.UserID)
-					if err != nil {
-						log.Printf("failed to get rating: %v", err)
-						return
+					if tournamentID != "" {
+						finishTournamentMatch(tournamentURL, id, userID, hash, 2, g.result, signed)
 					}
-					setProfile(userID, resMsg.UserID, myRate, opRate)
-					finChan := make(chan struct{})
-					dc.OnMessage(onMessage(dc, ch, finChan, board))
-					go func() {
-						select {
-						case <-finChan:
-							if err := updateRating(ratingURL, resMsg.RoomID, userID, hash, 2, board.Result()); err != nil {
-								log.Printf("failed to update rating: %v", err)
-								return
-							}
-						}
-					}()
-				})
-
-				if err := conn.Connect(); err != nil {
-					log.Fatal("failed to connect Ayame", err)
-				}
-				select {
-				case <-connected:
+				}
+			}()
+		}
+		// useRelay は中継サーバを経由して相手とつながります
+		useRelay := func() {
+			t, initiator, err := dialRelay(resMsg.RoomID)
+			if err != nil {
+				logger.Error("failed to connect relay", "err", err)
+				logElem("[Sys]: Failed to connect to the opponent\n")
+				return
+			}
+			dc = t
+			// 中継サーバではチャットも対局の経路に混ぜる
+			chat.attach(t)
+			logElem("[Sys]: Matching! Start connection via relay server\n")
+			showRelayStats()
+			if initiator {
+				host()
+			} else {
+				guest()
+			}
+		}
+		if transportMode() == transportRelay {
+			useRelay()
+			return
+		}
+
+		// TURN で接続し直すたびに新しい Connection に設定し直す
+		setup := func(next *ayame.Connection) {
+			conn = next
+			// 前の接続で作った DataChannel は使わない
+			dc = nil
+			authorizeSignaling(conn)
+			attachVoice(conn)
+			conn.OnOpen(func(metadata *interface{}) {
+				c, err := conn.CreateDataChannel("matchmaking-hit-and-blow", nil)
+				if err != nil && err != fmt.Errorf("client does not exist") {
+					logger.Error("failed to create DataChannel", "err", err)
+					return
+				}
+				dc = transport.NewDataChannel(c)
+				logger.Debug("DataChannel created", "label", dc.Label())
+				if c, err := conn.CreateDataChannel(chatLabel, nil); err != nil {
+					logger.Error("failed to create DataChannel", "err", err)
+				} else {
+					chat.attachDataChannel(c)
+				}
+				// 中継サーバに切り替えた後に始めないように、開いてから始める
+				dc.OnOpen(host)
+			})
+
+			conn.OnDisconnect(func(reason string, err error) {
+				// シグナリングの WebSocket 切断は対局に影響しない
+				if reason == "EXIT-RECV" {
+					return
+				}
+				logger.Warn("disconnected", "reason", reason, "err", err)
+				abandonProcess(dc, board, finChan)
+			})
+
+			conn.OnConnect(func() {
+				logElem("[Sys]: Matching! Start P2P connection not via server\n")
+				conn.CloseWebSocketConnection()
+				// 接続し直したら新しい Connection の表示に切り替える
+				go watchStats(next, func() bool { return conn == next })
+			})
+
+			conn.OnDataChannel(func(c *webrtc.DataChannel) {
+				logger.Debug("DataChannel received", "label", c.Label())
+				if c.Label() == chatLabel {
+					chat.attachDataChannel(c)
 					return
 				}
+				if dc == nil {
+					dc = transport.NewDataChannel(c)
+				}
+				guest()
+			})
+		}
+		strategy := ayame.Strategy{Credentials: turnCredentials(identityURL)}
+		_, path, err := ayame.Dial(signalingURL.String(), resMsg.RoomID, connectionOptions(), debugLog, strategy, setup)
+		if err == nil {
+			logElem(fmt.Sprintf("[Sys]: Connected via %s\n", pathView(path)))
+			return
+		}
+		logger.Error("failed to connect P2P", "err", err)
+		// つながらなかった原因を調べられるように自己診断を表示する
+		go runSelfTest()
+		if transportMode() != transportAuto {
+			logElem("[Sys]: Failed to connect to the opponent\n")
+			return
+		}
+		// P2P でつながらないネットワークでは中継サーバに切り替える
+		logElem("[Sys]: P2P connection failed, switch to relay server\n")
+		useRelay()
+	}
+
+	js.Global().Set("Search", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if queue != nil || dc != nil {
+			return js.Undefined()
+		}
+		js.Global().Get("document").Call("getElementById", "start").Set("disabled", true)
+		getElementByID("cancel-search").Set("disabled", false)
+		// 同じ条件を選んだ相手とだけマッチングする
+		unrated = getElementByID("unrated").Get("checked").Bool()
+		rated := !unrated
+		reqMsg := mmReqMsg{
+			UserID:      userID,
+			CreatedAt:   time.Now(),
+			Variant:     getElementByID("variant").Get("value").String(),
+			TimeControl: getElementByID("time-control").Get("value").String(),
+			Rated:       &rated,
+			Region:      region(),
+			Token:       authToken(identity.AudienceMatchmaking),
+		}
+		// まだ対局していなければ載せず、キューは初めてのプレイヤーとして扱う
+		if searchRating != nil {
+			reqMsg.Rate, reqMsg.Deviation = searchRating.Rate(), searchRating.Deviation()
+		}
+		go func() {
+			res, err := search(mmURL, reqMsg)
+			getElementByID("cancel-search").Set("disabled", true)
+			if err != nil {
+				logger.Error("failed to search match", "err", err)
+			}
+			if res == nil {
+				setQueueStatus("")
+				getElementByID("start").Set("disabled", false)
+				return
+			}
+			resMsg = *res
+			connectMatch()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("CancelSearch", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if queue == nil {
+				return
 			}
+			hideOffer()
+			queue.cancel()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("CreatePrivate", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if dc != nil || resMsg.RoomID != "" {
+			return js.Undefined()
+		}
+		code := newPrivateCode()
+		getElementByID("private-code").Set("value", code)
+		getElementByID("start").Set("disabled", true)
+		unrated = getElementByID("unrated").Get("checked").Bool()
+		go func() {
+			logElem(fmt.Sprintf("[Sys]: Private room %s, share the code with your friend\n", code))
+			resMsg = mmResMsg{Type: "MATCH", RoomID: privateRoomID(code), CreatedAt: time.Now()}
+			connectMatch()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("JoinPrivate", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if dc != nil || resMsg.RoomID != "" {
+			return js.Undefined()
+		}
+		code := getElementByID("private-code").Get("value").String()
+		if strings.TrimSpace(code) == "" {
+			js.Global().Call("alert", "Invite code must not be empty")
+			return js.Undefined()
+		}
+		getElementByID("start").Set("disabled", true)
+		unrated = getElementByID("unrated").Get("checked").Bool()
+		go func() {
+			resMsg = mmResMsg{Type: "MATCH", RoomID: privateRoomID(code), CreatedAt: time.Now()}
+			connectMatch()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("JoinTournament", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if tournamentID != "" || dc != nil {
+			return js.Undefined()
+		}
+		id := getElementByID("tournament-id").Get("value").String()
+		if id == "" {
+			js.Global().Call("alert", "Tournament ID must not be empty")
+			return js.Undefined()
+		}
+		getElementByID("start").Set("disabled", true)
+		go func() {
+			tournamentID = id
+			if err := registerTournament(tournamentURL, userID, hash); err != nil {
+				js.Global().Call("alert", err.Error())
+				return
+			}
+			logElem(fmt.Sprintf("[Tournament]: Registered to %s\n", tournamentID))
+			pairing, err := waitTournamentPairing(tournamentURL, userID)
+			if err != nil {
+				logger.Error("failed to get pairing", "err", err)
+				return
+			}
+			if pairing.Type == "FINISH" {
+				showTournamentStandings(tournamentURL)
+				return
+			}
+			logElem(fmt.Sprintf("[Tournament]: Round %d vs %s\n", pairing.Round, pairing.UserID))
+			resMsg = mmResMsg{Type: pairing.Type, RoomID: pairing.RoomID, UserID: pairing.UserID, CreatedAt: time.Now()}
+			tournamentFirst = pairing.First
+			connectMatch()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("ClaimWin", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if dc == nil || !board.CanClaimTimeout() {
+				return
+			}
+			if err := sendMessage(dc, Message{Type: "flag"}); err != nil {
+				logger.Error("failed to send flagMsg", "err", err)
+			}
+			logElem("[Sys]: Opponent Timeout! You Win!\n")
+			board.Timeout(game.OpTurn)
+			setJudge(board.Judge())
+			finishProcess(dc, board, finChan)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("Resign", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if dc == nil || !board.IsPlaying() {
+				return
+			}
+			if err := sendMessage(dc, Message{Type: "resign"}); err != nil {
+				logger.Error("failed to send resignMsg", "err", err)
+			}
+			logElem("[Sys]: You Resigned! You Lose!\n")
+			board.Resign(game.MyTurn)
+			setJudge(board.Judge())
+			finishProcess(dc, board, finChan)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("OfferDraw", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if dc == nil || !board.IsPlaying() {
+				return
+			}
+			if err := sendMessage(dc, Message{Type: "draw_offer"}); err != nil {
+				logger.Error("failed to send drawOfferMsg", "err", err)
+				return
+			}
+			logElem("[Sys]: Draw Offered, Waiting ...\n")
+			getElementByID("draw").Set("disabled", true)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("OfferRematch", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if dc == nil || !board.IsFinished() {
+				return
+			}
+			if err := sendMessage(dc, Message{Type: "rematch_offer"}); err != nil {
+				logger.Error("failed to send rematchOfferMsg", "err", err)
+				return
+			}
+			setTurn("Rematch Offered, Waiting ...")
+			getElementByID("rematch").Set("disabled", true)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("AcceptOffer", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			offer := pendingOffer
+			hideOffer()
+			switch offer {
+			case "match":
+				if queue != nil {
+					queue.respond(true)
+				}
+			case "draw":
+				if !board.IsPlaying() {
+					return
+				}
+				if err := sendMessage(dc, Message{Type: "draw_accept"}); err != nil {
+					logger.Error("failed to send drawAcceptMsg", "err", err)
+					return
+				}
+				board.AgreeDraw()
+				setJudge(board.Judge())
+				finishProcess(dc, board, finChan)
+			case "rematch":
+				if !board.IsFinished() {
+					return
+				}
+				if err := sendMessage(dc, Message{Type: "rematch_accept"}); err != nil {
+					logger.Error("failed to send rematchAcceptMsg", "err", err)
+					return
+				}
+				rematchProcess(dc, board)
+			}
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("DeclineOffer", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			offer := pendingOffer
+			hideOffer()
+			if offer == "match" && queue != nil {
+				queue.respond(false)
+				return
+			}
+			if offer != "draw" {
+				return
+			}
+			if err := sendMessage(dc, Message{Type: "draw_decline"}); err != nil {
+				logger.Error("failed to send drawDeclineMsg", "err", err)
+			}
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("UseItem", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		if len(args) == 0 {
+			return js.Undefined()
+		}
+		itemName := args[0].String()
+		go func() {
+			item, err := game.NewItemFromText(itemName)
+			if err != nil {
+				logger.Warn("invalid item", "err", err)
+				return
+			}
+			if dc == nil {
+				return
+			}
+			if err := board.CanUseItem(game.MyTurn, item); err != nil {
+				js.Global().Call("alert", err.Error())
+				return
+			}
+			itemMsg := Message{Type: "item", Item: item.Msg()}
+			var info string
+			switch item {
+			case game.Target:
+				digit, err := promptInt("TARGET: digit (0-9)")
+				if err != nil || digit < 0 || digit > 9 {
+					return
+				}
+				itemMsg.Digit = &digit
+			case game.Shuffle:
+				value := js.Global().Call("prompt", "SHUFFLE: new order of your hand", board.MyHandText())
+				if value.IsNull() {
+					return
+				}
+				next := game.NewHandFromText(value.String())
+				if err := board.ShuffleMyHand(next); err != nil {
+					js.Global().Call("alert", err.Error())
+					return
+				}
+				setHand(true, next)
+			case game.Change:
+				pos, err := promptInt("CHANGE: position (1-3)")
+				if err != nil {
+					return
+				}
+				digit, err := promptInt("CHANGE: new digit (same high/low)")
+				if err != nil {
+					return
+				}
+				highLow, err := board.ChangeMyHand(pos, digit)
+				if err != nil {
+					js.Global().Call("alert", err.Error())
+					return
+				}
+				setHand(true, board.MyHand())
+				itemMsg.Position, itemMsg.Info = &pos, highLow
+				info = fmt.Sprintf("pos %d -> %d", pos, digit)
+			case game.Double:
+				pos, err := promptInt("DOUBLE: position of your hand to reveal (1-3)")
+				if err != nil {
+					return
+				}
+				digit, err := board.RevealMyDigit(pos)
+				if err != nil {
+					js.Global().Call("alert", err.Error())
+					return
+				}
+				itemMsg.Position, itemMsg.Digit = &pos, &digit
+				info = fmt.Sprintf("pos %d = %d", pos, digit)
+			}
+			if err := board.UseItem(game.MyTurn, item); err != nil {
+				js.Global().Call("alert", err.Error())
+				return
+			}
+			if err := sendMessage(dc, itemMsg); err != nil {
+				logger.Error("failed to send itemMsg", "err", err)
+				return
+			}
+			getElementByID("item-"+item.Msg()).Set("disabled", true)
+			switch item {
+			case game.Shuffle, game.Change:
+				appendItemLog(board.AddItemUse(game.MyTurn, item, info))
+			case game.Double:
+				board.ArmDouble(game.MyTurn)
+				appendItemLog(board.AddItemUse(game.MyTurn, item, info))
+			}
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("CreateRoom", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if currentRoom != nil {
+			return js.Undefined()
+		}
+		go func() {
+			currentRoom = newRoomClient(shortHash(time.Now()), userID, hash, true, signalingURL, ratingURL)
+			getElementByID("room-code").Set("value", currentRoom.code)
+			getElementByID("start").Set("disabled", true)
+			renderRoomLobby(currentRoom.lobby())
+			currentRoom.openSeats()
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("JoinRoom", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if currentRoom != nil {
+			return js.Undefined()
+		}
+		code := getElementByID("room-code").Get("value").String()
+		if code == "" {
+			js.Global().Call("alert", "Room code must not be empty")
+			return js.Undefined()
+		}
+		go func() {
+			currentRoom = newRoomClient(code, userID, hash, false, signalingURL, ratingURL)
+			getElementByID("start").Set("disabled", true)
+			currentRoom.joinSeat(1)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("StartRoom", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if currentRoom == nil || !currentRoom.isHost || currentRoom.room.IsPlaying() || currentRoom.coop.IsPlaying() {
+				return
+			}
+			ids := currentRoom.lobby()
+			if len(ids) < game.MinRoomPlayers {
+				js.Global().Call("alert", fmt.Sprintf("Room needs at least %d players", game.MinRoomPlayers))
+				return
+			}
+			currentRoom.send(Message{Type: "room_start", Players: ids})
+			currentRoom.start(ids)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("StartCoop", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if currentRoom == nil || !currentRoom.isHost || currentRoom.room.IsPlaying() || currentRoom.coop.IsPlaying() {
+				return
+			}
+			// 入力欄に手札があればホストがディーラーとなり、なければボットが手札を選ぶ
+			var hand *game.Hand
+			el := getElementByID("input-number")
+			if text := el.Get("value").String(); text != "" {
+				hand = game.NewHandFromText(text)
+				if !hand.IsValid() {
+					js.Global().Call("alert", "Secret must be 3 different digits")
+					return
+				}
+				el.Set("value", "")
+				for i := 0; i <= 9; i++ {
+					getElementByID("input-"+strconv.Itoa(i)).Set("disabled", false)
+				}
+			}
+			currentRoom.dealCoop(hand)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("SendChat", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			el := getElementByID("room-chat")
+			text := el.Get("value").String()
+			if currentRoom == nil || text == "" {
+				return
+			}
+			currentRoom.sendChat(text)
+			el.Set("value", "")
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("StartDaily", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go func() {
+			if currentDaily != nil || currentRoom != nil || dc != nil {
+				return
+			}
+			startDaily(dailyURL)
+		}()
+		return js.Undefined()
+	}))
+	js.Global().Set("ShareDaily", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go copyDailyShare()
+		return js.Undefined()
+	}))
+	js.Global().Set("ShowProfile", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		id := strings.TrimSpace(getElementByID("profile-id").Get("value").String())
+		if id == "" {
+			id = userID
+		}
+		go showProfile(profileURL, id)
+		return js.Undefined()
+	}))
+	js.Global().Set("SendChatMessage", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		el := getElementByID("chat-input")
+		text := el.Get("value").String()
+		el.Set("value", "")
+		go chat.send(chatMsg{Text: text})
+		return js.Undefined()
+	}))
+	js.Global().Set("SendEmote", js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		if len(args) == 0 {
+			return js.Undefined()
+		}
+		go chat.send(chatMsg{Emote: args[0].String()})
+		return js.Undefined()
+	}))
+	js.Global().Set("ToggleMute", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		label := "MUTE"
+		if chat.toggleMute() {
+			label = "UNMUTE"
+		}
+		getElementByID("mute-chat").Set("innerHTML", label)
+		return js.Undefined()
+	}))
+	js.Global().Set("StartTalking", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		setTalking(conn, true)
+		return js.Undefined()
+	}))
+	js.Global().Set("StopTalking", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		setTalking(conn, false)
+		return js.Undefined()
+	}))
+	js.Global().Set("RunDiagnostics", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go runSelfTest()
+		return js.Undefined()
+	}))
+	js.Global().Set("ExportLogs", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		exportDebugBundle()
+		return js.Undefined()
+	}))
+	js.Global().Set("ShowLeaderboard", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		go showLeaderboard(leaderboardURL, getElementByID("leaderboard-view").Get("value").String(), userID)
+		return js.Undefined()
+	}))
+	js.Global().Set("AddFriend", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		el := getElementByID("profile-id")
+		addFriend(el.Get("value").String())
+		el.Set("value", "")
+		return js.Undefined()
+	}))
+	js.Global().Set("Watch", js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
+		if currentSpectator != nil || currentRoom != nil || currentDaily != nil || dc != nil {
+			return js.Undefined()
+		}
+		roomID := getElementByID("room-code").Get("value").String()
+		if roomID == "" {
+			js.Global().Call("alert", "Room ID must not be empty")
+			return js.Undefined()
+		}
+		go func() {
+			currentSpectator = &spectatorClient{}
+			getElementByID("start").Set("disabled", true)
+			setTurn("Connecting ...")
+			watch(signalingURL, roomID, 1)
 		}()
 		return js.Undefined()
 	}))
@@ -231,6 +835,33 @@ func main() {
 				js.Global().Call("alert", "Message must not be empty")
 				return
 			}
+			if currentDaily != nil {
+				if !currentDaily.IsPlaying() {
+					return
+				}
+				sendDailyGuess(dailyURL, game.NewGuessFromText(message))
+				el.Set("value", "")
+				for i := 0; i <= 9; i++ {
+					getElementByID("input-"+strconv.Itoa(i)).Set("disabled", false)
+				}
+				return
+			}
+			if currentRoom != nil {
+				switch {
+				case currentRoom.coop.IsMyTurn():
+					currentRoom.sendCoopGuess(game.NewGuessFromText(message))
+				case currentRoom.room.IsMyTurn():
+					target := getElementByID("room-target").Get("value").String()
+					currentRoom.sendGuess(target, game.NewGuessFromText(message))
+				default:
+					return
+				}
+				el.Set("value", "")
+				for i := 0; i <= 9; i++ {
+					getElementByID("input-"+strconv.Itoa(i)).Set("disabled", false)
+				}
+				return
+			}
 			if dc == nil {
 				return
 			}
@@ -249,7 +880,6 @@ func main() {
 		s := strconv.Itoa(i)
 		js.Global().Set("Input"+s, js.FuncOf(func(_ js.Value, _ []js.Value) interface{} {
 			go func() {
-				log.Printf("Input: %s\n", s)
 				el := getElementByID("input-number")
 				message := el.Get("value").String()
 				if len(message) >= 3 {
@@ -286,51 +916,116 @@ func sha256Hash(s string) string {
 }
 
 type Message struct {
-	Type   string `json:"type"`
-	Turn   *int   `json:"turn,omitempty"`
-	Hit    *int   `json:"hit,omitempty"`
-	Blow   *int   `json:"blow,omitempty"`
-	Guess  string `json:"guess,omitempty"`
-	MyHand string `json:"my_hand,omitempty"`
-}
-
-func onMessage(dc *webrtc.DataChannel, ch chan *game.Guess, finChan chan struct{}, board *game.Board) func(webrtc.DataChannelMessage) {
-	return func(msg webrtc.DataChannelMessage) {
-		log.Printf("recieve msg.Data: %s", string(msg.Data))
-		if !msg.IsString {
-			return
-		}
+	Type        string   `json:"type"`
+	Turn        *int     `json:"turn,omitempty"`
+	Hit         *int     `json:"hit,omitempty"`
+	Blow        *int     `json:"blow,omitempty"`
+	Guess       string   `json:"guess,omitempty"`
+	MyHand      string   `json:"my_hand,omitempty"`
+	TimeControl string   `json:"time_control,omitempty"`
+	BestOf      *int     `json:"best_of,omitempty"`
+	Variant     string   `json:"variant,omitempty"`
+	Rules       string   `json:"rules,omitempty"`
+	Item        string   `json:"item,omitempty"`
+	Digit       *int     `json:"digit,omitempty"`
+	Position    *int     `json:"position,omitempty"`
+	Info        string   `json:"info,omitempty"`
+	From        string   `json:"from,omitempty"`
+	Target      string   `json:"target,omitempty"`
+	Players     []string `json:"players,omitempty"`
+	Rated       *bool    `json:"rated,omitempty"`
+	Commitment  string   `json:"commitment,omitempty"`
+	Nonce       string   `json:"nonce,omitempty"`
+	Signature   string   `json:"signature,omitempty"`
+	MatchID     string   `json:"match_id,omitempty"`
+	Emote       string   `json:"emote,omitempty"`
+	Text        string   `json:"text,omitempty"`
+}
+
+// LogValue はログに出力する Message の値です
+// 手札は ReplaceSecrets で伏せられるように hand のキーで出力します
+func (m Message) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("type", m.Type)}
+	if m.Turn != nil {
+		attrs = append(attrs, slog.Int("turn", *m.Turn))
+	}
+	if m.Guess != "" {
+		attrs = append(attrs, slog.String("guess", m.Guess))
+	}
+	if m.Hit != nil && m.Blow != nil {
+		attrs = append(attrs, slog.Int("hit", *m.Hit), slog.Int("blow", *m.Blow))
+	}
+	if m.Item != "" {
+		attrs = append(attrs, slog.String("item", m.Item))
+	}
+	if m.MyHand != "" {
+		attrs = append(attrs, slog.String("hand", m.MyHand))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func onMessage(dc transport.Transport, ch chan *game.Guess, finChan chan *finishedGame, board *game.Board) func([]byte) {
+	return func(data []byte) {
 		var message Message
-		if err := json.Unmarshal(msg.Data, &message); err != nil {
-			log.Printf("failed to unmarshal: %v", err)
+		if err := json.Unmarshal(data, &message); err != nil {
+			logger.Error("failed to unmarshal", "err", err)
 			return
 		}
-		// logElem(fmt.Sprintf("[Any]: %s\n", msg.Data))
+		// 手札を含むので、メッセージ自体ではなく LogValue を出力する
+		boardLogger(board).Debug("receive message", "message", message)
+		// logElem(fmt.Sprintf("[Any]: %s\n", data))
 		switch message.Type {
 		case "start":
+			// 非開室者Only: マッチの次局は開室者の start を受けて盤面を初期化する
+			if board.IsFinished() && !match.IsDecided() {
+				rematchProcess(dc, board)
+			}
 			// 非開室者Only: GameStart処理
 			if board.IsInMenu() {
-				log.Printf("message.Turn: %v", *message.Turn)
+				if match == nil {
+					bestOf := 1
+					if message.BestOf != nil {
+						bestOf = *message.BestOf
+					}
+					match = game.NewMatch(bestOf)
+				}
 				initTurn := game.Turn(*message.Turn).Reverse()
 				rand.NewSource(time.Now().UnixNano())
 				seed := rand.Int()
 				myHand := game.NewHandBySeed(seed)
-				log.Printf("myHand(unopener): %v", myHand)
 				setHand(true, myHand)
-				board.Start(myHand, initTurn, 2)
-				if board.IsMyTurnInit() {
-					log.Printf("YOU FIRST!!!")
+				// 持ち時間は開室者の設定に合わせる
+				tc, err := game.NewTimeControlFromText(message.TimeControl)
+				if err != nil {
+					logger.Warn("invalid time control, use default", "err", err)
+					tc = game.DefaultTimeControl()
+				}
+				board.Start(myHand, initTurn, 2, tc)
+				board.SetVariant(game.NewVariantFromText(message.Variant))
+				// 勝敗判定のルールも開室者に合わせる
+				rules, err := game.NewRulesFromText(message.Rules)
+				if err != nil {
+					logger.Warn("invalid rules, use default", "err", err)
+					rules = game.DefaultRules()
 				}
+				board.SetRules(rules)
+				onCommit(board, message)
+				if err := sendMessage(dc, Message{Type: "commit", Commitment: board.Commitment(game.MyTurn)}); err != nil {
+					logger.Error("failed to send commitment", "err", err)
+				}
+				setItems(board)
+				board.StartClock()
+				go watchOpClock(board)
+				boardLogger(board).Info("game started", "opener", false, "first", board.IsMyTurnInit())
 			}
 			// 非開室者Only: 初回が後攻のときに開室者を初回guess処理に誘導
 			if board.IsOpTurn() {
 				startMsg := Message{Type: "start"}
 				by, _ := json.Marshal(startMsg)
-				if err := dc.SendText(string(by)); err != nil {
-					log.Printf("failed to send startMsg: %v", err)
+				if err := dc.Send(by); err != nil {
+					logger.Error("failed to send startMsg", "err", err)
 					return
 				}
-				log.Printf("startMsg(unopener): %v", string(by))
 				setTurn("It's Opponent's Turn, Waiting ...")
 				return
 			}
@@ -340,22 +1035,45 @@ func onMessage(dc *webrtc.DataChannel, ch chan *game.Guess, finChan chan struct{
 			if board.IsMyTurn() {
 				return
 			}
-			// 自分ターンへ遷移
-			board.ToggleTurn()
-			setTurn("It's Your Turn !")
 			guess := game.NewGuessFromText(message.Guess)
 			ans := board.CalcAnswer(guess)
+			// DOUBLE の1回目で当たらなければ手番は交代しない
+			keepTurn := board.IsBonusCall() && !ans.IsAllHit()
+			if !keepTurn {
+				// 自分ターンへ遷移
+				if !board.ToggleTurn() {
+					// 相手の持ち時間切れ後に届いたguessは受け付けない
+					if err := sendMessage(dc, Message{Type: "flag"}); err != nil {
+						logger.Error("failed to send flagMsg", "err", err)
+						return
+					}
+					logElem("[Sys]: Opponent Timeout! You Win!\n")
+					board.Timeout(game.OpTurn)
+					setJudge(board.Judge())
+					finishProcess(dc, board, finChan)
+					return
+				}
+				setTurn("It's Your Turn !")
+				board.CountTurn()
+			}
 			hit, blow := ans.Hit(), ans.Blow()
 			ansMsg := Message{Type: "answer", Hit: &hit, Blow: &blow}
 			by, _ := json.Marshal(ansMsg)
-			board.CountTurn()
-			board.AddOpQA(game.NewQA(guess, ans))
-			setScore(board, guess.View(), hit, blow)
-			j := board.Judge()
-			setJudge(j)
-			log.Printf("ansMsg: %v", string(by))
-			if err := dc.SendText(string(by)); err != nil {
-				log.Printf("failed to send ansMsg: %v", err)
+			qa := game.NewQA(guess, ans)
+			board.AddOpQA(qa)
+			spectators.call(game.OpTurn, qa)
+			board.EndBonusCall()
+			setScore(game.OpTurn, board.GuessCount(game.OpTurn), guess.View(), hit, blow)
+			j := game.NotYet
+			if !keepTurn {
+				j = board.Judge()
+				setJudge(j)
+			}
+			if err := dc.Send(by); err != nil {
+				logger.Error("failed to send ansMsg", "err", err)
+				return
+			}
+			if keepTurn {
 				return
 			}
 			if j != game.NotYet {
@@ -364,13 +1082,30 @@ func onMessage(dc *webrtc.DataChannel, ch chan *game.Guess, finChan chan struct{
 			}
 			// guess送信処理に続く
 		case "answer":
-			if board.IsMyTurn() {
+			bonus := board.IsBonusCall()
+			if board.IsMyTurn() && !bonus {
 				return
 			}
 			ans := game.NewAnswer(*message.Hit, *message.Blow)
-			board.CountTurn()
-			board.AddMyQA(game.NewQA(recentGuess, ans))
-			setScore(board, recentGuess.View(), ans.Hit(), ans.Blow())
+			keepTurn := bonus && !ans.IsAllHit()
+			if bonus && !keepTurn {
+				// DOUBLE の1回目で当たったのでこのターンを終える
+				board.ToggleTurn()
+				setTurn("It's Opponent's Turn, Waiting...")
+			}
+			if !keepTurn {
+				board.CountTurn()
+			}
+			qa := game.NewQA(recentGuess, ans)
+			board.AddMyQA(qa)
+			spectators.call(game.MyTurn, qa)
+			board.EndBonusCall()
+			setScore(game.MyTurn, board.GuessCount(game.MyTurn), recentGuess.View(), ans.Hit(), ans.Blow())
+			if keepTurn {
+				setTurn("DOUBLE! Call once more !")
+				go playMyTurn(dc, ch, finChan, board)
+				return
+			}
 			j := board.Judge()
 			setJudge(j)
 			if j != game.NotYet {
@@ -378,12 +1113,141 @@ func onMessage(dc *webrtc.DataChannel, ch chan *game.Guess, finChan chan struct{
 				return
 			}
 			return
+		case "item":
+			item, err := game.NewItemFromText(message.Item)
+			if err != nil {
+				logger.Warn("invalid item", "err", err)
+				return
+			}
+			// 不正な引数でアイテムを使い切らせないよう、記録する前に検証する
+			if err := game.ValidateItemArgs(item, message.Position, message.Digit, message.Info); err != nil {
+				logger.Warn("invalid item args", "item", item.Msg(), "err", err)
+				return
+			}
+			if err := board.UseItem(game.OpTurn, item); err != nil {
+				logger.Warn("invalid item use", "err", err)
+				return
+			}
+			var info string
+			switch item {
+			case game.HighLow, game.Target, game.Slash:
+				digit := 0
+				if message.Digit != nil {
+					digit = *message.Digit
+				}
+				result, err := board.AnswerItem(item, digit)
+				if err != nil {
+					logger.Error("failed to answer item", "err", err)
+					return
+				}
+				if err := sendMessage(dc, Message{Type: "item_result", Item: item.Msg(), Digit: &digit, Info: result}); err != nil {
+					logger.Error("failed to send itemResultMsg", "err", err)
+					return
+				}
+				info = itemResultView(item, digit, result)
+			case game.Change:
+				info = fmt.Sprintf("pos %d -> %s", *message.Position, message.Info)
+			case game.Double:
+				board.ArmDouble(game.OpTurn)
+				info = fmt.Sprintf("pos %d = %d", *message.Position, *message.Digit)
+			}
+			appendItemLog(board.AddItemUse(game.OpTurn, item, info))
+			return
+		case "item_result":
+			item, err := game.NewItemFromText(message.Item)
+			if err != nil || !board.IsItemUsed(game.MyTurn, item) {
+				return
+			}
+			if err := game.ValidateItemResult(item, message.Digit); err != nil {
+				logger.Warn("invalid item result", "item", item.Msg(), "err", err)
+				return
+			}
+			appendItemLog(board.AddItemUse(game.MyTurn, item, itemResultView(item, *message.Digit, message.Info)))
+			return
 		case "timeout":
-			setJudge(game.Win)
+			if !board.IsPlaying() {
+				return
+			}
+			board.Timeout(game.OpTurn)
+			setJudge(board.Judge())
+			finishProcess(dc, board, finChan)
+			return
+		case "flag":
+			// 相手側の時計で自分の持ち時間切れが判定された
+			if !board.IsPlaying() {
+				return
+			}
+			// 自分の時計でも切れていなければ受け入れず、対局を続ける
+			if !board.AcceptsFlag() {
+				logger.Warn("opponent claimed a timeout before the clock ran out", "remaining", board.Remaining(game.MyTurn))
+				logElem("[Sys]: Opponent claimed a timeout, but your clock is still running\n")
+				if err := sendMessage(dc, Message{Type: "flag_dispute"}); err != nil {
+					logger.Error("failed to send flag dispute", "err", err)
+				}
+				return
+			}
+			logElem("[Sys]: You Timeout! You Lose!\n")
+			board.Timeout(game.MyTurn)
+			setJudge(board.Judge())
+			finishProcess(dc, board, finChan)
+			return
+		case "flag_dispute":
+			// 相手の時計では持ち時間が残っていたので、結果は両者の署名が揃わず裁定者に委ねられる
+			logElem("[Sys]: Opponent disputes the timeout, it needs an arbiter\n")
+			return
+		case "resign":
+			if !board.IsPlaying() {
+				return
+			}
+			logElem("[Sys]: Opponent Resigned! You Win!\n")
+			board.Resign(game.OpTurn)
+			setJudge(board.Judge())
+			finishProcess(dc, board, finChan)
+			return
+		case "draw_offer":
+			if !board.IsPlaying() {
+				return
+			}
+			showOffer("draw", "Opponent offers a draw")
+			return
+		case "draw_accept":
+			if !board.IsPlaying() {
+				return
+			}
+			logElem("[Sys]: Draw Agreed!\n")
+			board.AgreeDraw()
+			setJudge(board.Judge())
 			finishProcess(dc, board, finChan)
 			return
+		case "draw_decline":
+			logElem("[Sys]: Draw Declined!\n")
+			getElementByID("draw").Set("disabled", false)
+			return
+		case "rematch_offer":
+			if !board.IsFinished() {
+				return
+			}
+			showOffer("rematch", "Opponent wants a rematch")
+			return
+		case "rematch_accept":
+			if !board.IsFinished() {
+				return
+			}
+			rematchProcess(dc, board)
+			return
+		case "commit":
+			onCommit(board, message)
+			return
+		case "result_sig":
+			onResultSignature(message)
+			return
+		case "chat":
+			chat.onMessage(chatMsg{Emote: message.Emote, Text: message.Text})
+			return
 		case "expose":
+			verifyExposedHand(board, message)
 			setHand(false, game.NewHandFromText(message.MyHand))
+			spectators.reveal(game.OpTurn, message.MyHand)
 			return
 		default:
 			return
@@ -391,55 +1255,69 @@ func onMessage(dc *webrtc.DataChannel, ch chan *game.Guess, finChan chan struct{
 		if board.IsOpTurn() {
 			return
 		}
+		// 相手からの投了や引き分け提案を受け取れるよう、自分の手番はハンドラの外で待つ
+		go playMyTurn(dc, ch, finChan, board)
+	}
+}
 
-		// 60sの間にguessを送信する処理
-		timeout := 60
-		gracePeriod := 1
-		toChan := make(chan struct{})
-		go func(to int, ch chan struct{}) {
-			for {
-				select {
-				case <-ch:
-					log.Printf("catch guess!!!!")
+func playMyTurn(dc transport.Transport, ch chan *game.Guess, finChan chan *finishedGame, board *game.Board) {
+	// 持ち時間の間にguessを送信する処理
+	toChan := make(chan struct{})
+	go func(ch chan struct{}) {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			setTimer(board.Remaining(game.MyTurn))
+			select {
+			case <-ch:
+				return
+			case <-ticker.C:
+				if board.IsFlagged(game.MyTurn) {
+					setTimer(0)
 					return
-				default:
-					to--
-					setTimer(to)
-					if to <= 0 {
-						return
-					}
-					time.Sleep(1 * time.Second)
 				}
 			}
-		}(timeout, toChan)
-		myGuess, isTO := board.WaitGuess(ch, toChan, time.Duration(timeout+gracePeriod)*time.Second)
-		recentGuess = myGuess
-		if isTO {
-			toMsg := Message{Type: "timeout"}
-			by, _ := json.Marshal(toMsg)
-			if err := dc.SendText(string(by)); err != nil {
-				log.Printf("failed to send toMsg: %v", err)
-				return
-			}
-			logElem("[Sys]: You Timeout! You Lose!\n")
-			setJudge(game.Lose)
-			finishProcess(dc, board, finChan)
+		}
+	}(toChan)
+	myGuess, isTO := board.WaitGuess(ch, toChan, board.Remaining(game.MyTurn))
+	if myGuess == nil && !isTO {
+		return
+	}
+	recentGuess = myGuess
+	if isTO {
+		if !board.IsPlaying() {
+			return
+		}
+		toMsg := Message{Type: "timeout"}
+		by, _ := json.Marshal(toMsg)
+		if err := dc.Send(by); err != nil {
+			logger.Error("failed to send toMsg", "err", err)
 			return
 		}
-		guessMsg := Message{Type: "guess", Guess: myGuess.Msg()}
-		by, _ := json.Marshal(guessMsg)
+		logElem("[Sys]: You Timeout! You Lose!\n")
+		board.Timeout(game.MyTurn)
+		setJudge(board.Judge())
+		finishProcess(dc, board, finChan)
+		return
+	}
+	guessMsg := Message{Type: "guess", Guess: myGuess.Msg()}
+	by, _ := json.Marshal(guessMsg)
+	if board.IsBonusCall() {
+		// DOUBLE の1回目は answer を受け取るまで自分のターンのまま
+		setTurn("DOUBLE! Waiting answer ...")
+	} else {
 		// 相手ターンへ遷移
 		board.ToggleTurn()
 		setTurn("It's Opponent's Turn, Waiting...")
-		if err := dc.SendText(string(by)); err != nil {
-			log.Printf("failed to send guessMsg: %v", err)
-			return
-		}
+	}
+	if err := dc.Send(by); err != nil {
+		logger.Error("failed to send guessMsg", "err", err)
+		return
 	}
 }
 
 func logElem(msg string) {
-	log.Printf(msg)
+	logger.Info(strings.TrimRight(msg, "\n"))
 	// el := getElementByID("logs")
 	// el.Set("innerHTML", el.Get("innerHTML").String()+msg)
 }
@@ -464,26 +1342,37 @@ func setJudge(judge game.JudgeStatus) {
 	}
 }
 
-func setScore(board *game.Board, guess string, hit int, blow int) {
+func setScore(guesser game.Turn, row int, guess string, hit int, blow int) {
 	var scores js.Value
 	doc := js.Global().Get("document").Call("getElementsByClassName", "board")
-	scores = doc.Index(0).Call("querySelector", "table").Get("tBodies").Index(0).Get("rows")
-	if board.IsMyTurn() {
-		scores = doc.Index(1).Call("querySelector", "table").Get("tBodies").Index(0).Get("rows")
-	}
-	turnCount := board.TurnCount()
-	log.Printf("board.TurnCount() %d", board.TurnCount())
-	guessCell := scores.Index(turnCount).Get("cells").Index(0)
-	hitCell := scores.Index(turnCount).Get("cells").Index(1)
-	blowCell := scores.Index(turnCount).Get("cells").Index(2)
+	tBody := doc.Index(0).Call("querySelector", "table").Get("tBodies").Index(0)
+	if guesser == game.OpTurn {
+		tBody = doc.Index(1).Call("querySelector", "table").Get("tBodies").Index(0)
+	}
+	scores = tBody.Get("rows")
+	// DOUBLE で call 数が規定ターン数を超えた場合は行を足す
+	for scores.Length() <= row {
+		newRow := tBody.Call("insertRow")
+		for i := 0; i < 3; i++ {
+			newRow.Call("insertCell").Set("innerHTML", "&nbsp;")
+		}
+	}
+	guessCell := scores.Index(row).Get("cells").Index(0)
+	hitCell := scores.Index(row).Get("cells").Index(1)
+	blowCell := scores.Index(row).Get("cells").Index(2)
 	guessCell.Set("innerHTML", guess)
 	hitCell.Set("innerHTML", hit)
 	blowCell.Set("innerHTML", blow)
 }
 
-func setTimer(second int) {
+func setTimer(remaining time.Duration) {
 	timer := js.Global().Get("document").Call("getElementById", "timer")
-	timer.Set("innerHTML", second)
+	second := int(math.Ceil(remaining.Seconds()))
+	if second < 60 {
+		timer.Set("innerHTML", second)
+		return
+	}
+	timer.Set("innerHTML", fmt.Sprintf("%d:%02d", second/60, second%60))
 }
 
 func setTurn(message string) {
@@ -502,65 +1391,327 @@ func setHand(isMyHand bool, hand *game.Hand) {
 	}
 }
 
-func setProfile(myID, opID string, myRate, opRate int) {
+func itemResultView(item game.Item, digit int, result string) string {
+	switch item {
+	case game.Target:
+		if result == "0" {
+			return fmt.Sprintf("%d is not in hand", digit)
+		}
+		return fmt.Sprintf("%d is at pos %s", digit, result)
+	case game.Slash:
+		return fmt.Sprintf("max - min = %s", result)
+	}
+	return result
+}
+
+func appendItemLog(use *game.ItemUse) {
+	spectators.item(use.Turn(), use.Item())
+	logElem(fmt.Sprintf("[Item]: %s\n", use.View()))
+	el := getElementByID("item-log")
+	item := js.Global().Get("document").Call("createElement", "li")
+	item.Set("textContent", use.View())
+	el.Call("appendChild", item)
+}
+
+// setItems はアイテムありルールのときだけアイテムボタンを表示します
+func setItems(board *game.Board) {
+	display := "none"
+	if board.Variant() == game.Numer0n {
+		display = "block"
+	}
+	getElementByID("items").Get("style").Set("display", display)
+	getElementByID("item-log").Set("innerHTML", "")
+	for _, item := range []game.Item{game.HighLow, game.Target, game.Slash, game.Shuffle, game.Change, game.Double} {
+		getElementByID("item-"+item.Msg()).Set("disabled", false)
+	}
+}
+
+func promptInt(message string) (int, error) {
+	value := js.Global().Call("prompt", message)
+	if value.IsNull() {
+		return -1, fmt.Errorf("canceled")
+	}
+	return strconv.Atoi(value.String())
+}
+
+func showOffer(offer, message string) {
+	pendingOffer = offer
+	getElementByID("offer-message").Set("innerHTML", message)
+	getElementByID("offer").Get("style").Set("display", "block")
+}
+
+func hideOffer() {
+	pendingOffer = ""
+	getElementByID("offer").Get("style").Set("display", "none")
+}
+
+func resetView() {
+	doc := js.Global().Get("document")
+	judge := doc.Call("querySelector", ".title").Get("firstElementChild")
+	judge.Set("id", "my-judge")
+	judge.Set("innerHTML", "")
+	boards := doc.Call("getElementsByClassName", "board")
+	for i := 0; i < boards.Length(); i++ {
+		rows := boards.Index(i).Call("querySelector", "table").Get("tBodies").Index(0).Get("rows")
+		for j := 1; j < rows.Length(); j++ {
+			cells := rows.Index(j).Get("cells")
+			for k := 0; k < cells.Length(); k++ {
+				cells.Index(k).Set("innerHTML", "&nbsp;")
+			}
+		}
+	}
+	for _, handID := range []string{"my-hand", "op-hand"} {
+		for i := 1; i <= 3; i++ {
+			getElementByID(fmt.Sprintf("%s-%d", handID, i)).Set("innerHTML", "?")
+		}
+	}
+	getElementByID("draw").Set("disabled", false)
+	getElementByID("rematch").Set("disabled", true)
+	setTurn("Turn Display")
+}
+
+func setMatchScore(match *game.Match) {
+	el := getElementByID("match-score")
+	if match.BestOf() == 1 {
+		el.Set("innerHTML", "")
+		return
+	}
+	my, op := match.Score()
+	text := fmt.Sprintf("Bo%d: %g - %g", match.BestOf(), my, op)
+	switch match.Judge() {
+	case game.Win:
+		text += " (Match WIN)"
+	case game.Lose:
+		text += " (Match LOSE)"
+	case game.Draw:
+		text += " (Match DRAW)"
+	}
+	el.Set("innerHTML", text)
+}
+
+func setProfile(myID, opID string, myRating, opRating *rating.Rating) {
 	myProfile := js.Global().Get("document").Call("getElementById", "my-profile")
 	opProfile := js.Global().Get("document").Call("getElementById", "op-profile")
-	myProfile.Set("innerHTML", fmt.Sprintf("%s(r%d)", myID, myRate))
-	opProfile.Set("innerHTML", fmt.Sprintf("%s(r%d)", opID, opRate))
+	profileRating = myRating.Rate()
+	searchRating = myRating
+	myProfile.Set("innerHTML", fmt.Sprintf("%s(r%d)", myID, myRating.Rate()))
+	opProfile.Set("innerHTML", fmt.Sprintf("%s(r%d)", opID, opRating.Rate()))
+	// 対局前に Glicko-2 での勝率の見込みを表示する
+	if myRating.Rate() > 0 && opRating.Rate() > 0 {
+		p := rating.WinProbability(myRating, opRating)
+		logElem(fmt.Sprintf("[Sys]: Win probability %.0f%% (r%d vs r%d)\n", p*100, myRating.Rate(), opRating.Rate()))
+	}
 }
 
-func finishProcess(dc *webrtc.DataChannel, board *game.Board, finChan chan struct{}) {
+func finishProcess(dc transport.Transport, board *game.Board, finChan chan *finishedGame) {
+	if !board.IsPlaying() {
+		return
+	}
+	board.Finish()
+	spectators.finish(board)
 	setTurn("Finish !!!")
-	exposeMsg := Message{Type: "expose", MyHand: board.MyHandText()}
+	hideOffer()
+	getElementByID("claim").Set("disabled", true)
+	getElementByID("draw").Set("disabled", true)
+	match.Record(board)
+	setMatchScore(match)
+	// 次の局が始まる前に、報告する値を取り出しておく
+	finished := newFinishedGame(board, match)
+	if match.IsDecided() {
+		getElementByID("rematch").Set("disabled", false)
+	} else if board.PNum() == 1 {
+		// マッチの次局は開室者が先後を入れ替えて開始する
+		go func() {
+			time.Sleep(3 * time.Second)
+			rematchProcess(dc, board)
+		}()
+	}
+	exposeMsg := Message{Type: "expose", MyHand: board.MyHandText(), Nonce: board.Nonce()}
 	by, _ := json.Marshal(exposeMsg)
-	if err := dc.SendText(string(by)); err != nil {
-		log.Printf("failed to send exposeMsg: %v", err)
+	// 相手が切断済みでも結果は報告する
+	if err := dc.Send(by); err != nil {
+		logger.Error("failed to send exposeMsg", "err", err)
+	}
+	finChan <- finished
+}
+
+// finishedGame は終局時点の局とマッチの結果です
+// 報告には相手の署名を待つなど時間がかかり、その間に開室者が次の局を始めると board が初期化されるので値で持ちます
+type finishedGame struct {
+	round    int
+	pNum     int
+	judge    game.JudgeStatus
+	first    bool
+	solved   bool
+	guesses  int
+	timeUsed time.Duration
+	// commitments は MyTurn、OpTurn の順の手札のコミットメントです
+	commitments [2]string
+	decided     bool
+	result      string
+	digest      string
+}
+
+func newFinishedGame(board *game.Board, match *game.Match) *finishedGame {
+	calls := board.History().Calls(game.MyTurn)
+	return &finishedGame{
+		round:       board.Round(),
+		pNum:        board.PNum(),
+		judge:       board.Judge(),
+		first:       board.IsMyTurnInit(),
+		solved:      len(calls) > 0 && calls[len(calls)-1].Answer().IsAllHit(),
+		guesses:     board.GuessCount(game.MyTurn),
+		timeUsed:    board.Used(game.MyTurn),
+		commitments: [2]string{board.Commitment(game.MyTurn), board.Commitment(game.OpTurn)},
+		decided:     match.IsDecided(),
+		result:      match.Result(board.PNum()),
+		digest:      match.Digest(),
+	}
+}
+
+// startProcess は開室者として手札を決めて start を送信し、対局を開始します
+func startProcess(dc transport.Transport, board *game.Board, initTurn game.Turn, tc *game.TimeControl) {
+	rand.NewSource(time.Now().UnixNano())
+	myHand := game.NewHandBySeed(rand.Int())
+	setHand(true, myHand)
+	board.Start(myHand, initTurn, 1, tc)
+	setItems(board)
+	boardLogger(board).Info("game started", "opener", true, "first", board.IsMyTurnInit())
+	if board.IsMyTurnInit() {
+		setTurn("It's Your Turn !")
+	}
+	turn, bestOf := int(initTurn), match.BestOf()
+	startMsg := Message{Type: "start", Turn: &turn, TimeControl: tc.Msg(), BestOf: &bestOf, Variant: board.Variant().Msg(), Rules: board.Rules().Msg(), Commitment: board.Commitment(game.MyTurn)}
+	by, _ := json.Marshal(startMsg)
+	time.Sleep(1 * time.Second)
+	if err := dc.Send(by); err != nil {
+		logger.Error("failed to send startMsg", "err", err)
 		return
 	}
-	finChan <- struct{}{}
-	board.Finish()
+	board.StartClock()
+	go watchOpClock(board)
+	spectators.start(board)
+}
+
+// rematchProcess は同じ PeerConnection のまま先後を入れ替えて次の対局を始めます
+func rematchProcess(dc transport.Transport, board *game.Board) {
+	tc := board.TimeControl()
+	initTurn := board.Rematch()
+	if match.IsDecided() {
+		match = game.NewMatch(match.BestOf())
+	}
+	resetView()
+	setMatchScore(match)
+	logElem(fmt.Sprintf("[Sys]: Rematch! Round %d\n", board.Round()))
+	// start の送信は開室者が担う
+	if board.PNum() == 1 {
+		go startProcess(dc, board, initTurn, tc)
+	}
 }
 
-func getRating(ratingURL url.URL, myID, opID string) (int, int, error) {
+func sendMessage(dc transport.Transport, message Message) error {
+	by, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return dc.Send(by)
+}
+
+// matchID は再戦ごとにレーティング報告用の対局IDを振り分けます
+func matchID(roomID string, round int) string {
+	if round == 1 {
+		return roomID
+	}
+	return fmt.Sprintf("%s-%d", roomID, round)
+}
+
+// abandonProcess は対局中に相手との接続が切れた場合に放棄勝ちとして終局させます
+func abandonProcess(dc transport.Transport, board *game.Board, finChan chan *finishedGame) {
+	if !board.IsPlaying() {
+		return
+	}
+	logElem("[Sys]: Opponent Disconnected! You Win!\n")
+	board.Abandon(game.OpTurn)
+	setJudge(board.Judge())
+	finishProcess(dc, board, finChan)
+}
+
+func onClose(dc transport.Transport, finChan chan *finishedGame, board *game.Board) func() {
+	return func() {
+		boardLogger(board).Warn("DataChannel closed", "label", dc.Label())
+		chat.detach(dc)
+		abandonProcess(dc, board, finChan)
+	}
+}
+
+// watchOpClock は相手の手番中に相手の持ち時間を表示し、時間切れを主張できるようになったら CLAIM ボタンを有効にします
+func watchOpClock(board *game.Board) {
+	done := board.Done()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !board.IsOpTurn() {
+				continue
+			}
+			setTimer(board.Remaining(game.OpTurn))
+			getElementByID("claim").Set("disabled", !board.CanClaimTimeout())
+		}
+	}
+}
+
+// getRating は両者のレーティングを返します
+// 偏差を返さないレーティングサーバでは rating.FromRate と同じく偏差の落ち着いたプレイヤーとして扱います
+func getRating(ratingURL url.URL, myID, opID string) (*rating.Rating, *rating.Rating, error) {
 	ratingURL.Path = path.Join(ratingURL.Path, "/start")
 	q := ratingURL.Query()
 	q.Set("p1", myID)
 	q.Set("p2", opID)
 	ratingURL.RawQuery = q.Encode()
-	res, err := http.Get(ratingURL.String())
+	res, err := getAuthorized(ratingURL.String(), identity.AudienceRating)
 	if err != nil {
-		return -1, -1, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return -1, -1, fmt.Errorf("failed to get rating: %v", res.Status)
+		return nil, nil, fmt.Errorf("failed to get rating: %v", res.Status)
 	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return -1, -1, err
+		return nil, nil, err
 	}
 	var resMsg getRatingResMsg
 	if err := json.Unmarshal(body, &resMsg); err != nil {
-		return -1, -1, err
+		return nil, nil, err
+	}
+	return ratingOf(resMsg.Player1.Rate, resMsg.Player1.Deviation), ratingOf(resMsg.Player2.Rate, resMsg.Player2.Deviation), nil
+}
+
+func ratingOf(rate int, deviation float64) *rating.Rating {
+	if deviation <= 0 {
+		return rating.FromRate(rate)
 	}
-	return resMsg.Player1.Rate, resMsg.Player2.Rate, nil
+	return rating.NewRating(float64(rate), deviation, rating.DefaultVolatility)
 }
 
-func updateRating(ratingURL url.URL, roomID, myID, hash string, pNum int, result string) error {
+func updateRating(ratingURL url.URL, roomID, myID, hash string, pNum int, result string, signed *identity.SignedResult) error {
 	resMsg := updateRatingResMsg{
 		MatchID:  roomID,
 		PlayerID: myID,
 		Number:   pNum,
 		Hash:     hash,
 		Result:   result,
+		Signed:   signed,
 	}
 	ratingURL.Path = path.Join(ratingURL.Path, "/finish")
 	body, err := json.Marshal(resMsg)
 	if err != nil {
 		return err
 	}
-	buf := bytes.NewBuffer(body)
-	res, err := http.Post(ratingURL.String(), "application/json", buf)
+	res, err := postAuthorized(ratingURL.String(), identity.AudienceRating, body)
 	if err != nil {
 		return err
 	}
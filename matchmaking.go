@@ -0,0 +1,155 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/rating"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+var (
+	// queue は待機中のマッチングです
+	queue *matchmaker
+	// searchRating は直近の対局開始時に得た自分のレーティングで、join に載せます
+	searchRating *rating.Rating
+)
+
+// matchmaker はマッチングサーバとの WebSocket を保持し、待機のキャンセルや対局の承諾を送ります
+//
+// クライアントからは join, cancel, accept, decline を送り、サーバからは次を受け取ります
+// join には rate と deviation を載せ、サーバは rating.ExpectedScore が 0.5 に近い相手から順に、
+// 待ち時間に応じて範囲を広げながら組み合わせます
+//   - QUEUE: 待ち順と推定待ち時間
+//   - PROPOSE: 対戦相手の候補とそのレーティング。accept_within 秒以内に両者が accept すると MATCH になる
+//   - MATCH: 対局するルーム
+//   - REQUEUE: 相手が承諾しなかったので待機に戻った
+//   - CANCELLED: cancel を受け付けた
+type matchmaker struct {
+	ws         *websocket.Conn
+	userID     string
+	proposalID string
+	mu         sync.Mutex
+}
+
+// region はタイムゾーンから近いリージョンを推定します
+func region() string {
+	if r := getElementByID("region").Get("value").String(); r != "auto" {
+		return r
+	}
+	tz := js.Global().Get("Intl").Call("DateTimeFormat").Call("resolvedOptions").Get("timeZone").String()
+	switch {
+	case strings.HasPrefix(tz, "Asia/"), strings.HasPrefix(tz, "Australia/"):
+		return "asia"
+	case strings.HasPrefix(tz, "America/"):
+		return "na"
+	case strings.HasPrefix(tz, "Europe/"), strings.HasPrefix(tz, "Africa/"):
+		return "eu"
+	}
+	return "any"
+}
+
+// search は reqMsg の条件で待機し、MATCH を受け取るまで待ちます
+// キャンセルされた場合は nil を返します
+func search(mmURL url.URL, reqMsg mmReqMsg) (*mmResMsg, error) {
+	ws, _, err := websocket.Dial(context.Background(), mmURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Close(websocket.StatusNormalClosure, "close connection")
+	queue = &matchmaker{ws: ws, userID: reqMsg.UserID}
+	defer func() {
+		queue = nil
+	}()
+
+	reqMsg.Type = "join"
+	if err := wsjson.Write(context.Background(), ws, reqMsg); err != nil {
+		return nil, err
+	}
+	logElem("[Sys]: Waiting match...\n")
+	for {
+		var resMsg mmResMsg
+		if err := wsjson.Read(context.Background(), ws, &resMsg); err != nil {
+			return nil, err
+		}
+		switch resMsg.Type {
+		case "QUEUE":
+			setQueueStatus(fmt.Sprintf("#%d in queue, about %ds", resMsg.Position, resMsg.EstimatedWait))
+		case "PROPOSE":
+			queue.propose(resMsg)
+		case "REQUEUE":
+			hideOffer()
+			logElem("[Sys]: Match was not accepted, back to the queue\n")
+		case "CANCELLED":
+			logElem("[Sys]: Search cancelled\n")
+			return nil, nil
+		case "MATCH":
+			setQueueStatus("")
+			return &resMsg, nil
+		}
+	}
+}
+
+// propose は対戦相手の候補を表示し、期限までに応答しなければ辞退します
+func (m *matchmaker) propose(resMsg mmResMsg) {
+	m.mu.Lock()
+	m.proposalID = resMsg.ProposalID
+	m.mu.Unlock()
+	within := time.Duration(resMsg.AcceptWithin) * time.Second
+	offer := fmt.Sprintf("Match found: %s. Accept within %ds?", resMsg.UserID, resMsg.AcceptWithin)
+	if searchRating != nil && resMsg.Rate > 0 {
+		p := rating.WinProbability(searchRating, ratingOf(resMsg.Rate, resMsg.Deviation))
+		offer = fmt.Sprintf("Match found: %s(r%d, win %.0f%%). Accept within %ds?", resMsg.UserID, resMsg.Rate, p*100, resMsg.AcceptWithin)
+	}
+	showOffer("match", offer)
+	go func() {
+		time.Sleep(within)
+		m.mu.Lock()
+		expired := m.proposalID == resMsg.ProposalID
+		m.mu.Unlock()
+		if expired {
+			hideOffer()
+			m.respond(false)
+		}
+	}()
+}
+
+// respond は対戦相手の候補を承諾または辞退します
+func (m *matchmaker) respond(accept bool) {
+	m.mu.Lock()
+	proposalID := m.proposalID
+	m.proposalID = ""
+	m.mu.Unlock()
+	if proposalID == "" {
+		return
+	}
+	reqType := "decline"
+	if accept {
+		reqType = "accept"
+		setQueueStatus("Waiting for the opponent to accept...")
+	}
+	m.send(mmReqMsg{Type: reqType, UserID: m.userID, ProposalID: proposalID})
+}
+
+func (m *matchmaker) cancel() {
+	m.send(mmReqMsg{Type: "cancel", UserID: m.userID})
+}
+
+func (m *matchmaker) send(reqMsg mmReqMsg) {
+	if err := wsjson.Write(context.Background(), m.ws, reqMsg); err != nil {
+		logger.Error("failed to send matchmaking request", "type", reqMsg.Type, "err", err)
+	}
+}
+
+func setQueueStatus(status string) {
+	getElementByID("queue-status").Set("innerHTML", status)
+}
@@ -0,0 +1,67 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+)
+
+// 読み間違えやすい 0/O, 1/I を除いた英数字
+const (
+	privateCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	privateCodeLength   = 6
+)
+
+// unrated はレーティングに反映しない対局かを表します
+// プライベートルームでどちらかが unrated を選んだときに true になります
+var unrated bool
+
+// newPrivateCode は口頭やチャットで伝えやすい短い招待コードを作ります
+func newPrivateCode() string {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(privateCodeAlphabet)))
+	for i := 0; i < privateCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			logger.Error("failed to generate private code", "err", err)
+			continue
+		}
+		sb.WriteByte(privateCodeAlphabet[n.Int64()])
+	}
+	return sb.String()
+}
+
+func privateRoomID(code string) string {
+	return "private-" + strings.ToUpper(strings.TrimSpace(code))
+}
+
+// sendHello はマッチングを経ずに入室した相手へ自分の userID とレーティング対象かを伝えます
+func sendHello(dc transport.Transport, myID string) {
+	rated := !unrated
+	dc.OnOpen(func() {
+		if err := sendMessage(dc, Message{Type: "hello", From: myID, Rated: &rated}); err != nil {
+			logger.Error("failed to send helloMsg", "err", err)
+		}
+	})
+}
+
+// withHello は hello を onHello で処理し、それ以外のメッセージを next に渡します
+func withHello(next func([]byte), onHello func(Message)) func([]byte) {
+	return func(data []byte) {
+		var message Message
+		if err := json.Unmarshal(data, &message); err == nil && message.Type == "hello" {
+			if message.Rated != nil && !*message.Rated {
+				unrated = true
+			}
+			onHello(message)
+			return
+		}
+		next(data)
+	}
+}
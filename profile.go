@@ -0,0 +1,148 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/profile"
+)
+
+const (
+	ratingGraphWidth  = 240
+	ratingGraphHeight = 60
+)
+
+var (
+	profileOrigin string
+	// profileRating は対局開始時に /rating/start で得た自分のレーティングです
+	profileRating int
+)
+
+type profileRecordReqMsg struct {
+	profile.Record
+	Hash string `json:"hash"`
+}
+
+// reportGameRecord は終局した board の自分から見た記録を報告します
+// マッチの途中の局も1局ずつ報告します
+func reportGameRecord(profileURL url.URL, matchID, myID, opID, hash string, g *finishedGame) {
+	if profileOrigin == "" {
+		return
+	}
+	outcome := map[game.JudgeStatus]profile.Outcome{game.Win: profile.Win, game.Draw: profile.Draw, game.Lose: profile.Loss}[g.judge]
+	body, err := json.Marshal(profileRecordReqMsg{
+		Record: profile.Record{
+			MatchID:    matchID,
+			PlayerID:   myID,
+			OpponentID: opID,
+			First:      g.first,
+			Outcome:    outcome,
+			Solved:     g.solved,
+			Guesses:    g.guesses,
+			TimeUsed:   g.timeUsed.Milliseconds(),
+			Rating:     max(profileRating, 0),
+		},
+		Hash: hash,
+	})
+	if err != nil {
+		logger.Error("failed to marshal game record", "err", err)
+		return
+	}
+	profileURL.Path = path.Join(profileURL.Path, "/record")
+	res, err := postAuthorized(profileURL.String(), identity.AudienceProfile, body)
+	if err != nil {
+		logger.Error("failed to report game record", "err", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logger.Error("failed to report game record", "status", res.Status)
+	}
+}
+
+func getProfile(profileURL url.URL, id string) (*profile.Profile, error) {
+	profileURL.Path = path.Join(profileURL.Path, url.PathEscape(id))
+	res, err := getAuthorized(profileURL.String(), identity.AudienceProfile)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get profile: %v", res.Status)
+	}
+	var p profile.Profile
+	if err := json.NewDecoder(res.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// showProfile は id の成績を表示します
+func showProfile(profileURL url.URL, id string) {
+	p, err := getProfile(profileURL, id)
+	if err != nil {
+		logger.Error("failed to show profile", "err", err)
+		getElementByID("profile-stats").Set("innerHTML", "Profile not available")
+		return
+	}
+	tally := func(t profile.Tally) string {
+		return fmt.Sprintf("%dW %dD %dL (%.0f%%)", t.Wins, t.Draws, t.Losses, t.WinRate*100)
+	}
+	lines := []string{
+		fmt.Sprintf("<b>%s</b>", html.EscapeString(p.PlayerID)),
+		"Record: " + tally(p.Overall),
+		"As first: " + tally(p.AsFirst),
+		"As second: " + tally(p.AsSecond),
+	}
+	if p.Solved > 0 {
+		lines = append(lines,
+			fmt.Sprintf("Average guesses: %.1f", p.AverageGuesses),
+			fmt.Sprintf("Fewest guesses: %d", p.FewestGuesses),
+			fmt.Sprintf("Fastest solve: %s", (time.Duration(p.FastestSolve)*time.Millisecond).Round(100*time.Millisecond)),
+		)
+	}
+	if graph := ratingGraph(p.RatingHistory); graph != "" {
+		lines = append(lines, graph)
+	}
+	if len(p.RecentOpponents) > 0 {
+		opponents := make([]string, 0, len(p.RecentOpponents))
+		for _, o := range p.RecentOpponents {
+			opponents = append(opponents, fmt.Sprintf("%s(%s)", html.EscapeString(o.PlayerID), o.Outcome))
+		}
+		lines = append(lines, "Recent: "+strings.Join(opponents, ", "))
+	}
+	getElementByID("profile-stats").Set("innerHTML", strings.Join(lines, "<br>"))
+}
+
+// ratingGraph はレーティングの推移を折れ線の SVG にします
+func ratingGraph(points []*profile.RatingPoint) string {
+	if len(points) < 2 {
+		return ""
+	}
+	ratings := make([]int, len(points))
+	for i, p := range points {
+		ratings[i] = p.Rating
+	}
+	lo, hi := slices.Min(ratings), slices.Max(ratings)
+	span := max(hi-lo, 1)
+	coords := make([]string, len(ratings))
+	for i, r := range ratings {
+		x := float64(i) * ratingGraphWidth / float64(len(ratings)-1)
+		y := float64(hi-r) * ratingGraphHeight / float64(span)
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return fmt.Sprintf(`<svg width="%d" height="%d"><polyline fill="none" stroke="black" points="%s"/></svg> r%d-%d`,
+		ratingGraphWidth, ratingGraphHeight, strings.Join(coords, " "), lo, hi)
+}
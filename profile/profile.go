@@ -0,0 +1,117 @@
+package profile
+
+import (
+	"slices"
+	"time"
+)
+
+// maxRecentOpponents は直近の対戦相手として返す人数です
+const maxRecentOpponents = 10
+
+// Tally は勝ち、引き分け、負けの数です
+type Tally struct {
+	Games   int     `json:"games"`
+	Wins    int     `json:"wins"`
+	Draws   int     `json:"draws"`
+	Losses  int     `json:"losses"`
+	WinRate float64 `json:"win_rate"`
+}
+
+func (t *Tally) add(o Outcome) {
+	t.Games++
+	switch o {
+	case Win:
+		t.Wins++
+	case Draw:
+		t.Draws++
+	case Loss:
+		t.Losses++
+	}
+	// 引き分けは半分の勝ちとして数える
+	t.WinRate = (float64(t.Wins) + float64(t.Draws)/2) / float64(t.Games)
+}
+
+// RatingPoint はレーティングの推移のグラフの1点です
+type RatingPoint struct {
+	MatchID  string    `json:"match_id"`
+	PlayedAt time.Time `json:"played_at"`
+	Rating   int       `json:"rating"`
+}
+
+type RecentOpponent struct {
+	PlayerID string    `json:"player_id"`
+	Outcome  Outcome   `json:"outcome"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+// Profile は対局記録から集計したプレイヤーの成績です
+type Profile struct {
+	PlayerID string `json:"player_id"`
+	Overall  Tally  `json:"overall"`
+	AsFirst  Tally  `json:"as_first"`
+	AsSecond Tally  `json:"as_second"`
+	// 相手の手札を当てた対局についての集計で、当てた対局がなければ 0 です
+	Solved         int     `json:"solved"`
+	AverageGuesses float64 `json:"average_guesses"`
+	FewestGuesses  int     `json:"fewest_guesses"`
+	// FastestSolve は当てるまでに使った時間の最短(ミリ秒)です
+	FastestSolve    int64             `json:"fastest_solve"`
+	RatingHistory   []*RatingPoint    `json:"rating_history"`
+	RecentOpponents []*RecentOpponent `json:"recent_opponents"`
+}
+
+// NewProfile は古い順に並んだ records から playerID の成績を集計します
+func NewProfile(playerID string, records []*Record) *Profile {
+	p := &Profile{
+		PlayerID:        playerID,
+		RatingHistory:   make([]*RatingPoint, 0),
+		RecentOpponents: make([]*RecentOpponent, 0),
+	}
+	var guesses int
+	for _, r := range records {
+		p.Overall.add(r.Outcome)
+		if r.First {
+			p.AsFirst.add(r.Outcome)
+		} else {
+			p.AsSecond.add(r.Outcome)
+		}
+		if r.Rating > 0 {
+			p.RatingHistory = append(p.RatingHistory, &RatingPoint{r.MatchID, r.PlayedAt, r.Rating})
+		}
+		if !r.Solved {
+			continue
+		}
+		p.Solved++
+		guesses += r.Guesses
+		if p.FewestGuesses == 0 || r.Guesses < p.FewestGuesses {
+			p.FewestGuesses = r.Guesses
+		}
+		if p.FastestSolve == 0 || r.TimeUsed < p.FastestSolve {
+			p.FastestSolve = r.TimeUsed
+		}
+	}
+	if p.Solved > 0 {
+		p.AverageGuesses = float64(guesses) / float64(p.Solved)
+	}
+	// 新しい順に、同じ相手は最後の対局だけを並べる
+	seen := make(map[string]bool)
+	for i := len(records) - 1; i >= 0 && len(p.RecentOpponents) < maxRecentOpponents; i-- {
+		r := records[i]
+		if seen[r.OpponentID] {
+			continue
+		}
+		seen[r.OpponentID] = true
+		p.RecentOpponents = append(p.RecentOpponents, &RecentOpponent{r.OpponentID, r.Outcome, r.PlayedAt})
+	}
+	return p
+}
+
+// History は新しい順に最大 limit 件の記録を返します
+func History(records []*Record, limit int) []*Record {
+	history := slices.Clone(records)
+	slices.Reverse(history)
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+	return history
+}
@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewProfile(t *testing.T) {
+	at := func(min int) time.Time {
+		return time.Unix(int64(min)*60, 0)
+	}
+	records := []*Record{
+		{MatchID: "m1", PlayerID: "alice", OpponentID: "bob", First: true, Outcome: Win, Solved: true, Guesses: 6, TimeUsed: 40000, Rating: 1500, PlayedAt: at(1)},
+		{MatchID: "m2", PlayerID: "alice", OpponentID: "carol", First: false, Outcome: Loss, Guesses: 5, TimeUsed: 30000, PlayedAt: at(2)},
+		{MatchID: "m3", PlayerID: "alice", OpponentID: "bob", First: false, Outcome: Draw, Solved: true, Guesses: 4, TimeUsed: 50000, Rating: 1520, PlayedAt: at(3)},
+		{MatchID: "m4", PlayerID: "alice", OpponentID: "dave", First: true, Outcome: Win, Solved: true, Guesses: 8, TimeUsed: 20000, Rating: 1510, PlayedAt: at(4)},
+	}
+	p := NewProfile("alice", records)
+
+	tallies := []struct {
+		name string
+		got  Tally
+		want Tally
+	}{
+		{name: "overall", got: p.Overall, want: Tally{Games: 4, Wins: 2, Draws: 1, Losses: 1, WinRate: 0.625}},
+		{name: "as first", got: p.AsFirst, want: Tally{Games: 2, Wins: 2, WinRate: 1}},
+		{name: "as second", got: p.AsSecond, want: Tally{Games: 2, Draws: 1, Losses: 1, WinRate: 0.25}},
+	}
+	for _, tt := range tallies {
+		if tt.got != tt.want {
+			t.Errorf("%s = %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+	// 当てられなかった m2 は guess 数と時間の集計に入れない
+	if p.Solved != 3 || p.AverageGuesses != 6 || p.FewestGuesses != 4 || p.FastestSolve != 20000 {
+		t.Errorf("solved %d, average %v, fewest %d, fastest %d, want 3, 6, 4, 20000", p.Solved, p.AverageGuesses, p.FewestGuesses, p.FastestSolve)
+	}
+	ratings := make([]int, len(p.RatingHistory))
+	for i, point := range p.RatingHistory {
+		ratings[i] = point.Rating
+	}
+	if want := []int{1500, 1520, 1510}; !slices.Equal(ratings, want) {
+		t.Errorf("RatingHistory = %v, want %v", ratings, want)
+	}
+	opponents := make([]string, len(p.RecentOpponents))
+	for i, op := range p.RecentOpponents {
+		opponents[i] = op.PlayerID
+	}
+	if want := []string{"dave", "bob", "carol"}; !slices.Equal(opponents, want) {
+		t.Errorf("RecentOpponents = %v, want %v", opponents, want)
+	}
+	if p.RecentOpponents[1].Outcome != Draw {
+		t.Errorf("RecentOpponents[bob] = %s, want the last game %s", p.RecentOpponents[1].Outcome, Draw)
+	}
+}
+
+func TestNewProfileWithoutRecords(t *testing.T) {
+	p := NewProfile("alice", nil)
+	if p.Overall.Games != 0 || p.Overall.WinRate != 0 || p.AverageGuesses != 0 {
+		t.Errorf("NewProfile(nil) = %+v, want an empty profile", p)
+	}
+	if p.RatingHistory == nil || p.RecentOpponents == nil {
+		t.Error("NewProfile(nil) returned nil slices, they encode as null")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	records := []*Record{{MatchID: "m1"}, {MatchID: "m2"}, {MatchID: "m3"}}
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{limit: 2, want: []string{"m3", "m2"}},
+		{limit: 3, want: []string{"m3", "m2", "m1"}},
+		{limit: 10, want: []string{"m3", "m2", "m1"}},
+		{limit: 0, want: []string{"m3", "m2", "m1"}},
+	}
+	for _, tt := range tests {
+		got := make([]string, 0)
+		for _, r := range History(records, tt.limit) {
+			got = append(got, r.MatchID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("History(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+	// 元の並びは変えない
+	if records[0].MatchID != "m1" {
+		t.Error("History() reordered the records")
+	}
+}
@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"fmt"
+	"time"
+)
+
+type Outcome string
+
+const (
+	Win  Outcome = "win"
+	Draw Outcome = "draw"
+	Loss Outcome = "loss"
+)
+
+func NewOutcomeFromText(text string) (Outcome, error) {
+	switch o := Outcome(text); o {
+	case Win, Draw, Loss:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown outcome: %q", text)
+}
+
+// Record は1局ごとに各プレイヤーが報告する対局記録です
+// 同じ対局でも両者がそれぞれ自分から見た記録を報告し、食い違わなければ成績に数えます
+type Record struct {
+	MatchID    string  `json:"match_id"`
+	PlayerID   string  `json:"player_id"`
+	OpponentID string  `json:"opponent_id"`
+	First      bool    `json:"first"`
+	Outcome    Outcome `json:"outcome"`
+	// Solved は相手の手札を当てたかで、Guesses はそれまでの call 数です
+	Solved  bool `json:"solved"`
+	Guesses int  `json:"guesses"`
+	// TimeUsed は自分の持ち時間から使った時間(ミリ秒)です
+	TimeUsed int64 `json:"time_used"`
+	// Rating は対局開始時のレーティングで、分からない場合は 0 です
+	Rating   int       `json:"rating"`
+	PlayedAt time.Time `json:"played_at"`
+}
+
+func (r *Record) Validate() error {
+	if r.MatchID == "" || r.PlayerID == "" || r.OpponentID == "" {
+		return fmt.Errorf("match_id, player_id and opponent_id are required")
+	}
+	if r.PlayerID == r.OpponentID {
+		return fmt.Errorf("player and opponent are the same: %s", r.PlayerID)
+	}
+	if _, err := NewOutcomeFromText(string(r.Outcome)); err != nil {
+		return err
+	}
+	if r.Guesses < 0 || r.TimeUsed < 0 {
+		return fmt.Errorf("guesses and time_used must not be negative")
+	}
+	return nil
+}
+
+func (r *Record) key() string {
+	return r.MatchID + "/" + r.PlayerID
+}
+
+func (r *Record) opponentKey() string {
+	return r.MatchID + "/" + r.OpponentID
+}
+
+// mirrors は op が同じ対局の相手から見た記録として r と食い違わないかを返します
+func (r *Record) mirrors(op *Record) bool {
+	outcome := map[Outcome]Outcome{Win: Loss, Draw: Draw, Loss: Win}[r.Outcome]
+	return op.MatchID == r.MatchID &&
+		op.PlayerID == r.OpponentID &&
+		op.OpponentID == r.PlayerID &&
+		op.First != r.First &&
+		op.Outcome == outcome
+}
@@ -0,0 +1,93 @@
+package profile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Store は対局記録をプレイヤーごとに保持します
+// 勝敗は各自の申告なので、相手の記録と突き合わせて食い違わなかった記録だけを成績に数えます
+// 相手がまだ報告していない記録や食い違った記録は pending に残します
+// w を渡すと記録を JSON Lines で書き出し、Load で読み戻せます
+type Store struct {
+	records map[string][]*Record
+	pending map[string]*Record
+	seen    map[string]bool
+	w       io.Writer
+	mu      sync.RWMutex
+}
+
+func NewStore(w io.Writer) *Store {
+	return &Store{
+		records: make(map[string][]*Record),
+		pending: make(map[string]*Record),
+		seen:    make(map[string]bool),
+		w:       w,
+	}
+}
+
+// Load は書き出した記録を読み込みます
+func (s *Store) Load(r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+		s.add(&record)
+	}
+	return scanner.Err()
+}
+
+// Add は記録を追加します
+// 同じ対局の同じプレイヤーの記録は1度しか受け付けません
+func (s *Store) Add(record *Record) error {
+	if err := record.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[record.key()] {
+		return fmt.Errorf("already recorded: %s", record.key())
+	}
+	if s.w != nil {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := s.w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	s.add(record)
+	return nil
+}
+
+func (s *Store) add(record *Record) {
+	s.seen[record.key()] = true
+	op, ok := s.pending[record.opponentKey()]
+	if !ok || !record.mirrors(op) {
+		s.pending[record.key()] = record
+		return
+	}
+	delete(s.pending, op.key())
+	s.records[record.PlayerID] = append(s.records[record.PlayerID], record)
+	s.records[op.PlayerID] = append(s.records[op.PlayerID], op)
+}
+
+// Records は playerID の相手と突き合わせ済みの記録を古い順に返します
+func (s *Store) Records(playerID string) []*Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := slices.Clone(s.records[playerID])
+	slices.SortStableFunc(records, func(a, b *Record) int {
+		return a.PlayedAt.Compare(b.PlayedAt)
+	})
+	return records
+}
@@ -0,0 +1,126 @@
+package profile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// report は matchID の対局で player から見た記録を返します
+func report(matchID, player, opponent string, first bool, outcome Outcome) *Record {
+	return &Record{MatchID: matchID, PlayerID: player, OpponentID: opponent, First: first, Outcome: outcome, PlayedAt: time.Unix(0, 0)}
+}
+
+func TestStoreAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		reports []*Record
+		// want は alice の成績に数える記録の数です
+		want int
+	}{
+		{
+			name:    "waits for the opponent",
+			reports: []*Record{report("m1", "alice", "bob", true, Win)},
+			want:    0,
+		},
+		{
+			name:    "counts agreeing reports",
+			reports: []*Record{report("m1", "alice", "bob", true, Win), report("m1", "bob", "alice", false, Loss)},
+			want:    1,
+		},
+		{
+			name:    "counts agreeing draws",
+			reports: []*Record{report("m1", "bob", "alice", false, Draw), report("m1", "alice", "bob", true, Draw)},
+			want:    1,
+		},
+		{
+			name:    "both claim the win",
+			reports: []*Record{report("m1", "alice", "bob", true, Win), report("m1", "bob", "alice", false, Win)},
+			want:    0,
+		},
+		{
+			name:    "both claim to move first",
+			reports: []*Record{report("m1", "alice", "bob", true, Win), report("m1", "bob", "alice", true, Loss)},
+			want:    0,
+		},
+		{
+			name:    "report from a third player",
+			reports: []*Record{report("m1", "alice", "bob", true, Win), report("m1", "carol", "alice", false, Loss)},
+			want:    0,
+		},
+		{
+			name:    "reports of another match",
+			reports: []*Record{report("m1", "alice", "bob", true, Win), report("m2", "bob", "alice", false, Loss)},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(nil)
+			for _, r := range tt.reports {
+				if err := s.Add(r); err != nil {
+					t.Fatalf("Add(%s) error: %v", r.key(), err)
+				}
+			}
+			if got := len(s.Records("alice")); got != tt.want {
+				t.Errorf("len(Records(alice)) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreAddRejects(t *testing.T) {
+	s := NewStore(nil)
+	if err := s.Add(report("m1", "alice", "bob", true, Win)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		record *Record
+	}{
+		{name: "same player reports the same match twice", record: report("m1", "alice", "bob", true, Loss)},
+		{name: "without match id", record: report("", "bob", "alice", false, Loss)},
+		{name: "against self", record: report("m2", "alice", "alice", true, Win)},
+		{name: "unknown outcome", record: report("m3", "alice", "bob", true, "lucky")},
+		{name: "negative guesses", record: &Record{MatchID: "m4", PlayerID: "alice", OpponentID: "bob", Outcome: Win, Guesses: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Add(tt.record); err == nil {
+				t.Error("Add() succeeded")
+			}
+		})
+	}
+}
+
+func TestStoreLoad(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStore(&buf)
+	for _, r := range []*Record{
+		report("m1", "alice", "bob", true, Win),
+		report("m1", "bob", "alice", false, Loss),
+		report("m2", "alice", "bob", false, Draw),
+	} {
+		if err := s.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded := NewStore(nil)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(loaded.Records("alice")); got != 1 {
+		t.Errorf("len(Records(alice)) = %d, want 1", got)
+	}
+	// 読み戻した後も、相手の報告を待っている記録は突き合わせられる
+	if err := loaded.Add(report("m2", "bob", "alice", true, Draw)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(loaded.Records("alice")); got != 2 {
+		t.Errorf("len(Records(alice)) = %d after the opponent reported, want 2", got)
+	}
+	if err := loaded.Add(report("m1", "alice", "bob", true, Win)); err == nil {
+		t.Error("Add() of a loaded record succeeded")
+	}
+}
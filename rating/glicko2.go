@@ -0,0 +1,114 @@
+package rating
+
+import (
+	"fmt"
+	"math"
+)
+
+// Glicko-2 の内部スケールへの変換係数です
+const glicko2Scale = 173.7178
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	// EstablishedDeviation は十分に対局したプレイヤーの典型的な偏差です
+	// 整数のレーティングしか分からない相手を FromRate で扱うときに使います
+	EstablishedDeviation = 60.0
+)
+
+// convergence は変動率の反復計算を打ち切る精度です
+const convergence = 0.000001
+
+type Score float64
+
+// Board.Result と同じく勝ち "1"、引き分け "0.5"、負け "0" です
+const (
+	Loss Score = 0
+	Draw Score = 0.5
+	Win  Score = 1
+)
+
+func NewScoreFromText(text string) (Score, error) {
+	switch text {
+	case "1":
+		return Win, nil
+	case "0.5":
+		return Draw, nil
+	case "0":
+		return Loss, nil
+	}
+	return 0, fmt.Errorf("invalid result: %q", text)
+}
+
+// Reverse は相手から見た結果です
+func (s Score) Reverse() Score {
+	return Win - s
+}
+
+// Rating は Glicko-2 のレーティング、偏差(RD)、変動率です
+type Rating struct {
+	rating     float64
+	deviation  float64
+	volatility float64
+}
+
+func NewRating(rating, deviation, volatility float64) *Rating {
+	return &Rating{rating, deviation, volatility}
+}
+
+// NewPlayerRating は初めて対局するプレイヤーのレーティングです
+func NewPlayerRating() *Rating {
+	return NewRating(DefaultRating, DefaultDeviation, DefaultVolatility)
+}
+
+// FromRate は整数のレーティングだけが分かるプレイヤーを、偏差の落ち着いたプレイヤーとして扱います
+func FromRate(rate int) *Rating {
+	return NewRating(float64(rate), EstablishedDeviation, DefaultVolatility)
+}
+
+func (r *Rating) Rating() float64 {
+	return r.rating
+}
+
+func (r *Rating) Deviation() float64 {
+	return r.deviation
+}
+
+func (r *Rating) Volatility() float64 {
+	return r.volatility
+}
+
+// Rate は表示に使う整数のレーティングです
+func (r *Rating) Rate() int {
+	return int(math.Round(r.rating))
+}
+
+func (r *Rating) mu() float64 {
+	return (r.rating - DefaultRating) / glicko2Scale
+}
+
+func (r *Rating) phi() float64 {
+	return r.deviation / glicko2Scale
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muOp, phiOp float64) float64 {
+	return 1 / (1 + math.Exp(-g(phiOp)*(mu-muOp)))
+}
+
+// ExpectedScore は r が op に対して得る勝ち点の期待値です
+// Glicko-2 の更新式と同じく、相手の偏差だけを考慮します
+func ExpectedScore(r, op *Rating) float64 {
+	return expected(r.mu(), op.mu(), op.phi())
+}
+
+// WinProbability は対局前に表示する、両者の偏差を考慮した r の勝率の見込みです
+// 引き分けは半分の勝ちとして含みます
+func WinProbability(r, op *Rating) float64 {
+	phi := math.Hypot(r.phi(), op.phi())
+	return 1 / (1 + math.Exp(-g(phi)*(r.mu()-op.mu())))
+}
@@ -0,0 +1,63 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestNewScoreFromText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Score
+		wantErr bool
+	}{
+		{text: "1", want: Win},
+		{text: "0.5", want: Draw},
+		{text: "0", want: Loss},
+		{text: "0.50", wantErr: true},
+		{text: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NewScoreFromText(tt.text)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NewScoreFromText(%q) = %v, %v, want %v, wantErr %t", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWinProbability(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *Rating
+		op   *Rating
+	}{
+		{name: "equal", r: NewRating(1500, 200, DefaultVolatility), op: NewRating(1500, 200, DefaultVolatility)},
+		{name: "stronger", r: NewRating(1700, 80, DefaultVolatility), op: NewRating(1500, 200, DefaultVolatility)},
+		{name: "new player", r: NewPlayerRating(), op: FromRate(1620)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, q := WinProbability(tt.r, tt.op), WinProbability(tt.op, tt.r)
+			if !near(p+q, 1, 1e-12) {
+				t.Errorf("WinProbability(r, op) + WinProbability(op, r) = %v, want 1", p+q)
+			}
+			switch {
+			case tt.r.Rating() > tt.op.Rating() && p <= 0.5,
+				tt.r.Rating() < tt.op.Rating() && p >= 0.5,
+				tt.r.Rating() == tt.op.Rating() && p != 0.5:
+				t.Errorf("WinProbability() = %v for %v against %v", p, tt.r.Rating(), tt.op.Rating())
+			}
+		})
+	}
+	// 偏差が大きいほど見込みは 0.5 に寄る
+	strong := NewRating(1700, 50, DefaultVolatility)
+	sure := WinProbability(strong, NewRating(1500, 50, DefaultVolatility))
+	unsure := WinProbability(strong, NewRating(1500, 350, DefaultVolatility))
+	if !(sure > unsure && unsure > 0.5) {
+		t.Errorf("WinProbability() = %v against a settled player, %v against an unsettled one", sure, unsure)
+	}
+}
@@ -0,0 +1,129 @@
+package rating
+
+import (
+	"math"
+)
+
+// System は Glicko-2 の計算の設定です
+type System struct {
+	// tau は変動率の変化のしやすさで、0.3 から 1.2 程度にします
+	tau float64
+}
+
+func NewSystem(tau float64) *System {
+	return &System{tau}
+}
+
+func DefaultSystem() *System {
+	return NewSystem(0.5)
+}
+
+type outcome struct {
+	op    *Rating
+	score Score
+}
+
+// Period はレーティング期間です
+// 期間中の対局をまとめておき、Rate で全員のレーティングを一度に更新します
+type Period struct {
+	system   *System
+	ratings  map[string]*Rating
+	outcomes map[string][]*outcome
+}
+
+// NewPeriod は ratings を期間の開始時のレーティングとしてレーティング期間を始めます
+func (s *System) NewPeriod(ratings map[string]*Rating) *Period {
+	p := &Period{
+		system:   s,
+		ratings:  make(map[string]*Rating, len(ratings)),
+		outcomes: make(map[string][]*outcome),
+	}
+	for id, r := range ratings {
+		p.ratings[id] = r
+	}
+	return p
+}
+
+func (p *Period) rating(id string) *Rating {
+	r, ok := p.ratings[id]
+	if !ok {
+		r = NewPlayerRating()
+		p.ratings[id] = r
+	}
+	return r
+}
+
+// Add は player1 から見た結果が score の対局を加えます
+// 期間中の対局はすべて期間の開始時のレーティング同士で計算します
+func (p *Period) Add(player1, player2 string, score Score) {
+	r1, r2 := p.rating(player1), p.rating(player2)
+	p.outcomes[player1] = append(p.outcomes[player1], &outcome{r2, score})
+	p.outcomes[player2] = append(p.outcomes[player2], &outcome{r1, score.Reverse()})
+}
+
+// Rate は期間の終了時の全員のレーティングを返します
+// 期間中に対局しなかったプレイヤーは偏差だけが広がります
+func (p *Period) Rate() map[string]*Rating {
+	next := make(map[string]*Rating, len(p.ratings))
+	for id, r := range p.ratings {
+		next[id] = p.system.update(r, p.outcomes[id])
+	}
+	return next
+}
+
+// update は Glickman の "Example of the Glicko-2 system" の手順 3 から 8 です
+func (s *System) update(r *Rating, outcomes []*outcome) *Rating {
+	mu, phi, sigma := r.mu(), r.phi(), r.volatility
+	if len(outcomes) == 0 {
+		phiStar := math.Sqrt(phi*phi + sigma*sigma)
+		return NewRating(r.rating, math.Min(phiStar*glicko2Scale, DefaultDeviation), sigma)
+	}
+	var vInv, sum float64
+	for _, o := range outcomes {
+		gj := g(o.op.phi())
+		e := expected(mu, o.op.mu(), o.op.phi())
+		vInv += gj * gj * e * (1 - e)
+		sum += gj * (float64(o.score) - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+	sigma = s.volatility(delta, phi, v, sigma)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+	return NewRating(mu*glicko2Scale+DefaultRating, phi*glicko2Scale, sigma)
+}
+
+// volatility は手順 5 の Illinois 法で新しい変動率を求めます
+func (s *System) volatility(delta, phi, v, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau2 := s.tau * s.tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/tau2
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*s.tau) < 0 {
+			k++
+		}
+		B = a - k*s.tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
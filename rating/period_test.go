@@ -0,0 +1,85 @@
+package rating
+
+import "testing"
+
+// TestPeriodGlickmanExample は Glickman の "Example of the Glicko-2 system" の計算例です
+func TestPeriodGlickmanExample(t *testing.T) {
+	p := NewSystem(0.5).NewPeriod(map[string]*Rating{
+		"player": NewRating(1500, 200, 0.06),
+		"op1":    NewRating(1400, 30, 0.06),
+		"op2":    NewRating(1550, 100, 0.06),
+		"op3":    NewRating(1700, 300, 0.06),
+	})
+	p.Add("player", "op1", Win)
+	p.Add("op2", "player", Win)
+	p.Add("player", "op3", Loss)
+	got := p.Rate()["player"]
+	if !near(got.Rating(), 1464.06, 0.01) || !near(got.Deviation(), 151.52, 0.01) || !near(got.Volatility(), 0.05999, 0.00001) {
+		t.Errorf("Rate() = %.2f/%.2f/%.5f, want 1464.06/151.52/0.05999", got.Rating(), got.Deviation(), got.Volatility())
+	}
+}
+
+func TestPeriodDraw(t *testing.T) {
+	tests := []struct {
+		name string
+		r1   *Rating
+		r2   *Rating
+		// want1 は player1 のレーティングの変化の向きです
+		want1 int
+	}{
+		{name: "equal players", r1: NewRating(1500, 200, 0.06), r2: NewRating(1500, 200, 0.06), want1: 0},
+		{name: "stronger player1", r1: NewRating(1700, 100, 0.06), r2: NewRating(1500, 100, 0.06), want1: -1},
+		{name: "weaker player1", r1: NewRating(1400, 100, 0.06), r2: NewRating(1600, 100, 0.06), want1: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultSystem().NewPeriod(map[string]*Rating{"p1": tt.r1, "p2": tt.r2})
+			score, err := NewScoreFromText("0.5")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Add("p1", "p2", score)
+			next := p.Rate()
+			d1 := next["p1"].Rating() - tt.r1.Rating()
+			d2 := next["p2"].Rating() - tt.r2.Rating()
+			switch {
+			case tt.want1 == 0 && !near(d1, 0, 1e-9),
+				tt.want1 < 0 && d1 >= 0,
+				tt.want1 > 0 && d1 <= 0:
+				t.Errorf("player1 moved %+.2f, want direction %d", d1, tt.want1)
+			}
+			// 偏差が同じなら引き分けの増減は対称になる
+			if !near(d1, -d2, 1e-9) {
+				t.Errorf("player1 moved %+.2f, player2 moved %+.2f, want opposite", d1, d2)
+			}
+			if next["p1"].Deviation() >= tt.r1.Deviation() {
+				t.Errorf("Deviation() = %.2f after a game, want below %.2f", next["p1"].Deviation(), tt.r1.Deviation())
+			}
+		})
+	}
+}
+
+func TestPeriodWithoutGames(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *Rating
+		want float64
+	}{
+		// φ* = √(φ² + σ²) を元のスケールに戻した値
+		{name: "settled player", r: NewRating(1600, 200, 0.06), want: 200.27},
+		{name: "volatile player", r: NewRating(1600, 50, 0.2), want: 60.89},
+		{name: "capped at the initial deviation", r: NewRating(1600, 349.9, 0.06), want: DefaultDeviation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultSystem().NewPeriod(map[string]*Rating{"idle": tt.r})
+			got := p.Rate()["idle"]
+			if got.Rating() != tt.r.Rating() || got.Volatility() != tt.r.Volatility() {
+				t.Errorf("Rate() = %.2f/%.5f, want %.2f/%.5f unchanged", got.Rating(), got.Volatility(), tt.r.Rating(), tt.r.Volatility())
+			}
+			if !near(got.Deviation(), tt.want, 0.01) {
+				t.Errorf("Deviation() = %.2f, want %.2f", got.Deviation(), tt.want)
+			}
+		})
+	}
+}
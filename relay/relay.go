@@ -0,0 +1,138 @@
+// Package relay は P2P でつながらないプレイヤー同士の対局のメッセージを中継する WebSocket サーバです
+// 同じルームに入った2人をつなぎ、片方から届いたメッセージをそのまま相手に送ります
+package relay
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// DefaultPairTimeout は相手がルームに入るのを待つ時間です
+const DefaultPairTimeout = time.Minute
+
+// waiting は相手を待っている1人目です
+type waiting struct {
+	ws     *websocket.Conn
+	paired chan *websocket.Conn
+}
+
+// Server はルームごとに相手を待つ1人目を持ちます
+type Server struct {
+	// auth が nil の場合はトークンを検証しません
+	auth        identity.Authenticator
+	pairTimeout time.Duration
+	rooms       map[string]*waiting
+	mu          sync.Mutex
+}
+
+func NewServer(auth identity.Authenticator, pairTimeout time.Duration) *Server {
+	return &Server{
+		auth:        auth,
+		pairTimeout: pairTimeout,
+		rooms:       make(map[string]*waiting),
+	}
+}
+
+// Handler は GET /relay/{room} で WebSocket を受け付けます
+// ブラウザの WebSocket はヘッダを付けられないので、トークンは token クエリで受け取ります
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /relay/{room}", s.relay)
+	return mux
+}
+
+func (s *Server) relay(w http.ResponseWriter, r *http.Request) {
+	if s.auth != nil {
+		if _, err := s.auth.Authenticate(r.URL.Query().Get("token"), identity.AudienceRelay, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	// WASM クライアントは別のオリジンから配信されます
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		log.Printf("failed to accept: %v", err)
+		return
+	}
+	defer ws.CloseNow()
+	ws.SetReadLimit(transport.MaxRelayMessage)
+
+	peer, err := s.pair(r.Context(), r.PathValue("room"), ws)
+	if err != nil {
+		ws.Close(websocket.StatusTryAgainLater, "no opponent")
+		return
+	}
+	forward(r.Context(), ws, peer)
+}
+
+// pair はルームの1人目なら相手を待ち、2人目なら待っている1人目とつなぎます
+func (s *Server) pair(ctx context.Context, room string, ws *websocket.Conn) (*websocket.Conn, error) {
+	s.mu.Lock()
+	w, ok := s.rooms[room]
+	if ok {
+		delete(s.rooms, room)
+		s.mu.Unlock()
+		// 1人目が待っている間に抜けていた場合は、自分が1人目として待ちます
+		if err := wsjson.Write(ctx, w.ws, transport.RelayPaired{Type: "paired", Initiator: false}); err != nil {
+			close(w.paired)
+			return s.pair(ctx, room, ws)
+		}
+		if err := wsjson.Write(ctx, ws, transport.RelayPaired{Type: "paired", Initiator: true}); err != nil {
+			close(w.paired)
+			return nil, err
+		}
+		w.paired <- ws
+		return w.ws, nil
+	}
+	w = &waiting{ws: ws, paired: make(chan *websocket.Conn, 1)}
+	s.rooms[room] = w
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, s.pairTimeout)
+	defer cancel()
+	select {
+	case peer, ok := <-w.paired:
+		if !ok {
+			return nil, context.Canceled
+		}
+		return peer, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		alone := s.rooms[room] == w
+		if alone {
+			delete(s.rooms, room)
+		}
+		s.mu.Unlock()
+		if alone {
+			return nil, ctx.Err()
+		}
+		// 待ち時間が切れる直前に2人目が入った場合
+		peer, ok := <-w.paired
+		if !ok {
+			return nil, ctx.Err()
+		}
+		return peer, nil
+	}
+}
+
+// forward は from から届いたメッセージを to に送り、from が閉じたら to も閉じます
+func forward(ctx context.Context, from, to *websocket.Conn) {
+	defer to.Close(websocket.StatusNormalClosure, "opponent left")
+	for {
+		typ, data, err := from.Read(ctx)
+		if err != nil {
+			return
+		}
+		if err := to.Write(ctx, typ, data); err != nil {
+			return
+		}
+	}
+}
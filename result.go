@@ -0,0 +1,127 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+)
+
+// resultSignTimeout は終局後に相手の署名を待つ時間です
+const resultSignTimeout = 10 * time.Second
+
+// opResultSignatures は相手から受け取った結果への署名をマッチ ID ごとに渡します
+// 署名を待ち終えたマッチは nil にして、遅れて届いた署名が次のマッチで使われないようにします
+var (
+	opResultSignatures   = make(map[string]chan string)
+	opResultSignaturesMu sync.Mutex
+)
+
+// resultSignature は matchID の署名を受け取るチャネルで、相手の署名が先に届いた場合も同じチャネルを返します
+// 待ち終えたマッチでは nil を返します
+func resultSignature(matchID string) chan string {
+	opResultSignaturesMu.Lock()
+	defer opResultSignaturesMu.Unlock()
+	ch, ok := opResultSignatures[matchID]
+	if !ok {
+		ch = make(chan string, 1)
+		opResultSignatures[matchID] = ch
+	}
+	return ch
+}
+
+func forgetResultSignature(matchID string) {
+	opResultSignaturesMu.Lock()
+	defer opResultSignaturesMu.Unlock()
+	opResultSignatures[matchID] = nil
+}
+
+// onCommit は相手の手札のコミットメントを記録します
+// 開室者は start に、非開室者は start への返信の commit に載せて送ります
+func onCommit(board *game.Board, message Message) {
+	if message.Commitment == "" {
+		logger.Warn("opponent sent no commitment")
+		return
+	}
+	board.SetOpCommitment(message.Commitment)
+}
+
+// verifyExposedHand は公開された相手の手札が対局開始時のコミットメントと一致するかを確かめます
+// SHUFFLE や CHANGE で手札を変えた場合は最初の手札が公開されないので確かめられません
+func verifyExposedHand(board *game.Board, message Message) {
+	if board.History().HandChanged(game.OpTurn) {
+		return
+	}
+	if !game.VerifyCommitment(board.Commitment(game.OpTurn), game.NewHandFromText(message.MyHand), message.Nonce) {
+		logElem("[Sys]: Opponent's hand does not match the commitment!\n")
+	}
+}
+
+// onResultSignature は相手の結果への署名を受け取ります
+func onResultSignature(message Message) {
+	ch := resultSignature(message.MatchID)
+	if ch == nil {
+		logger.Warn("dropped late result signature", "match_id", message.MatchID)
+		return
+	}
+	select {
+	case ch <- message.Signature:
+	default:
+		logger.Warn("dropped unexpected result signature", "match_id", message.MatchID)
+	}
+}
+
+// signResult はマッチ全体の結果に署名して相手と交換し、報告する署名付きの結果を返します
+// players は開室者、非開室者の順の userID です
+// アカウントがない場合は nil を返し、従来の hash だけで報告します
+func signResult(dc transport.Transport, g *finishedGame, matchID string, players [2]string) *identity.SignedResult {
+	if identityKey == nil {
+		return nil
+	}
+	me, op := g.pNum-1, 2-g.pNum
+	var commitments [2]string
+	commitments[me], commitments[op] = g.commitments[game.MyTurn], g.commitments[game.OpTurn]
+	signed := &identity.SignedResult{
+		Statement: identity.ResultStatement{
+			MatchID:     matchID,
+			Players:     players,
+			Commitments: commitments,
+			MoveDigest:  g.digest,
+			Result:      g.result,
+		},
+	}
+	signed.Signatures[me] = identity.SignResult(identityKey, &signed.Statement)
+	opSignature := resultSignature(matchID)
+	defer forgetResultSignature(matchID)
+	if err := sendMessage(dc, Message{Type: "result_sig", Signature: signed.Signatures[me], MatchID: matchID}); err != nil {
+		logger.Error("failed to send result signature", "err", err)
+		return signed
+	}
+	select {
+	case sig := <-opSignature:
+		if err := verifyOpSignature(players[op], &signed.Statement, sig); err != nil {
+			logger.Error("failed to verify result signature", "err", err)
+			logElem("[Sys]: Opponent signed a different result, it needs an arbiter\n")
+			return signed
+		}
+		signed.Signatures[op] = sig
+	case <-time.After(resultSignTimeout):
+		logElem("[Sys]: Opponent did not sign the result, it needs an arbiter\n")
+	}
+	return signed
+}
+
+// verifyOpSignature は報告の前に相手の署名を確かめ、食い違いをその場で知らせます
+func verifyOpSignature(opID string, statement *identity.ResultStatement, signature string) error {
+	account, err := identityClient.Lookup(opID)
+	if err != nil {
+		return fmt.Errorf("failed to lookup %s: %w", opID, err)
+	}
+	return account.VerifyResult(statement, signature)
+}
@@ -0,0 +1,465 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+)
+
+// currentRoom は参加中のバトルロイヤルのルームです
+var currentRoom *roomClient
+
+const roomJoinTimeout = 10 * time.Second
+
+type updateMultiRatingResMsg struct {
+	MatchID   string   `json:"match_id"`
+	PlayerID  string   `json:"player_id"`
+	Hash      string   `json:"hash"`
+	Standings []string `json:"standings"`
+}
+
+// roomPeer はホストと1人のゲストを結ぶ接続です
+type roomPeer struct {
+	seat   int
+	userID string
+	conn   *ayame.Connection
+	dc     transport.Transport
+}
+
+// roomClient はスター型の接続でバトルロイヤルを進行します
+// Ayame のルームは1対1のため、ホストは席ごとに Ayame のルームを開き、ゲストから届いたメッセージを他のゲストへ中継します
+type roomClient struct {
+	code         string
+	isHost       bool
+	userID       string
+	hash         string
+	signalingURL url.URL
+	ratingURL    url.URL
+	room         *game.Room
+	coop         *game.Coop
+
+	peers map[int]*roomPeer
+	mu    sync.Mutex
+}
+
+func newRoomClient(code, userID, hash string, isHost bool, signalingURL, ratingURL url.URL) *roomClient {
+	return &roomClient{
+		code:         code,
+		isHost:       isHost,
+		userID:       userID,
+		hash:         hash,
+		signalingURL: signalingURL,
+		ratingURL:    ratingURL,
+		room:         game.NewRoom(userID),
+		coop:         game.NewCoop(userID),
+		peers:        map[int]*roomPeer{},
+	}
+}
+
+func roomSeatID(code string, seat int) string {
+	return fmt.Sprintf("room-%s-%d", code, seat)
+}
+
+// openSeats はホストとしてゲスト用の席をすべて開きます
+func (rc *roomClient) openSeats() {
+	for seat := 1; seat < game.MaxRoomPlayers; seat++ {
+		rc.connectSeat(seat, nil)
+	}
+}
+
+// joinSeat はゲストとして空いている席を先頭から順に探して接続します
+func (rc *roomClient) joinSeat(seat int) {
+	if seat >= game.MaxRoomPlayers {
+		js.Global().Call("alert", "Room is full or does not exist")
+		return
+	}
+	rc.connectSeat(seat, func() {
+		rc.joinSeat(seat + 1)
+	})
+}
+
+func (rc *roomClient) connectSeat(seat int, onReject func()) {
+	peer := &roomPeer{seat: seat}
+	rc.mu.Lock()
+	rc.peers[seat] = peer
+	rc.mu.Unlock()
+
+	peer.conn = dialPeer(rc.signalingURL, roomSeatID(rc.code, seat), "room-hit-and-blow", func(dc transport.Transport) {
+		rc.setupDataChannel(peer, dc)
+	}, func() {
+		if onReject == nil {
+			return
+		}
+		rc.mu.Lock()
+		delete(rc.peers, seat)
+		rc.mu.Unlock()
+		onReject()
+	})
+	if rc.isHost {
+		return
+	}
+	go func() {
+		time.Sleep(roomJoinTimeout)
+		// 満員で次の席に移った場合は、移った先の席の待ち時間に任せる
+		rc.mu.Lock()
+		timedOut := rc.peers[seat] == peer && peer.dc == nil
+		rc.mu.Unlock()
+		if timedOut {
+			peer.conn.Disconnect()
+			js.Global().Call("alert", "Room not found")
+		}
+	}()
+}
+
+// dialPeer は1対1の Ayame のルーム roomID に接続し、DataChannel が確立したら経路として onDataChannel に渡します
+// DataChannel の確立前に切断された場合(ルームが満員のときなど)は onReject を呼びます
+func dialPeer(signalingURL url.URL, roomID, label string, onDataChannel func(transport.Transport), onReject func()) *ayame.Connection {
+	conn := ayame.NewConnection(signalingURL.String(), roomID, ayame.DefaultOptions(), debugLog, false)
+	authorizeSignaling(conn)
+	var mu sync.Mutex
+	var established bool
+	setup := func(dc *webrtc.DataChannel) {
+		mu.Lock()
+		established = true
+		mu.Unlock()
+		onDataChannel(transport.NewDataChannel(dc))
+	}
+	conn.OnOpen(func(metadata *interface{}) {
+		dc, err := conn.CreateDataChannel(label, nil)
+		if err != nil {
+			// 後から入室した側が DataChannel を作成する
+			return
+		}
+		setup(dc)
+	})
+	conn.OnDataChannel(setup)
+	conn.OnConnect(func() {
+		conn.CloseWebSocketConnection()
+	})
+	conn.OnDisconnect(func(reason string, err error) {
+		if reason == "EXIT-RECV" {
+			return
+		}
+		logger.Warn("disconnected", "room_id", roomID, "reason", reason, "err", err)
+		mu.Lock()
+		rejected := !established
+		mu.Unlock()
+		if rejected {
+			onReject()
+		}
+	})
+	if err := conn.Connect(); err != nil {
+		logger.Error("failed to connect Ayame", "err", err)
+	}
+	return conn
+}
+
+func (rc *roomClient) setupDataChannel(peer *roomPeer, dc transport.Transport) {
+	rc.mu.Lock()
+	peer.dc = dc
+	rc.mu.Unlock()
+	dc.OnMessage(rc.onMessage(peer))
+	dc.OnClose(func() {
+		rc.onLeave(peer)
+	})
+	if rc.isHost {
+		return
+	}
+	dc.OnOpen(func() {
+		if err := sendMessage(dc, Message{Type: "room_join", From: rc.userID}); err != nil {
+			logger.Error("failed to send roomJoinMsg", "err", err)
+		}
+	})
+}
+
+// send は自分のメッセージを全員に届けます
+// ゲストはホストにだけ送り、ホストが残りのゲストへ中継します
+func (rc *roomClient) send(message Message) {
+	rc.relay(message, -1)
+}
+
+func (rc *roomClient) relay(message Message, from int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for seat, peer := range rc.peers {
+		if seat == from || peer.dc == nil || peer.dc.State() != transport.StateOpen {
+			continue
+		}
+		if err := sendMessage(peer.dc, message); err != nil {
+			logger.Error("failed to send message to seat", "type", message.Type, "seat", seat, "err", err)
+		}
+	}
+}
+
+func (rc *roomClient) onMessage(peer *roomPeer) func([]byte) {
+	return func(data []byte) {
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			logger.Error("failed to unmarshal", "err", err)
+			return
+		}
+		switch message.Type {
+		case "room_guess", "room_answer", "room_leave", "room_chat", "coop_guess":
+			// ゲストが他のプレイヤーになりすましたメッセージは中継も適用もしない
+			if rc.isHost && (peer.userID == "" || message.From != peer.userID) {
+				logger.Warn("sender does not match the seat", "type", message.Type, "seat", peer.seat, "from", message.From)
+				return
+			}
+		}
+		switch message.Type {
+		case "room_join":
+			if !rc.isHost || rc.room.IsPlaying() || rc.coop.IsPlaying() {
+				return
+			}
+			peer.userID = message.From
+			rc.send(Message{Type: "room_lobby", Players: rc.lobby()})
+			renderRoomLobby(rc.lobby())
+			return
+		case "room_lobby":
+			renderRoomLobby(message.Players)
+			return
+		case "room_start":
+			rc.start(message.Players)
+			return
+		case "room_guess":
+			if rc.isHost {
+				rc.relay(message, peer.seat)
+			}
+			rc.applyGuess(message)
+		case "room_answer":
+			if rc.isHost {
+				rc.relay(message, peer.seat)
+			}
+			rc.applyAnswer(message)
+		case "room_leave":
+			if rc.isHost {
+				rc.relay(message, peer.seat)
+			}
+			rc.room.Eliminate(message.From)
+			rc.coop.Leave(message.From)
+			logElem(fmt.Sprintf("[Room]: %s left\n", message.From))
+		case "room_chat":
+			if rc.isHost {
+				rc.relay(message, peer.seat)
+			}
+			appendRoomLog(fmt.Sprintf("%s: %s", message.From, message.Info))
+			return
+		case "coop_start":
+			rc.startCoop(message.Players, nil)
+			return
+		case "coop_guess":
+			if rc.isHost {
+				rc.relay(message, peer.seat)
+			}
+			rc.applyCoopGuess(message)
+			return
+		case "coop_answer":
+			// 回答できるのはディーラーのホストだけなので、ゲストの席から届いたものは受け付けない
+			// ゲストはホストの席としか繋がっていない
+			if rc.isHost {
+				logger.Warn("co-op answer from a guest seat", "seat", peer.seat, "from", message.From)
+				return
+			}
+			rc.applyCoopAnswer(message)
+			return
+		default:
+			return
+		}
+		rc.render()
+	}
+}
+
+func (rc *roomClient) onLeave(peer *roomPeer) {
+	if peer.userID == "" && rc.isHost {
+		return
+	}
+	if rc.coop.IsPlaying() {
+		rc.onCoopLeave(peer)
+		return
+	}
+	if !rc.room.IsPlaying() {
+		return
+	}
+	leaver := peer.userID
+	if !rc.isHost {
+		// ホストが抜けると中継できないのでホストを脱落させて終える
+		leaver = rc.room.Players()[0].ID()
+	}
+	logElem(fmt.Sprintf("[Room]: %s disconnected\n", leaver))
+	rc.room.Eliminate(leaver)
+	if rc.isHost {
+		rc.send(Message{Type: "room_leave", From: leaver})
+	}
+	rc.render()
+}
+
+// lobby は席順に参加者を返します
+func (rc *roomClient) lobby() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	ids := []string{rc.userID}
+	for seat := 1; seat < game.MaxRoomPlayers; seat++ {
+		if peer, ok := rc.peers[seat]; ok && peer.userID != "" {
+			ids = append(ids, peer.userID)
+		}
+	}
+	return ids
+}
+
+func (rc *roomClient) start(ids []string) {
+	rand.NewSource(time.Now().UnixNano())
+	myHand := game.NewHandBySeed(rand.Int())
+	if err := rc.room.Start(myHand, ids); err != nil {
+		js.Global().Call("alert", err.Error())
+		return
+	}
+	setHand(true, myHand)
+	logElem(fmt.Sprintf("[Room]: Start with %v\n", ids))
+	rc.render()
+}
+
+func (rc *roomClient) sendGuess(target string, guess *game.Guess) {
+	message := Message{Type: "room_guess", From: rc.userID, Target: target, Guess: guess.Msg()}
+	rc.send(message)
+	rc.applyGuess(message)
+	rc.render()
+}
+
+func (rc *roomClient) applyGuess(message Message) {
+	if _, err := rc.room.Guess(message.From, message.Target, game.NewGuessFromText(message.Guess)); err != nil {
+		logger.Warn("invalid room guess", "err", err)
+		return
+	}
+	if !rc.room.IsMyAnswerNeeded() {
+		return
+	}
+	ans := rc.room.CalcAnswer()
+	hit, blow := ans.Hit(), ans.Blow()
+	answer := Message{Type: "room_answer", From: rc.userID, Target: message.From, Hit: &hit, Blow: &blow}
+	rc.send(answer)
+	rc.applyAnswer(answer)
+}
+
+func (rc *roomClient) applyAnswer(message Message) {
+	if message.Hit == nil || message.Blow == nil {
+		logger.Warn("invalid room answer", "from", message.From)
+		return
+	}
+	if err := rc.room.Answer(message.From, game.NewAnswer(*message.Hit, *message.Blow)); err != nil {
+		logger.Warn("invalid room answer", "err", err)
+		return
+	}
+	calls := rc.room.Calls()
+	logElem(fmt.Sprintf("[Room]: %s\n", calls[len(calls)-1].View()))
+	appendRoomLog(calls[len(calls)-1].View())
+	if rc.room.IsFinished() {
+		rc.finish()
+	}
+}
+
+func (rc *roomClient) finish() {
+	standings := rc.room.Standings()
+	logElem(fmt.Sprintf("[Room]: Finish! %v\n", standings))
+	go func() {
+		if err := updateMultiRating(rc.ratingURL, rc.code, rc.userID, rc.hash, standings); err != nil {
+			logger.Error("failed to update rating", "err", err)
+		}
+	}()
+}
+
+func (rc *roomClient) render() {
+	players := getElementByID("room-players")
+	players.Set("innerHTML", "")
+	for _, p := range rc.room.Players() {
+		text := p.ID()
+		if p.ID() == rc.userID {
+			text += " (You)"
+		}
+		switch {
+		case p.Place() > 0:
+			text += fmt.Sprintf(" #%d", p.Place())
+		case rc.room.IsPlaying() && rc.room.CurrentPlayer() == p.ID():
+			text += " <- turn"
+		}
+		item := js.Global().Get("document").Call("createElement", "li")
+		item.Set("textContent", text)
+		players.Call("appendChild", item)
+	}
+	target := getElementByID("room-target")
+	target.Set("innerHTML", "")
+	addOption := func(value, text string) {
+		option := js.Global().Get("document").Call("createElement", "option")
+		option.Set("value", value)
+		option.Set("textContent", text)
+		target.Call("appendChild", option)
+	}
+	addOption(game.AllTargets, "All opponents")
+	for _, id := range rc.room.Alive() {
+		if id != rc.userID {
+			addOption(id, id)
+		}
+	}
+	switch {
+	case rc.room.IsFinished():
+		setTurn(fmt.Sprintf("Finish !!! Standings: %v", rc.room.Standings()))
+	case rc.room.IsMyTurn():
+		setTurn("It's Your Turn ! Choose a target")
+	case rc.room.IsPlaying():
+		setTurn(fmt.Sprintf("It's %s's Turn, Waiting ...", rc.room.CurrentPlayer()))
+	}
+}
+
+func renderRoomLobby(ids []string) {
+	players := getElementByID("room-players")
+	players.Set("innerHTML", "")
+	for _, id := range ids {
+		item := js.Global().Get("document").Call("createElement", "li")
+		item.Set("textContent", id)
+		players.Call("appendChild", item)
+	}
+	setTurn(fmt.Sprintf("Waiting players ... (%d/%d)", len(ids), game.MaxRoomPlayers))
+}
+
+func appendRoomLog(text string) {
+	item := js.Global().Get("document").Call("createElement", "li")
+	item.Set("textContent", text)
+	getElementByID("room-log").Call("appendChild", item)
+}
+
+func updateMultiRating(ratingURL url.URL, matchID, myID, hash string, standings []string) error {
+	resMsg := updateMultiRatingResMsg{
+		MatchID:   matchID,
+		PlayerID:  myID,
+		Hash:      hash,
+		Standings: standings,
+	}
+	ratingURL.Path = path.Join(ratingURL.Path, "/finish_multi")
+	body, err := json.Marshal(resMsg)
+	if err != nil {
+		return err
+	}
+	res, err := postAuthorized(ratingURL.String(), identity.AudienceRating, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update rating: %v", res.Status)
+	}
+	return nil
+}
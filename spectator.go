@@ -0,0 +1,190 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"syscall/js"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/game"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+)
+
+const maxSpectators = 4
+
+var (
+	// spectators は開室者が観戦者へ対局を中継するハブです
+	spectators *spectatorHub
+	// currentSpectator は観戦中の対局です
+	currentSpectator *spectatorClient
+)
+
+func spectateSeatID(roomID string, seat int) string {
+	return fmt.Sprintf("%s-watch-%d", roomID, seat)
+}
+
+// spectatorHub は開室者の盤面から手札を伏せたイベントを作り、観戦者へ一方向に送ります
+// Turn は開室者(player1)から見た手番で、手札は expose の後にだけ公開します
+type spectatorHub struct {
+	players []string
+	events  []Message
+	dcs     []transport.Transport
+	mu      sync.Mutex
+}
+
+func newSpectatorHub(p1ID, p2ID string) *spectatorHub {
+	return &spectatorHub{
+		players: []string{p1ID, p2ID},
+		events:  make([]Message, 0),
+		dcs:     make([]transport.Transport, 0),
+	}
+}
+
+// open は観戦者用の席を開きます
+func (h *spectatorHub) open(signalingURL url.URL, roomID string) {
+	for seat := 1; seat <= maxSpectators; seat++ {
+		dialPeer(signalingURL, spectateSeatID(roomID, seat), "spectate-hit-and-blow", h.attach, func() {})
+	}
+	logElem(fmt.Sprintf("[Sys]: Spectators can watch with room ID %s\n", roomID))
+}
+
+// attach は途中から観戦を始めた観戦者にも現在の局の経過を送ります
+func (h *spectatorHub) attach(dc transport.Transport) {
+	dc.OnOpen(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.dcs = append(h.dcs, dc)
+		for _, event := range h.events {
+			if err := sendMessage(dc, event); err != nil {
+				logger.Error("failed to send message to spectator", "type", event.Type, "err", err)
+			}
+		}
+	})
+}
+
+func (h *spectatorHub) publish(event Message) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if event.Type == "spec_start" {
+		h.events = h.events[:0]
+	}
+	h.events = append(h.events, event)
+	for _, dc := range h.dcs {
+		if dc.State() != transport.StateOpen {
+			continue
+		}
+		if err := sendMessage(dc, event); err != nil {
+			logger.Error("failed to send message to spectator", "type", event.Type, "err", err)
+		}
+	}
+}
+
+// setOpponent はプライベートルームで後から分かった相手の userID を設定します
+func (h *spectatorHub) setOpponent(id string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.players[1] = id
+}
+
+func (h *spectatorHub) start(board *game.Board) {
+	turn := int(board.InitTurn())
+	h.publish(Message{Type: "spec_start", Turn: &turn, Players: h.players, TimeControl: board.TimeControl().Msg(), Variant: board.Variant().Msg(), Rules: board.Rules().Msg()})
+}
+
+func (h *spectatorHub) call(by game.Turn, qa *game.QA) {
+	turn, hit, blow := int(by), qa.Answer().Hit(), qa.Answer().Blow()
+	h.publish(Message{Type: "spec_call", Turn: &turn, Guess: qa.Guess().Msg(), Hit: &hit, Blow: &blow})
+}
+
+// item はアイテムの種類だけを伝え、手札に関わる結果は伏せます
+func (h *spectatorHub) item(by game.Turn, item game.Item) {
+	turn := int(by)
+	h.publish(Message{Type: "spec_item", Turn: &turn, Item: item.Msg()})
+}
+
+func (h *spectatorHub) reveal(by game.Turn, hand string) {
+	turn := int(by)
+	h.publish(Message{Type: "spec_reveal", Turn: &turn, MyHand: hand})
+}
+
+func (h *spectatorHub) finish(board *game.Board) {
+	h.publish(Message{Type: "spec_finish", Info: board.Result()})
+	h.reveal(game.MyTurn, board.MyHandText())
+}
+
+// spectatorClient は中継されたイベントを読み取り専用で表示します
+// 盤面の左に player1、右に player2 の call を並べます
+type spectatorClient struct {
+	players []string
+	rows    [2]int
+}
+
+// watch は観戦者として空いている席を先頭から順に探して接続します
+func watch(signalingURL url.URL, roomID string, seat int) {
+	if seat > maxSpectators {
+		js.Global().Call("alert", "No spectator seat is available")
+		currentSpectator = nil
+		return
+	}
+	sc := currentSpectator
+	dialPeer(signalingURL, spectateSeatID(roomID, seat), "spectate-hit-and-blow", func(dc transport.Transport) {
+		dc.OnMessage(sc.onMessage)
+		dc.OnClose(func() {
+			setTurn("Broadcast ended")
+		})
+	}, func() {
+		watch(signalingURL, roomID, seat+1)
+	})
+}
+
+func (sc *spectatorClient) onMessage(data []byte) {
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		logger.Error("failed to unmarshal", "err", err)
+		return
+	}
+	switch message.Type {
+	case "spec_start":
+		resetView()
+		sc.players = message.Players
+		sc.rows = [2]int{}
+		getElementByID("my-profile").Set("innerHTML", sc.players[0])
+		getElementByID("op-profile").Set("innerHTML", sc.players[1])
+		logElem(fmt.Sprintf("[Watch]: %s vs %s (%s, %s)\n", sc.players[0], sc.players[1], message.TimeControl, message.Rules))
+		setTurn(fmt.Sprintf("Watching ... %s first", sc.players[*message.Turn]))
+	case "spec_call":
+		turn := game.Turn(*message.Turn)
+		sc.rows[turn]++
+		guess := game.NewGuessFromText(message.Guess)
+		setScore(turn, sc.rows[turn], guess.View(), *message.Hit, *message.Blow)
+		logElem(fmt.Sprintf("[%s]: %s (%s)\n", sc.players[turn], guess.View(), game.NewAnswer(*message.Hit, *message.Blow).Msg()))
+	case "spec_item":
+		item, err := game.NewItemFromText(message.Item)
+		if err != nil {
+			logger.Warn("invalid item", "err", err)
+			return
+		}
+		logElem(fmt.Sprintf("[%s]: %s\n", sc.players[*message.Turn], item.View()))
+	case "spec_reveal":
+		setHand(game.Turn(*message.Turn) == game.MyTurn, game.NewHandFromText(message.MyHand))
+	case "spec_finish":
+		switch message.Info {
+		case "1":
+			setTurn(fmt.Sprintf("Finish !!! %s wins", sc.players[0]))
+		case "0":
+			setTurn(fmt.Sprintf("Finish !!! %s wins", sc.players[1]))
+		default:
+			setTurn("Finish !!! Draw")
+		}
+	}
+}
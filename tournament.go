@@ -0,0 +1,164 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+)
+
+const tournamentPollInterval = 3 * time.Second
+
+var (
+	// tournamentID は参加中の大会のIDで、大会に参加していなければ空です
+	tournamentID string
+	// tournamentFirst は大会が指定した先手の userID です
+	tournamentFirst string
+)
+
+type tournamentRegisterReqMsg struct {
+	TournamentID string `json:"tournament_id"`
+	PlayerID     string `json:"player_id"`
+	Hash         string `json:"hash"`
+}
+
+type tournamentPairingResMsg struct {
+	Type   string `json:"type"`
+	Round  int    `json:"round"`
+	RoomID string `json:"room_id"`
+	UserID string `json:"user_id"`
+	First  string `json:"first"`
+}
+
+type tournamentFinishReqMsg struct {
+	TournamentID string                 `json:"tournament_id"`
+	MatchID      string                 `json:"match_id"`
+	PlayerID     string                 `json:"player_id"`
+	Number       int                    `json:"number"`
+	Hash         string                 `json:"hash"`
+	Result       string                 `json:"result"`
+	Signed       *identity.SignedResult `json:"signed,omitempty"`
+}
+
+type tournamentStandingsResMsg struct {
+	Round     int  `json:"round"`
+	Rounds    int  `json:"rounds"`
+	Finished  bool `json:"finished"`
+	Standings []struct {
+		Rank     int     `json:"rank"`
+		Player   string  `json:"player"`
+		Score    float64 `json:"score"`
+		Buchholz float64 `json:"buchholz"`
+	} `json:"standings"`
+}
+
+func postTournament(tournamentURL url.URL, endpoint string, reqMsg any) error {
+	tournamentURL.Path = path.Join(tournamentURL.Path, endpoint)
+	body, err := json.Marshal(reqMsg)
+	if err != nil {
+		return err
+	}
+	res, err := postAuthorized(tournamentURL.String(), identity.AudienceTournament, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to post %s: %v", endpoint, res.Status)
+	}
+	return nil
+}
+
+func getTournament(tournamentURL url.URL, endpoint string, query url.Values, resMsg any) error {
+	tournamentURL.Path = path.Join(tournamentURL.Path, endpoint)
+	tournamentURL.RawQuery = query.Encode()
+	res, err := getAuthorized(tournamentURL.String(), identity.AudienceTournament)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: %v", endpoint, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resMsg)
+}
+
+func registerTournament(tournamentURL url.URL, myID, hash string) error {
+	return postTournament(tournamentURL, "/register", tournamentRegisterReqMsg{
+		TournamentID: tournamentID,
+		PlayerID:     myID,
+		Hash:         hash,
+	})
+}
+
+// waitTournamentPairing は次の対局が組まれるか大会が終わるまで待ちます
+func waitTournamentPairing(tournamentURL url.URL, myID string) (*tournamentPairingResMsg, error) {
+	query := url.Values{"tournament_id": {tournamentID}, "player_id": {myID}}
+	var lastType string
+	for {
+		var resMsg tournamentPairingResMsg
+		if err := getTournament(tournamentURL, "/pairing", query, &resMsg); err != nil {
+			return nil, err
+		}
+		switch resMsg.Type {
+		case "MATCH", "FINISH":
+			return &resMsg, nil
+		case "BYE":
+			if lastType != resMsg.Type {
+				logElem(fmt.Sprintf("[Tournament]: Round %d Bye, Waiting next round...\n", resMsg.Round))
+			}
+		default:
+			if lastType != resMsg.Type {
+				logElem("[Tournament]: Waiting pairing...\n")
+			}
+		}
+		lastType = resMsg.Type
+		time.Sleep(tournamentPollInterval)
+	}
+}
+
+// reportTournament は /rating/finish と同じ内容を大会にも報告します
+func reportTournament(tournamentURL url.URL, matchID, myID, hash string, pNum int, result string, signed *identity.SignedResult) error {
+	return postTournament(tournamentURL, "/finish", tournamentFinishReqMsg{
+		TournamentID: tournamentID,
+		MatchID:      matchID,
+		PlayerID:     myID,
+		Number:       pNum,
+		Hash:         hash,
+		Result:       result,
+		Signed:       signed,
+	})
+}
+
+func showTournamentStandings(tournamentURL url.URL) {
+	var resMsg tournamentStandingsResMsg
+	if err := getTournament(tournamentURL, "/standings", url.Values{"tournament_id": {tournamentID}}, &resMsg); err != nil {
+		logger.Error("failed to get standings", "err", err)
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Tournament]: Standings after round %d/%d\n", resMsg.Round, resMsg.Rounds)
+	for _, s := range resMsg.Standings {
+		fmt.Fprintf(&sb, "%d. %s %.1f (Buchholz %.1f)\n", s.Rank, s.Player, s.Score, s.Buchholz)
+	}
+	logElem(sb.String())
+}
+
+// finishTournamentMatch は大会の対局結果を報告して順位表を表示します
+// 次のラウンドは接続をやり直すため、ページを再読み込みしてから参加します
+func finishTournamentMatch(tournamentURL url.URL, matchID, myID, hash string, pNum int, result string, signed *identity.SignedResult) {
+	if err := reportTournament(tournamentURL, matchID, myID, hash, pNum, result, signed); err != nil {
+		logger.Error("failed to report tournament result", "err", err)
+		return
+	}
+	showTournamentStandings(tournamentURL)
+	logElem("[Tournament]: Reload and JOIN again for the next round\n")
+}
@@ -0,0 +1,210 @@
+package tournament
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// Standing は順位表の1行です
+type Standing struct {
+	Rank     int     `json:"rank"`
+	Player   string  `json:"player"`
+	Score    float64 `json:"score"`
+	Buchholz float64 `json:"buchholz"`
+	Firsts   int     `json:"firsts"`
+}
+
+// record は組み合わせに必要な各参加者の成績です
+type record struct {
+	score     float64
+	firsts    int
+	seconds   int
+	opponents []string
+	hadBye    bool
+}
+
+func (t *Tournament) records() map[string]*record {
+	records := make(map[string]*record, len(t.players))
+	for _, player := range t.players {
+		records[player] = &record{opponents: make([]string, 0)}
+	}
+	for _, p := range t.finished() {
+		r1 := records[p.player1]
+		r1.score += p.Score(p.player1)
+		if p.IsBye() {
+			r1.hadBye = true
+			continue
+		}
+		r2 := records[p.player2]
+		r2.score += p.Score(p.player2)
+		r1.firsts++
+		r2.seconds++
+		r1.opponents = append(r1.opponents, p.player2)
+		r2.opponents = append(r2.opponents, p.player1)
+	}
+	return records
+}
+
+// Standings は勝ち点、Buchholz(対戦相手の勝ち点の合計)、登録順で並べた順位表を返します
+func (t *Tournament) Standings() []*Standing {
+	records := t.records()
+	standings := make([]*Standing, len(t.players))
+	for i, player := range t.players {
+		r := records[player]
+		var buchholz float64
+		for _, op := range r.opponents {
+			buchholz += records[op].score
+		}
+		standings[i] = &Standing{Player: player, Score: r.score, Buchholz: buchholz, Firsts: r.firsts}
+	}
+	slices.SortStableFunc(standings, compareStanding)
+	for i, s := range standings {
+		s.Rank = i + 1
+		if i > 0 && compareStanding(standings[i-1], s) == 0 {
+			s.Rank = standings[i-1].Rank
+		}
+	}
+	return standings
+}
+
+func compareStanding(a, b *Standing) int {
+	switch {
+	case a.Score != b.Score:
+		if a.Score > b.Score {
+			return -1
+		}
+		return 1
+	case a.Buchholz != b.Buchholz:
+		if a.Buchholz > b.Buchholz {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// swissPairs は順位の近い参加者同士を、再戦を避けて組み合わせます
+// 奇数人のときは不戦勝をまだ受けていない最下位の参加者に与えます
+func (t *Tournament) swissPairs() [][2]string {
+	records := t.records()
+	standings := t.Standings()
+	players := make([]string, len(standings))
+	for i, s := range standings {
+		players[i] = s.Player
+	}
+	pairs := make([][2]string, 0, len(players)/2+1)
+	var bye string
+	if len(players)%2 == 1 {
+		bye = players[len(players)-1]
+		for i := len(players) - 1; i >= 0; i-- {
+			if !records[players[i]].hadBye {
+				bye = players[i]
+				break
+			}
+		}
+		players = slices.DeleteFunc(players, func(p string) bool {
+			return p == bye
+		})
+	}
+	matched, ok := pairWithoutRematch(players, records)
+	if !ok {
+		// 再戦を避けきれない場合は順位順にそのまま組む
+		matched = make([][2]string, 0, len(players)/2)
+		for i := 0; i+1 < len(players); i += 2 {
+			matched = append(matched, [2]string{players[i], players[i+1]})
+		}
+	}
+	for _, pair := range matched {
+		pairs = append(pairs, balanceFirst(pair, records))
+	}
+	if bye != "" {
+		pairs = append(pairs, [2]string{bye, ""})
+	}
+	return pairs
+}
+
+// pairWithoutRematch は上位から順に、まだ対戦していない最も順位の近い相手を探して組み合わせます
+func pairWithoutRematch(players []string, records map[string]*record) ([][2]string, bool) {
+	if len(players) == 0 {
+		return [][2]string{}, true
+	}
+	first := players[0]
+	for i := 1; i < len(players); i++ {
+		second := players[i]
+		if slices.Contains(records[first].opponents, second) {
+			continue
+		}
+		rest := slices.Concat(players[1:i], players[i+1:])
+		if pairs, ok := pairWithoutRematch(rest, records); ok {
+			return append([][2]string{{first, second}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// balanceFirst は先手の回数が少ない方を先手にします
+func balanceFirst(pair [2]string, records map[string]*record) [2]string {
+	a, b := records[pair[0]], records[pair[1]]
+	if a.firsts-a.seconds > b.firsts-b.seconds {
+		return [2]string{pair[1], pair[0]}
+	}
+	return pair
+}
+
+func knockoutRounds(n int) int {
+	return bits.Len(uint(n - 1))
+}
+
+// knockoutPairs は1回戦では上位シードと下位シードを組み合わせ、以降は隣り合う卓の勝者同士を組み合わせます
+// 参加人数が2の累乗に満たない分は上位シードの不戦勝にします
+func (t *Tournament) knockoutPairs() [][2]string {
+	var survivors []string
+	if len(t.pairings) == 0 {
+		size := 1 << knockoutRounds(len(t.players))
+		seeds := make([]string, size)
+		copy(seeds, t.players)
+		survivors = make([]string, 0, size)
+		for _, seed := range bracketOrder(size) {
+			survivors = append(survivors, seeds[seed])
+		}
+	} else {
+		// 再戦があった卓は最後の組み合わせの勝者が勝ち上がる
+		last := t.pairings[len(t.pairings)-1]
+		winners := make(map[int]string)
+		tables := 0
+		for _, p := range last {
+			winners[p.table] = p.Winner()
+			tables = max(tables, p.table)
+		}
+		for table := 1; table <= tables; table++ {
+			survivors = append(survivors, winners[table])
+		}
+	}
+	records := t.records()
+	pairs := make([][2]string, 0, len(survivors)/2)
+	for i := 0; i+1 < len(survivors); i += 2 {
+		a, b := survivors[i], survivors[i+1]
+		switch {
+		case b == "":
+			pairs = append(pairs, [2]string{a, ""})
+		case a == "":
+			pairs = append(pairs, [2]string{b, ""})
+		default:
+			pairs = append(pairs, balanceFirst([2]string{a, b}, records))
+		}
+	}
+	return pairs
+}
+
+// bracketOrder は1位と最下位、2位と下から2番目が当たり、上位シード同士が決勝まで当たらない並びを返します
+func bracketOrder(size int) []int {
+	order := []int{0}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n-1-seed)
+		}
+		order = next
+	}
+	return order
+}
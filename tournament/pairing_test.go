@@ -0,0 +1,191 @@
+package tournament
+
+import (
+	"slices"
+	"testing"
+)
+
+// newTestTournament は players を登録順に登録して開始したトーナメントを返します
+func newTestTournament(t *testing.T, format Format, rounds int, players ...string) *Tournament {
+	t.Helper()
+	tr := New("test", format, rounds)
+	for _, p := range players {
+		if err := tr.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+// report は進行中のラウンドの未決着の卓に、卓の順で results を報告します
+func report(t *testing.T, tr *Tournament, results ...string) {
+	t.Helper()
+	var open []*Pairing
+	for _, p := range tr.Pairings(tr.CurrentRound()) {
+		if !p.IsFinished() {
+			open = append(open, p)
+		}
+	}
+	if len(open) != len(results) {
+		t.Fatalf("round %d has %d open tables, got %d results", tr.CurrentRound(), len(open), len(results))
+	}
+	for i, p := range open {
+		if err := tr.Report(p.RoomID(), results[i]); err != nil {
+			t.Fatalf("Report(%s, %s) error: %v", p.RoomID(), results[i], err)
+		}
+	}
+}
+
+func pairsOf(tr *Tournament, round int) [][2]string {
+	pairs := make([][2]string, 0)
+	for _, p := range tr.Pairings(round) {
+		pairs = append(pairs, [2]string{p.Player1(), p.Player2()})
+	}
+	return pairs
+}
+
+func TestSwissPairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []string
+		rounds  int
+		results [][]string
+		want    [][2]string
+	}{
+		{
+			name:    "first round pairs neighbours by seed",
+			players: []string{"a", "b", "c", "d"},
+			rounds:  3,
+			want:    [][2]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			name:    "avoids a rematch between equal scores",
+			players: []string{"a", "b", "c", "d"},
+			rounds:  3,
+			results: [][]string{{Drawn, Drawn}},
+			want:    [][2]string{{"a", "c"}, {"b", "d"}},
+		},
+		{
+			name:    "player with fewer firsts moves first",
+			players: []string{"a", "b", "c", "d"},
+			rounds:  3,
+			results: [][]string{{Player1Win, Player2Win}},
+			want:    [][2]string{{"d", "a"}, {"b", "c"}},
+		},
+		{
+			name:    "rematch when it cannot be avoided",
+			players: []string{"a", "b"},
+			rounds:  2,
+			results: [][]string{{Player1Win}},
+			want:    [][2]string{{"b", "a"}},
+		},
+		{
+			name:    "bye goes to the lowest player",
+			players: []string{"a", "b", "c"},
+			rounds:  3,
+			want:    [][2]string{{"a", "b"}, {"c", ""}},
+		},
+		{
+			name:    "bye is not given twice",
+			players: []string{"a", "b", "c"},
+			rounds:  3,
+			results: [][]string{{Player1Win}},
+			want:    [][2]string{{"c", "a"}, {"b", ""}},
+		},
+		{
+			name:    "bye moves up to a player without one",
+			players: []string{"a", "b", "c"},
+			rounds:  3,
+			results: [][]string{{Player1Win}, {Player1Win}},
+			want:    [][2]string{{"b", "c"}, {"a", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTournament(t, Swiss, tt.rounds, tt.players...)
+			for _, results := range tt.results {
+				report(t, tr, results...)
+			}
+			if got := pairsOf(tr, tr.CurrentRound()); !slices.Equal(got, tt.want) {
+				t.Errorf("round %d pairs = %v, want %v", tr.CurrentRound(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStandings(t *testing.T) {
+	tr := newTestTournament(t, Swiss, 3, "a", "b", "c", "d")
+	report(t, tr, Player1Win, Drawn)
+	want := []Standing{
+		{Rank: 1, Player: "a", Score: 1, Buchholz: 0, Firsts: 1},
+		{Rank: 2, Player: "c", Score: 0.5, Buchholz: 0.5, Firsts: 1},
+		{Rank: 2, Player: "d", Score: 0.5, Buchholz: 0.5, Firsts: 0},
+		{Rank: 4, Player: "b", Score: 0, Buchholz: 1, Firsts: 0},
+	}
+	got := tr.Standings()
+	if len(got) != len(want) {
+		t.Fatalf("Standings() has %d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("Standings()[%d] = %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestBracketOrder(t *testing.T) {
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{size: 1, want: []int{0}},
+		{size: 2, want: []int{0, 1}},
+		{size: 4, want: []int{0, 3, 1, 2}},
+		{size: 8, want: []int{0, 7, 3, 4, 1, 6, 2, 5}},
+	}
+	for _, tt := range tests {
+		if got := bracketOrder(tt.size); !slices.Equal(got, tt.want) {
+			t.Errorf("bracketOrder(%d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestKnockout(t *testing.T) {
+	tr := newTestTournament(t, Knockout, 0, "a", "b", "c", "d", "e")
+	if tr.Rounds() != 3 {
+		t.Fatalf("Rounds() = %d, want 3", tr.Rounds())
+	}
+	// 8人の山に5人なので上位3シードが不戦勝になる
+	want := [][2]string{{"a", ""}, {"d", "e"}, {"b", ""}, {"c", ""}}
+	if got := pairsOf(tr, 1); !slices.Equal(got, want) {
+		t.Fatalf("round 1 pairs = %v, want %v", got, want)
+	}
+	report(t, tr, Player2Win)
+
+	want = [][2]string{{"e", "a"}, {"b", "c"}}
+	if got := pairsOf(tr, 2); !slices.Equal(got, want) {
+		t.Fatalf("round 2 pairs = %v, want %v", got, want)
+	}
+	// 引き分けは先後を入れ替えて同じ卓で再戦する
+	report(t, tr, Drawn, Player1Win)
+	replay := tr.PairingOf("a")
+	if replay.Table() != 1 || replay.Player1() != "a" || replay.Player2() != "e" || replay.IsFinished() {
+		t.Fatalf("replay = table %d %s vs %s, want table 1 a vs e", replay.Table(), replay.Player1(), replay.Player2())
+	}
+	if tr.CurrentRound() != 2 {
+		t.Fatalf("CurrentRound() = %d before the replay finished, want 2", tr.CurrentRound())
+	}
+	report(t, tr, Player1Win)
+
+	want = [][2]string{{"a", "b"}}
+	if got := pairsOf(tr, 3); !slices.Equal(got, want) {
+		t.Fatalf("final pairs = %v, want %v", got, want)
+	}
+	report(t, tr, Player2Win)
+	if tr.State() != Finished {
+		t.Errorf("State() = %v, want Finished", tr.State())
+	}
+}
@@ -0,0 +1,335 @@
+package tournament
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Format int
+
+const (
+	// スイス式: 同じ勝ち点同士を再戦なしで組み合わせ、規定ラウンド数を戦う
+	Swiss Format = iota
+	// ノックアウト式: 負けたら敗退するトーナメント
+	Knockout
+)
+
+var formatNames = map[Format]string{
+	Swiss:    "swiss",
+	Knockout: "knockout",
+}
+
+func NewFormatFromText(text string) (Format, error) {
+	for f, n := range formatNames {
+		if n == text {
+			return f, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown format: %q", text)
+}
+
+func (f Format) Msg() string {
+	return formatNames[f]
+}
+
+type State int
+
+const (
+	Registering State = iota
+	Running
+	Finished
+)
+
+// 結果は /rating/finish と同じく player1 から見た "1", "0", "0.5" で表します
+const (
+	Player1Win = "1"
+	Player2Win = "0"
+	Drawn      = "0.5"
+)
+
+// Pairing は1ラウンドの1卓の組み合わせです
+// player1 が先手で、player2 が空のときは不戦勝(bye)です
+type Pairing struct {
+	round   int
+	table   int
+	player1 string
+	player2 string
+	roomID  string
+	result  string
+}
+
+func (p *Pairing) Round() int {
+	return p.round
+}
+
+func (p *Pairing) Table() int {
+	return p.table
+}
+
+func (p *Pairing) Player1() string {
+	return p.player1
+}
+
+func (p *Pairing) Player2() string {
+	return p.player2
+}
+
+// RoomID は対局に使う Ayame のルームIDです
+func (p *Pairing) RoomID() string {
+	return p.roomID
+}
+
+func (p *Pairing) Result() string {
+	return p.result
+}
+
+func (p *Pairing) IsBye() bool {
+	return p.player2 == ""
+}
+
+func (p *Pairing) IsFinished() bool {
+	return p.result != ""
+}
+
+func (p *Pairing) Has(player string) bool {
+	return p.player1 == player || p.player2 == player
+}
+
+// Opponent は player の相手を返します
+func (p *Pairing) Opponent(player string) string {
+	if p.player1 == player {
+		return p.player2
+	}
+	return p.player1
+}
+
+// Score は player の勝ち点を返します
+func (p *Pairing) Score(player string) float64 {
+	switch {
+	case p.result == Drawn:
+		return 0.5
+	case p.result == Player1Win && p.player1 == player, p.result == Player2Win && p.player2 == player:
+		return 1
+	}
+	return 0
+}
+
+// Winner は勝者を返し、引き分けや未決着のときは空文字を返します
+func (p *Pairing) Winner() string {
+	switch p.result {
+	case Player1Win:
+		return p.player1
+	case Player2Win:
+		return p.player2
+	}
+	return ""
+}
+
+// Tournament は参加登録から組み合わせ、結果の集計までを管理します
+type Tournament struct {
+	id       string
+	format   Format
+	state    State
+	rounds   int
+	players  []string
+	pairings [][]*Pairing
+}
+
+// New は rounds ラウンドのトーナメントを作ります
+// ノックアウト式では rounds は無視され、参加人数から決まります
+func New(id string, format Format, rounds int) *Tournament {
+	return &Tournament{
+		id:       id,
+		format:   format,
+		state:    Registering,
+		rounds:   rounds,
+		players:  make([]string, 0),
+		pairings: make([][]*Pairing, 0),
+	}
+}
+
+func (t *Tournament) ID() string {
+	return t.id
+}
+
+func (t *Tournament) Format() Format {
+	return t.format
+}
+
+func (t *Tournament) State() State {
+	return t.state
+}
+
+// Rounds は予定しているラウンド数を返します
+func (t *Tournament) Rounds() int {
+	return t.rounds
+}
+
+func (t *Tournament) Players() []string {
+	return t.players
+}
+
+// Register は参加者を登録します
+// 登録順がノックアウト式のシード順になります
+func (t *Tournament) Register(player string) error {
+	if player == "" {
+		return fmt.Errorf("player id must not be empty")
+	}
+	if slices.Contains(t.players, player) {
+		return nil
+	}
+	if t.state != Registering {
+		return fmt.Errorf("registration is closed")
+	}
+	t.players = append(t.players, player)
+	return nil
+}
+
+func (t *Tournament) IsRegistered(player string) bool {
+	return slices.Contains(t.players, player)
+}
+
+// Start は登録を締め切り、1ラウンド目を組みます
+func (t *Tournament) Start() error {
+	if t.state != Registering {
+		return fmt.Errorf("tournament has already started")
+	}
+	if len(t.players) < 2 {
+		return fmt.Errorf("tournament needs at least 2 players: %d", len(t.players))
+	}
+	if t.format == Knockout {
+		t.rounds = knockoutRounds(len(t.players))
+	}
+	if t.rounds < 1 {
+		return fmt.Errorf("invalid rounds: %d", t.rounds)
+	}
+	t.state = Running
+	t.nextRound()
+	return nil
+}
+
+// CurrentRound は進行中のラウンド(1始まり)を返します
+func (t *Tournament) CurrentRound() int {
+	return len(t.pairings)
+}
+
+// Pairings は round ラウンド目の組み合わせを返します
+func (t *Tournament) Pairings(round int) []*Pairing {
+	if round < 1 || round > len(t.pairings) {
+		return nil
+	}
+	return t.pairings[round-1]
+}
+
+// PairingOf は進行中のラウンドで player が参加する組み合わせを返します
+func (t *Tournament) PairingOf(player string) *Pairing {
+	// 再戦があれば最後の組み合わせを返す
+	pairings := t.Pairings(t.CurrentRound())
+	for i := len(pairings) - 1; i >= 0; i-- {
+		if pairings[i].Has(player) {
+			return pairings[i]
+		}
+	}
+	return nil
+}
+
+// FindPairing は roomID の対局を探します
+// マッチ戦の次局は "<roomID>-<局数>" で報告されるので、未決着の組み合わせの前方一致でも探します
+func (t *Tournament) FindPairing(roomID string) *Pairing {
+	var found *Pairing
+	for _, p := range t.Pairings(t.CurrentRound()) {
+		if p.roomID == roomID {
+			return p
+		}
+		if !p.IsFinished() && strings.HasPrefix(roomID, p.roomID+"-") {
+			if found == nil || len(p.roomID) > len(found.roomID) {
+				found = p
+			}
+		}
+	}
+	return found
+}
+
+// Report は対局結果を記録し、ラウンドの全卓が終わったら次のラウンドを組みます
+func (t *Tournament) Report(roomID, result string) error {
+	if t.state != Running {
+		return fmt.Errorf("tournament is not running")
+	}
+	if result != Player1Win && result != Player2Win && result != Drawn {
+		return fmt.Errorf("invalid result: %q", result)
+	}
+	p := t.FindPairing(roomID)
+	if p == nil {
+		return fmt.Errorf("no pairing for room: %s", roomID)
+	}
+	if p.IsFinished() {
+		if p.result != result {
+			return fmt.Errorf("conflicting result for room %s: %s != %s", roomID, p.result, result)
+		}
+		return nil
+	}
+	p.result = result
+	// ノックアウト式の引き分けは先後を入れ替えて再戦する
+	if t.format == Knockout && result == Drawn {
+		round := t.pairings[len(t.pairings)-1]
+		replay := &Pairing{
+			round:   p.round,
+			table:   p.table,
+			player1: p.player2,
+			player2: p.player1,
+			roomID:  fmt.Sprintf("%s-replay%d", p.roomID, len(round)),
+		}
+		t.pairings[len(t.pairings)-1] = append(round, replay)
+		return nil
+	}
+	if slices.ContainsFunc(t.Pairings(t.CurrentRound()), func(p *Pairing) bool {
+		return !p.IsFinished()
+	}) {
+		return nil
+	}
+	if t.CurrentRound() >= t.rounds {
+		t.state = Finished
+		return nil
+	}
+	t.nextRound()
+	return nil
+}
+
+func (t *Tournament) nextRound() {
+	round := len(t.pairings) + 1
+	var pairs [][2]string
+	if t.format == Knockout {
+		pairs = t.knockoutPairs()
+	} else {
+		pairs = t.swissPairs()
+	}
+	pairings := make([]*Pairing, len(pairs))
+	for i, pair := range pairs {
+		p := &Pairing{
+			round:   round,
+			table:   i + 1,
+			player1: pair[0],
+			player2: pair[1],
+			roomID:  fmt.Sprintf("%s-r%d-t%d", t.id, round, i+1),
+		}
+		if p.IsBye() {
+			p.result = Player1Win
+		}
+		pairings[i] = p
+	}
+	t.pairings = append(t.pairings, pairings)
+}
+
+// finished は決着した組み合わせを古い順に返します
+func (t *Tournament) finished() []*Pairing {
+	ps := make([]*Pairing, 0)
+	for _, round := range t.pairings {
+		for _, p := range round {
+			if p.IsFinished() {
+				ps = append(ps, p)
+			}
+		}
+	}
+	return ps
+}
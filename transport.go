@@ -0,0 +1,90 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+	"github.com/ponyo877/go-wasm-hit-and-blow/transport"
+	"github.com/ponyo877/go-wasm-hit-and-blow/turnrest"
+)
+
+const (
+	transportAuto  = "auto"
+	transportP2P   = "p2p"
+	transportRelay = "relay"
+	// relayTimeout は中継サーバで相手が入るのを待つ時間です
+	relayTimeout = time.Minute
+)
+
+var (
+	// relayOrigin が空の場合は中継サーバを使いません
+	relayOrigin string
+)
+
+// transportMode は選ばれた経路です
+func transportMode() string {
+	if relayOrigin == "" {
+		return transportP2P
+	}
+	switch mode := getElementByID("transport").Get("value").String(); mode {
+	case transportP2P, transportRelay:
+		return mode
+	}
+	return transportAuto
+}
+
+// turnCredentials は identity サーバから TURN サーバの一時的な認証情報を取得する関数を返します
+// アカウントがない場合は TURN を使わず、直接つながらなければ中継サーバに切り替えます
+func turnCredentials(identityURL url.URL) func() ([]webrtc.ICEServer, error) {
+	if identityKey == nil {
+		return nil
+	}
+	return func() ([]webrtc.ICEServer, error) {
+		u := identityURL
+		u.Path = "/turn/credentials"
+		res, err := getAuthorized(u.String(), identity.AudienceTURN)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get TURN credentials: %s", res.Status)
+		}
+		var credential turnrest.Credential
+		if err := json.NewDecoder(res.Body).Decode(&credential); err != nil {
+			return nil, err
+		}
+		return []webrtc.ICEServer{{
+			URLs:           credential.URIs,
+			Username:       credential.Username,
+			Credential:     credential.Password,
+			CredentialType: webrtc.ICECredentialTypePassword,
+		}}, nil
+	}
+}
+
+// pathView は接続に使った経路の表示です
+func pathView(path ayame.Path) string {
+	if path == ayame.PathTURN {
+		return "TURN relay"
+	}
+	return "direct P2P"
+}
+
+// dialRelay は中継サーバの roomID のルームで相手とつながります
+func dialRelay(roomID string) (transport.Transport, bool, error) {
+	relayURL := url.URL{Scheme: wsScheme, Host: relayOrigin, Path: "/relay"}
+	ctx, cancel := context.WithTimeout(context.Background(), relayTimeout)
+	defer cancel()
+	return transport.DialRelay(ctx, relayURL, roomID, "relay-hit-and-blow", authToken(identity.AudienceRelay))
+}
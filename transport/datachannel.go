@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// dataChannel は P2P で相手と直接つながる WebRTC の DataChannel です
+// OnClose は DataChannel にそのまま設定し、h はメッセージの保留にだけ使います
+type dataChannel struct {
+	dc *webrtc.DataChannel
+	h  handlers
+}
+
+// NewDataChannel は DataChannel を経路として使います
+// OnMessage を設定する前に届いたメッセージを取りこぼさないよう、すぐに受信を始めます
+func NewDataChannel(dc *webrtc.DataChannel) Transport {
+	t := &dataChannel{dc: dc}
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		if !msg.IsString {
+			return
+		}
+		t.h.message(msg.Data)
+	})
+	return t
+}
+
+func (t *dataChannel) Send(data []byte) error {
+	return t.dc.SendText(string(data))
+}
+
+func (t *dataChannel) OnMessage(f func(data []byte)) {
+	t.h.setOnMessage(f)
+}
+
+func (t *dataChannel) OnOpen(f func()) {
+	// ブラウザの DataChannel は開いた後に設定したコールバック関数を呼ばないので、自分で呼びます
+	var once sync.Once
+	call := func() { once.Do(f) }
+	t.dc.OnOpen(call)
+	if t.dc.ReadyState() == webrtc.DataChannelStateOpen {
+		go call()
+	}
+}
+
+func (t *dataChannel) OnClose(f func()) {
+	t.dc.OnClose(f)
+}
+
+func (t *dataChannel) Close() error {
+	return t.dc.Close()
+}
+
+func (t *dataChannel) State() State {
+	switch t.dc.ReadyState() {
+	case webrtc.DataChannelStateOpen:
+		return StateOpen
+	case webrtc.DataChannelStateClosing:
+		return StateClosing
+	case webrtc.DataChannelStateClosed:
+		return StateClosed
+	}
+	return StateConnecting
+}
+
+func (t *dataChannel) Label() string {
+	return t.dc.Label()
+}
@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// newPeerConnections は同じプロセス内で接続した2つの PeerConnection を返します
+func newPeerConnections(t *testing.T) (*webrtc.PeerConnection, *webrtc.PeerConnection) {
+	t.Helper()
+	var s webrtc.SettingEngine
+	s.SetIncludeLoopbackCandidate(true)
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(s))
+	offerer, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	answerer, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		offerer.Close()
+		answerer.Close()
+	})
+	return offerer, answerer
+}
+
+// signal は ICE の候補を集め終えた offer と answer を交換します
+func signal(t *testing.T, offerer, answerer *webrtc.PeerConnection) {
+	t.Helper()
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(offerer)
+	if err := offerer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := answerer.SetRemoteDescription(*offerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := answerer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered = webrtc.GatheringCompletePromise(answerer)
+	if err := answerer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := offerer.SetRemoteDescription(*answerer.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDataChannelDeliversMessagesBeforeOnMessage は OnMessage を設定する前に届いたメッセージも順に渡すことを確かめます
+func TestDataChannelDeliversMessagesBeforeOnMessage(t *testing.T) {
+	offerer, answerer := newPeerConnections(t)
+	dc, err := offerer.CreateDataChannel("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := wantMessages(3)
+	sender := NewDataChannel(dc)
+	sender.OnOpen(func() {
+		for _, m := range want {
+			if err := sender.Send([]byte(m)); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	received := make(chan Transport, 1)
+	answerer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		received <- NewDataChannel(dc)
+	})
+	signal(t, offerer, answerer)
+
+	var receiver Transport
+	select {
+	case receiver = <-received:
+	case <-time.After(pipeTestTimeout):
+		t.Fatal("DataChannel was not received")
+	}
+	// 相手が送り終えてから OnMessage を設定する
+	time.Sleep(200 * time.Millisecond)
+	got := make(chan string, len(want))
+	receiver.OnMessage(func(data []byte) {
+		got <- string(data)
+	})
+	var messages []string
+	for range want {
+		select {
+		case m := <-got:
+			messages = append(messages, m)
+		case <-time.After(pipeTestTimeout):
+			t.Fatalf("received %v, want %v", messages, want)
+		}
+	}
+	if !slices.Equal(messages, want) {
+		t.Errorf("received %v, want %v", messages, want)
+	}
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"sync"
+)
+
+// pipeBuffer は相手が受け取っていないメッセージを溜めておける数です
+const pipeBuffer = 64
+
+// pipe は同じプロセス内でつながる経路です
+// テストや bot との対局で、ネットワークを使わずに対局のメッセージをやり取りします
+type pipe struct {
+	label string
+	inbox chan []byte
+	peer  *pipe
+	// shared は両端で共有する状態です
+	shared *pipeState
+	handlers
+}
+
+// pipeState は done を閉じることで両端を閉じます
+// inbox は閉じないので、Close と並行して Send しても閉じたチャネルに送ることはありません
+type pipeState struct {
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPipe はつながった2つの経路を返します
+// 片方で Send したメッセージはもう片方の OnMessage に届きます
+func NewPipe(label string) (Transport, Transport) {
+	shared := &pipeState{done: make(chan struct{})}
+	a := &pipe{label: label, inbox: make(chan []byte, pipeBuffer), shared: shared}
+	b := &pipe{label: label, inbox: make(chan []byte, pipeBuffer), shared: shared}
+	a.peer, b.peer = b, a
+	go a.deliver()
+	go b.deliver()
+	return a, b
+}
+
+func (p *pipe) deliver() {
+	for {
+		select {
+		case data := <-p.inbox:
+			p.message(data)
+		case <-p.shared.done:
+			// 閉じる前に届いていたメッセージを渡してから OnClose を呼びます
+			for {
+				select {
+				case data := <-p.inbox:
+					p.message(data)
+				default:
+					p.close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Send は相手が受け取っていないメッセージが pipeBuffer 個溜まっている間は待ち、
+// その間に閉じられた場合は ErrClosed を返します
+func (p *pipe) Send(data []byte) error {
+	select {
+	case <-p.shared.done:
+		return ErrClosed
+	default:
+	}
+	// 送った後に書き換えられても影響しないように複製します
+	select {
+	case p.peer.inbox <- append([]byte(nil), data...):
+		return nil
+	case <-p.shared.done:
+		return ErrClosed
+	}
+}
+
+func (p *pipe) OnMessage(f func(data []byte)) {
+	p.setOnMessage(f)
+}
+
+func (p *pipe) OnOpen(f func()) {
+	if p.State() == StateOpen {
+		go f()
+	}
+}
+
+func (p *pipe) OnClose(f func()) {
+	p.setOnClose(f)
+}
+
+// Close は両端を閉じます
+// 届いていないメッセージは相手に渡してから OnClose を呼びます
+func (p *pipe) Close() error {
+	p.shared.closeOnce.Do(func() {
+		close(p.shared.done)
+	})
+	return nil
+}
+
+func (p *pipe) State() State {
+	select {
+	case <-p.shared.done:
+		return StateClosed
+	default:
+		return StateOpen
+	}
+}
+
+func (p *pipe) Label() string {
+	return p.label
+}
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const pipeTestTimeout = 5 * time.Second
+
+// recorder は OnMessage で受け取ったメッセージと OnClose を記録します
+type recorder struct {
+	messages []string
+	closed   chan struct{}
+	mu       sync.Mutex
+}
+
+func newRecorder(t Transport) *recorder {
+	r := &recorder{closed: make(chan struct{})}
+	t.OnMessage(func(data []byte) {
+		r.mu.Lock()
+		r.messages = append(r.messages, string(data))
+		r.mu.Unlock()
+	})
+	t.OnClose(func() {
+		close(r.closed)
+	})
+	return r
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+func (r *recorder) waitClose(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.closed:
+	case <-time.After(pipeTestTimeout):
+		t.Fatal("OnClose was not called")
+	}
+}
+
+func wantMessages(n int) []string {
+	want := make([]string, n)
+	for i := range want {
+		want[i] = fmt.Sprintf("message %d", i)
+	}
+	return want
+}
+
+func TestPipeOrder(t *testing.T) {
+	a, b := NewPipe("test")
+	ra, rb := newRecorder(a), newRecorder(b)
+	// pipeBuffer より多く送っても順序どおりに届く
+	want := wantMessages(pipeBuffer * 3)
+	for _, m := range want {
+		if err := a.Send([]byte(m)); err != nil {
+			t.Fatalf("Send(%q) error: %v", m, err)
+		}
+	}
+	if err := b.Send([]byte("reply")); err != nil {
+		t.Fatalf("Send(reply) error: %v", err)
+	}
+	a.Close()
+	ra.waitClose(t)
+	rb.waitClose(t)
+	if got := rb.received(); !slices.Equal(got, want) {
+		t.Errorf("received %d messages %v, want %d in order", len(got), got, len(want))
+	}
+	if got := ra.received(); !slices.Equal(got, []string{"reply"}) {
+		t.Errorf("received %v, want [reply]", got)
+	}
+}
+
+func TestPipeDeliversQueuedMessagesBeforeClose(t *testing.T) {
+	a, b := NewPipe("test")
+	// 最初のメッセージで受け取りを止め、残りを溜めたまま閉じる
+	release := make(chan struct{})
+	var once sync.Once
+	rb := &recorder{closed: make(chan struct{})}
+	b.OnMessage(func(data []byte) {
+		once.Do(func() { <-release })
+		rb.mu.Lock()
+		rb.messages = append(rb.messages, string(data))
+		rb.mu.Unlock()
+	})
+	b.OnClose(func() {
+		// OnClose の時点で全部届いている
+		if got := len(rb.received()); got != pipeBuffer {
+			t.Errorf("received %d messages before OnClose, want %d", got, pipeBuffer)
+		}
+		close(rb.closed)
+	})
+	want := wantMessages(pipeBuffer)
+	for _, m := range want {
+		if err := a.Send([]byte(m)); err != nil {
+			t.Fatalf("Send(%q) error: %v", m, err)
+		}
+	}
+	a.Close()
+	close(release)
+	rb.waitClose(t)
+	if got := rb.received(); !slices.Equal(got, want) {
+		t.Errorf("received %v, want %d messages in order", got, len(want))
+	}
+}
+
+func TestPipeSendAfterClose(t *testing.T) {
+	a, b := NewPipe("test")
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("second Close() error: %v", err)
+	}
+	for _, p := range []Transport{a, b} {
+		if err := p.Send([]byte("late")); !errors.Is(err, ErrClosed) {
+			t.Errorf("Send after Close = %v, want %v", err, ErrClosed)
+		}
+		if got := p.State(); got != StateClosed {
+			t.Errorf("State() = %v, want %v", got, StateClosed)
+		}
+	}
+}
+
+// TestPipeCloseUnblocksSend は相手が受け取らずに溜まっている間に Send が待っていても、Close できることを確かめます
+func TestPipeCloseUnblocksSend(t *testing.T) {
+	a, b := NewPipe("test")
+	release := make(chan struct{})
+	b.OnMessage(func(data []byte) {
+		<-release
+	})
+	defer close(release)
+	sent := make(chan error)
+	go func() {
+		for {
+			if err := a.Send([]byte("flood")); err != nil {
+				sent <- err
+				return
+			}
+		}
+	}()
+	// 受け取り側が止まっているので、いずれ Send は待つ
+	time.Sleep(50 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		a.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(pipeTestTimeout):
+		t.Fatal("Close blocked by a pending Send")
+	}
+	select {
+	case err := <-sent:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("pending Send = %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(pipeTestTimeout):
+		t.Fatal("pending Send did not return after Close")
+	}
+}
@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// MaxRelayMessage は中継するメッセージの最大の大きさです
+const MaxRelayMessage = 64 << 10
+
+// RelayPaired は中継サーバが2人目が入った時に両方へ送るメッセージです
+// これより後のメッセージはそのまま相手に中継されます
+type RelayPaired struct {
+	Type string `json:"type"`
+	// Initiator は後から入った方が true で、Ayame の offer 側と同じく対局を始める側です
+	Initiator bool `json:"initiator"`
+}
+
+// relay は P2P でつながらないネットワークのために、サーバを経由して相手とつながる WebSocket です
+type relay struct {
+	ws      *websocket.Conn
+	label   string
+	state   State
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stateMu sync.Mutex
+	handlers
+}
+
+// DialRelay は中継サーバの roomID のルームに入り、相手が入るまで待ちます
+// 戻り値の initiator が true なら対局を始める側です
+func DialRelay(ctx context.Context, relayURL url.URL, roomID, label, token string) (Transport, bool, error) {
+	relayURL.Path = relayURL.Path + "/" + url.PathEscape(roomID)
+	// ブラウザの WebSocket はヘッダを付けられないので、トークンはクエリで渡します
+	if token != "" {
+		q := relayURL.Query()
+		q.Set("token", token)
+		relayURL.RawQuery = q.Encode()
+	}
+	ws, _, err := websocket.Dial(ctx, relayURL.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	ws.SetReadLimit(MaxRelayMessage)
+	var paired RelayPaired
+	if err := wsjson.Read(ctx, ws, &paired); err != nil {
+		ws.Close(websocket.StatusNormalClosure, "close connection")
+		return nil, false, err
+	}
+	if paired.Type != "paired" {
+		ws.Close(websocket.StatusProtocolError, "unexpected message")
+		return nil, false, fmt.Errorf("unexpected relay message: %s", paired.Type)
+	}
+	t := &relay{ws: ws, label: label, state: StateOpen}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	go t.read()
+	return t, paired.Initiator, nil
+}
+
+func (t *relay) read() {
+	defer func() {
+		t.setState(StateClosed)
+		t.cancel()
+		t.close()
+	}()
+	for {
+		typ, data, err := t.ws.Read(t.ctx)
+		if err != nil {
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+		t.message(data)
+	}
+}
+
+func (t *relay) setState(state State) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	t.state = state
+}
+
+func (t *relay) Send(data []byte) error {
+	if t.State() != StateOpen {
+		return ErrClosed
+	}
+	return t.ws.Write(t.ctx, websocket.MessageText, data)
+}
+
+func (t *relay) OnMessage(f func(data []byte)) {
+	t.setOnMessage(f)
+}
+
+func (t *relay) OnOpen(f func()) {
+	if t.State() == StateOpen {
+		go f()
+	}
+}
+
+func (t *relay) OnClose(f func()) {
+	t.setOnClose(f)
+}
+
+func (t *relay) Close() error {
+	t.setState(StateClosing)
+	return t.ws.Close(websocket.StatusNormalClosure, "close connection")
+}
+
+func (t *relay) State() State {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	return t.state
+}
+
+func (t *relay) Label() string {
+	return t.label
+}
@@ -0,0 +1,113 @@
+// Package transport は対局のメッセージを相手に届ける経路です
+// WebRTC の DataChannel、サーバが中継する WebSocket、同じプロセス内のパイプを同じように扱えます
+package transport
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrClosed = errors.New("transport is closed")
+
+// State は経路の状態です
+type State int
+
+const (
+	StateConnecting State = iota
+	StateOpen
+	StateClosing
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateOpen:
+		return "open"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Transport は相手とテキストのメッセージをやり取りする経路です
+type Transport interface {
+	// Send はメッセージを送ります
+	Send(data []byte) error
+	// OnMessage は受信したメッセージのコールバック関数を設定します
+	// 設定する前に届いたメッセージは、設定した時に届いた順に渡します
+	OnMessage(f func(data []byte))
+	// OnOpen は経路が開いた時のコールバック関数を設定します
+	// すでに開いている場合はすぐに呼びます
+	OnOpen(f func())
+	// OnClose は経路が閉じた時のコールバック関数を設定します
+	OnClose(f func())
+	// Close は経路を閉じます
+	Close() error
+	// State は経路の状態を返します
+	State() State
+	// Label は経路の名前です
+	Label() string
+}
+
+// handlers は各経路で共通のコールバック関数の管理です
+type handlers struct {
+	onMessage func(data []byte)
+	onClose   func()
+	pending   [][]byte
+	closed    bool
+	mu        sync.Mutex
+	// deliverMu は保留していたメッセージと新しいメッセージの順序を保ちます
+	deliverMu sync.Mutex
+}
+
+func (h *handlers) setOnMessage(f func(data []byte)) {
+	h.deliverMu.Lock()
+	defer h.deliverMu.Unlock()
+	h.mu.Lock()
+	h.onMessage = f
+	pending := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+	for _, data := range pending {
+		f(data)
+	}
+}
+
+func (h *handlers) message(data []byte) {
+	h.deliverMu.Lock()
+	defer h.deliverMu.Unlock()
+	h.mu.Lock()
+	f := h.onMessage
+	if f == nil {
+		h.pending = append(h.pending, data)
+	}
+	h.mu.Unlock()
+	if f != nil {
+		f(data)
+	}
+}
+
+func (h *handlers) setOnClose(f func()) {
+	h.mu.Lock()
+	h.onClose = f
+	h.mu.Unlock()
+}
+
+// close は最初の1回だけ OnClose のコールバック関数を呼びます
+func (h *handlers) close() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	f := h.onClose
+	h.mu.Unlock()
+	if f != nil {
+		f()
+	}
+}
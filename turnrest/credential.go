@@ -0,0 +1,77 @@
+// Package turnrest は TURN サーバの一時的な認証情報を TURN REST API の形式で発行し、検証します
+// username は "有効期限のUNIX時刻:userID"、password は共有の秘密鍵による username の HMAC-SHA1 です
+// TURN サーバは秘密鍵だけを知っていれば、発行した記録なしに認証できます
+package turnrest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTTL は認証情報の有効期間です
+// 対局中に切れないように、1日程度の長さにしておきます
+const DefaultTTL = 24 * time.Hour
+
+var (
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrExpired         = errors.New("credential is expired")
+)
+
+// Credential はクライアントに返す認証情報です
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TTL は秒単位の有効期間です
+	TTL  int      `json:"ttl"`
+	URIs []string `json:"uris"`
+}
+
+// NewCredential は userID の now から ttl の間有効な認証情報を作ります
+func NewCredential(secret, userID string, uris []string, ttl time.Duration, now time.Time) *Credential {
+	username := fmt.Sprintf("%d:%s", now.Add(ttl).Unix(), userID)
+	return &Credential{
+		Username: username,
+		Password: Password(secret, username),
+		TTL:      int(ttl / time.Second),
+		URIs:     uris,
+	}
+}
+
+// Password は username の password です
+func Password(secret, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseUsername は username から userID と有効期限を取り出します
+func ParseUsername(username string) (string, time.Time, error) {
+	expiresText, userID, ok := strings.Cut(username, ":")
+	if !ok || userID == "" {
+		return "", time.Time{}, ErrInvalidUsername
+	}
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidUsername
+	}
+	return userID, time.Unix(expires, 0), nil
+}
+
+// Verify は username が now の時点で有効かを確かめ、userID を返します
+// password は TURN の長期認証では送られてこないため、TURN サーバは Password で求めて使います
+func Verify(username string, now time.Time) (string, error) {
+	userID, expires, err := ParseUsername(username)
+	if err != nil {
+		return "", err
+	}
+	if !now.Before(expires) {
+		return "", ErrExpired
+	}
+	return userID, nil
+}
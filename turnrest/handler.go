@@ -0,0 +1,40 @@
+package turnrest
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ponyo877/go-wasm-hit-and-blow/identity"
+)
+
+// Issuer は認証済みのプレイヤーに TURN の認証情報を発行します
+type Issuer struct {
+	secret string
+	uris   []string
+	ttl    time.Duration
+	auth   identity.Authenticator
+}
+
+func NewIssuer(secret string, uris []string, ttl time.Duration, auth identity.Authenticator) *Issuer {
+	return &Issuer{secret: secret, uris: uris, ttl: ttl, auth: auth}
+}
+
+// Handler は GET /turn/credentials で認証情報を返します
+// 誰でも TURN サーバを使えないように、identity のトークンを Bearer で送ったものだけに発行します
+func (i *Issuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /turn/credentials", identity.RequireToken(i.auth, identity.AudienceTURN, http.HandlerFunc(i.credentials)))
+	return mux
+}
+
+func (i *Issuer) credentials(w http.ResponseWriter, r *http.Request) {
+	account, _ := identity.AccountFrom(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	// 認証情報はプレイヤーごとなので共有のキャッシュに残さない
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(NewCredential(i.secret, account.ID, i.uris, i.ttl, time.Now())); err != nil {
+		log.Printf("failed to encode credential: %v", err)
+	}
+}
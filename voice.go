@@ -0,0 +1,63 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"github.com/pion/webrtc/v3"
+	"github.com/ponyo877/go-wasm-hit-and-blow/go-ayame"
+)
+
+// voiceEnabled は音声チャットに参加するかです
+// 双方が参加する場合だけ音声の送受信がネゴシエーションされます
+func voiceEnabled() bool {
+	return getElementByID("voice").Get("checked").Bool()
+}
+
+// connectionOptions は接続オプションを返します
+// 音声チャットに参加する場合は Opus で送受信します
+func connectionOptions() *ayame.ConnectionOptions {
+	options := ayame.DefaultOptions()
+	if !voiceEnabled() {
+		return options
+	}
+	options.Audio = ayame.ConnectionAudioOption{
+		Enabled:   true,
+		Direction: webrtc.RTPTransceiverDirectionSendrecv,
+		Codecs: []*webrtc.RTPCodecParameters{{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "audio/opus", ClockRate: 48000, Channels: 2},
+			PayloadType:        111,
+		}},
+	}
+	return options
+}
+
+// attachVoice は接続前にマイクを用意します
+// 押している間だけ話せるように、最初はミュートしておきます
+func attachVoice(conn *ayame.Connection) {
+	conn.OnRemoteAudio(func() {
+		logElem("[Sys]: Voice chat connected\n")
+	})
+	if !conn.Options.Audio.Enabled {
+		return
+	}
+	conn.SetAudioMuted(true)
+	if err := conn.AttachLocalAudio(); err != nil {
+		logger.Error("failed to attach local audio", "err", err)
+		return
+	}
+	getElementByID("push-to-talk").Set("disabled", false)
+}
+
+// setTalking は押して話すボタンの状態に合わせてマイクを切り替えます
+func setTalking(conn *ayame.Connection, talking bool) {
+	if conn == nil || !conn.Options.Audio.Enabled {
+		return
+	}
+	conn.SetAudioMuted(!talking)
+	label := "PUSH TO TALK"
+	if talking {
+		label = "TALKING..."
+	}
+	getElementByID("push-to-talk").Set("innerHTML", label)
+}